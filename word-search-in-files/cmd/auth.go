@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"word-search-in-files/internal/args"
+	"word-search-in-files/pkg/auth"
+	"word-search-in-files/pkg/searcher"
+)
+
+type accessKey struct{}
+
+// access is the caller of a request of an authenticated server
+type access struct {
+	principal *auth.Principal
+	audit     *auth.AuditLog
+}
+
+// accessOf returns the caller of the request, nil when the server is open
+func accessOf(r *http.Request) *access {
+	a, _ := r.Context().Value(accessKey{}).(*access)
+	return a
+}
+
+// ownerOf returns the name of the caller of the request, empty when the server is
+// open
+func ownerOf(r *http.Request) string {
+	if a := accessOf(r); a != nil && a.principal != nil {
+		return a.principal.Name
+	}
+
+	return ""
+}
+
+// record writes the audit event of a denied request of the caller
+func (a *access) record(ev auth.AuditEvent) {
+	if a.principal != nil {
+		ev.Principal, ev.Auth = a.principal.Name, a.principal.Method
+	}
+
+	_ = a.audit.Log(ev)
+}
+
+// deny replies with the error and writes the audit event
+func (a *access) deny(w http.ResponseWriter, r *http.Request, status int, code, reason string) {
+	a.record(auth.AuditEvent{
+		Remote: r.RemoteAddr,
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Status: status,
+		Reason: reason,
+	})
+
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="word-search", ApiKey realm="word-search"`)
+	}
+
+	writeError(w, status, code, reason)
+}
+
+// authenticate lets the requests of the known callers through to the handler, the
+// others are denied and audited
+func authenticate(authn auth.Authenticator, audit *auth.AuditLog, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := &access{audit: audit}
+
+		p, e := authn.Authenticate(r)
+		switch {
+		case errors.Is(e, auth.ErrNoCredentials):
+			a.deny(w, r, http.StatusUnauthorized, codeUnauthorized, "authentication required")
+			return
+		case e != nil:
+			a.deny(w, r, http.StatusUnauthorized, codeUnauthorized, "invalid credentials")
+			return
+		}
+
+		a.principal = p
+		next(w, r.WithContext(context.WithValue(r.Context(), accessKey{}, a)))
+	}
+}
+
+// authorize restricts the filter to the subtrees the caller may search. A path
+// prefix out of all of them, or a caller without any, is denied and audited
+func authorize(w http.ResponseWriter, r *http.Request, f *searcher.Filter) bool {
+	a := accessOf(r)
+	if a == nil {
+		return true
+	}
+
+	if reason := a.restrict(f); reason != "" {
+		a.deny(w, r, http.StatusForbidden, codeForbidden, reason)
+		return false
+	}
+
+	return true
+}
+
+// restrict narrows the filter to the subtrees of the caller, the reason of the denial
+// is empty when the search is allowed
+func (a *access) restrict(f *searcher.Filter) string {
+	p := a.principal
+
+	if len(p.Paths) == 0 {
+		return "no paths are granted to " + p.Name
+	}
+
+	if f.PathPrefix != "" && !p.CanSee(f.PathPrefix) {
+		return "path " + f.PathPrefix + " is not granted to " + p.Name
+	}
+
+	f.Subtrees = p.Paths
+
+	return ""
+}
+
+// newAuthenticator loads the access configuration and opens the audit log
+func newAuthenticator(a *args.Args) (auth.Authenticator, *auth.AuditLog, error) {
+	cfg, e := auth.LoadConfig(a.AuthConfig)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	chain, e := cfg.Authenticator()
+	if e != nil {
+		return nil, nil, e
+	}
+
+	if len(chain) == 0 {
+		return nil, nil, fmt.Errorf("%s: no API keys, token secrets or certificate names", a.AuthConfig)
+	}
+
+	var out io.Writer = os.Stderr
+	if a.AuditLog != "" {
+		f, e := os.OpenFile(a.AuditLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if e != nil {
+			return nil, nil, e
+		}
+		out = f
+	}
+
+	return chain, auth.NewAuditLog(out), nil
+}
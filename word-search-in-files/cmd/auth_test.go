@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"word-search-in-files/pkg/auth"
+	"word-search-in-files/pkg/searcher"
+)
+
+func TestSearchHandler_Auth(t *testing.T) {
+	srch := newTestSearcher(t, map[string]string{
+		"docs/a.txt":   "secret plan",
+		"src/b.txt":    "secret code",
+		"public/c.txt": "secret menu",
+	})
+	srch.Scan()
+
+	keys := auth.NewAPIKeys()
+	keys.Add("all", &auth.Principal{Name: "admin", Paths: []string{"."}})
+	keys.Add("docs", &auth.Principal{Name: "docs-team", Paths: []string{"docs", "public"}})
+	keys.Add("none", &auth.Principal{Name: "nobody"})
+
+	audit := &bytes.Buffer{}
+	handler := authenticate(keys, auth.NewAuditLog(audit), func(w http.ResponseWriter, r *http.Request) {
+		searchHandler(w, r, srch)
+	})
+
+	tests := []struct {
+		name       string
+		key        string
+		url        string
+		wantStatus int
+		wantPaths  []string
+		wantAudit  string
+	}{
+		{name: "All", key: "all", url: "/files/search?word=secret", wantStatus: http.StatusOK, wantPaths: []string{"docs/a.txt", "public/c.txt", "src/b.txt"}},
+		{name: "Subtrees", key: "docs", url: "/files/search?word=secret", wantStatus: http.StatusOK, wantPaths: []string{"docs/a.txt", "public/c.txt"}},
+		{name: "Prefix in a subtree", key: "docs", url: "/files/search?word=secret&path_prefix=docs", wantStatus: http.StatusOK, wantPaths: []string{"docs/a.txt"}},
+		{name: "E: prefix out of the subtrees", key: "docs", url: "/files/search?word=secret&path_prefix=src", wantStatus: http.StatusForbidden, wantAudit: `"principal":"docs-team"`},
+		{name: "E: no paths", key: "none", url: "/files/search?word=secret", wantStatus: http.StatusForbidden, wantAudit: `"principal":"nobody"`},
+		{name: "E: no key", url: "/files/search?word=secret", wantStatus: http.StatusUnauthorized, wantAudit: `"reason":"authentication required"`},
+		{name: "E: unknown key", key: "nope", url: "/files/search?word=secret", wantStatus: http.StatusUnauthorized, wantAudit: `"reason":"invalid credentials"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit.Reset()
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %q", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			var env struct {
+				Results []SearchHit `json:"results"`
+			}
+			if e := json.Unmarshal(rec.Body.Bytes(), &env); e != nil {
+				t.Fatalf("decoding %q: %v", rec.Body.String(), e)
+			}
+
+			var paths []string
+			for _, hit := range env.Results {
+				paths = append(paths, hit.Path)
+			}
+
+			if strings.Join(paths, ",") != strings.Join(tt.wantPaths, ",") {
+				t.Errorf("paths = %v, want %v", paths, tt.wantPaths)
+			}
+
+			if tt.wantAudit == "" && audit.Len() > 0 {
+				t.Errorf("audit = %q, want nothing", audit.String())
+			}
+
+			if !strings.Contains(audit.String(), tt.wantAudit) {
+				t.Errorf("audit = %q, want %s", audit.String(), tt.wantAudit)
+			}
+		})
+	}
+}
+
+func TestSubscriptions_Auth(t *testing.T) {
+	srch := newTestSearcher(t, map[string]string{
+		"docs/a.txt": "secret plan",
+		"src/b.txt":  "secret code",
+	})
+	srch.Scan()
+
+	keys := auth.NewAPIKeys()
+	keys.Add("docs", &auth.Principal{Name: "docs-team", Paths: []string{"docs"}})
+	keys.Add("src", &auth.Principal{Name: "src-team", Paths: []string{"src"}})
+
+	handler := authenticate(keys, auth.NewAuditLog(&bytes.Buffer{}), func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/subscriptions" {
+			subscriptionsHandler(w, r, srch)
+		} else {
+			subscriptionHandler(w, r, srch)
+		}
+	})
+
+	do := func(method, url, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, nil)
+		req.Header.Set("X-API-Key", key)
+
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/subscriptions?word=secret", "docs")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, body %q", rec.Code, rec.Body.String())
+	}
+
+	var env struct {
+		Results []SavedQuery `json:"results"`
+	}
+	if e := json.Unmarshal(rec.Body.Bytes(), &env); e != nil || len(env.Results) != 1 {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), e)
+	}
+	id := env.Results[0].ID
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		key        string
+		wantStatus int
+		wantQuery  bool
+	}{
+		{name: "List of the owner", method: http.MethodGet, url: "/subscriptions", key: "docs", wantStatus: http.StatusOK, wantQuery: true},
+		{name: "List of another", method: http.MethodGet, url: "/subscriptions", key: "src", wantStatus: http.StatusOK},
+		{name: "E: changes of another", method: http.MethodGet, url: "/subscriptions/" + id + "/changes?timeout=0s", key: "src", wantStatus: http.StatusNotFound},
+		{name: "E: events of another", method: http.MethodGet, url: "/subscriptions/" + id + "/events", key: "src", wantStatus: http.StatusNotFound},
+		{name: "E: delete of another", method: http.MethodDelete, url: "/subscriptions/" + id, key: "src", wantStatus: http.StatusNotFound},
+		{name: "Changes of the owner", method: http.MethodGet, url: "/subscriptions/" + id + "/changes?timeout=0s", key: "docs", wantStatus: http.StatusOK},
+		{name: "Delete of the owner", method: http.MethodDelete, url: "/subscriptions/" + id, key: "docs", wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.method, tt.url, tt.key)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %q", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			if tt.method != http.MethodGet || !strings.HasSuffix(tt.url, "/subscriptions") {
+				return
+			}
+
+			var env struct {
+				Results []SavedQuery `json:"results"`
+			}
+			if e := json.Unmarshal(rec.Body.Bytes(), &env); e != nil {
+				t.Fatal(e)
+			}
+
+			if found := len(env.Results) == 1 && env.Results[0].ID == id; found != tt.wantQuery {
+				t.Errorf("results = %+v, want the query %v", env.Results, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestSuggestAndStats_Auth(t *testing.T) {
+	srch := newTestSearcher(t, map[string]string{
+		"docs/a.txt": "public plan",
+		"src/b.txt":  "secret planet",
+	})
+	srch.Scan()
+
+	keys := auth.NewAPIKeys()
+	keys.Add("all", &auth.Principal{Name: "admin", Paths: []string{"."}})
+	keys.Add("docs", &auth.Principal{Name: "docs-team", Paths: []string{"docs"}})
+	keys.Add("none", &auth.Principal{Name: "nobody"})
+
+	handler := authenticate(keys, auth.NewAuditLog(&bytes.Buffer{}), func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stats" {
+			statsHandler(w, r, srch)
+		} else {
+			suggestHandler(w, r, srch)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		key        string
+		url        string
+		wantStatus int
+		wantTerms  string
+	}{
+		{name: "Suggest all", key: "all", url: "/suggest?prefix=p", wantStatus: http.StatusOK, wantTerms: "plan,planet,public"},
+		{name: "Suggest subtrees", key: "docs", url: "/suggest?prefix=p", wantStatus: http.StatusOK, wantTerms: "plan,public"},
+		{name: "Stats all", key: "all", url: "/stats", wantStatus: http.StatusOK, wantTerms: "plan,planet,public,secret"},
+		{name: "Stats subtrees", key: "docs", url: "/stats", wantStatus: http.StatusOK, wantTerms: "plan,public"},
+		{name: "E: suggest no paths", key: "none", url: "/suggest?prefix=p", wantStatus: http.StatusForbidden},
+		{name: "E: stats no paths", key: "none", url: "/stats", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.Header.Set("X-API-Key", tt.key)
+
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %q", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var terms []TermHit
+			if strings.HasPrefix(tt.url, "/stats") {
+				var env struct {
+					Results []CorpusStats `json:"results"`
+				}
+				if e := json.Unmarshal(rec.Body.Bytes(), &env); e != nil || len(env.Results) != 1 {
+					t.Fatalf("decoding %q: %v", rec.Body.String(), e)
+				}
+				terms = env.Results[0].TopTerms
+			} else {
+				var env struct {
+					Results []TermHit `json:"results"`
+				}
+				if e := json.Unmarshal(rec.Body.Bytes(), &env); e != nil {
+					t.Fatalf("decoding %q: %v", rec.Body.String(), e)
+				}
+				terms = env.Results
+			}
+
+			var got []string
+			for _, term := range terms {
+				got = append(got, term.Term)
+			}
+			sort.Strings(got)
+
+			if strings.Join(got, ",") != tt.wantTerms {
+				t.Errorf("terms = %v, want %s", got, tt.wantTerms)
+			}
+		})
+	}
+}
+
+// unreadableFS fails to open one of the files of the map
+type unreadableFS struct {
+	files fstest.MapFS
+	name  string
+}
+
+func (f unreadableFS) Open(name string) (fs.File, error) {
+	if name == f.name {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+
+	return f.files.Open(name)
+}
+
+func TestSearchHandler_AuthScanErrors(t *testing.T) {
+	srch, e := searcher.NewSearcher("", searcher.WithFS(unreadableFS{
+		files: fstest.MapFS{
+			"docs/a.txt":      {Data: []byte("secret plan")},
+			"src/private.txt": {Data: []byte("secret code")},
+		},
+		name: "src/private.txt",
+	}))
+	if e != nil {
+		t.Fatal(e)
+	}
+	srch.Scan()
+
+	keys := auth.NewAPIKeys()
+	keys.Add("all", &auth.Principal{Name: "admin", Paths: []string{"."}})
+	keys.Add("docs", &auth.Principal{Name: "docs-team", Paths: []string{"docs"}})
+
+	handler := authenticate(keys, auth.NewAuditLog(&bytes.Buffer{}), func(w http.ResponseWriter, r *http.Request) {
+		searchHandler(w, r, srch)
+	})
+
+	tests := []struct {
+		name      string
+		key       string
+		url       string
+		wantPaths string
+	}{
+		{name: "All", key: "all", url: "/files/search?word=secret", wantPaths: "src/private.txt"},
+		{name: "Subtrees", key: "docs", url: "/files/search?word=secret"},
+		{name: "Stream", key: "docs", url: "/files/search?word=secret&stream=ndjson"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.Header.Set("X-API-Key", tt.key)
+
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+			}
+
+			if tt.wantPaths == "" && strings.Contains(rec.Body.String(), "private") {
+				t.Errorf("body = %q, want no path out of the subtrees", rec.Body.String())
+			}
+
+			if tt.wantPaths != "" && !strings.Contains(rec.Body.String(), `"path":"`+tt.wantPaths+`"`) {
+				t.Errorf("body = %q, want the error of %s", rec.Body.String(), tt.wantPaths)
+			}
+		})
+	}
+}
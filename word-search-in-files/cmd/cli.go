@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+	"word-search-in-files/internal/args"
+	"word-search-in-files/pkg/searcher"
+)
+
+// Exit codes of the subcommands, the same as grep(1) and diff(1): 1 when nothing is
+// found or the snapshots differ
+const (
+	exitOK       = 0
+	exitNotFound = 1
+	exitDiffers  = 1
+	exitError    = 2
+)
+
+// searchMain runs `search` against a directory or a saved index and returns the exit code
+func searchMain(arguments []string, stdout, stderr io.Writer) int {
+	a, e := args.SearchParse(arguments, stderr)
+	if errors.Is(e, flag.ErrHelp) {
+		return exitOK
+	}
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	query, e := searchQuery(a)
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	srch, e := openIndex(a.IndexArgs, a.Index)
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	var hits []searcher.Hit
+	res, e := srch.Stream(context.Background(), query, func(hit searcher.Hit) error {
+		hits = append(hits, hit)
+		return nil
+	})
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	w := bufio.NewWriter(stdout)
+
+	if a.Format == "json" {
+		e = printSearchJSON(w, res, hits)
+	} else {
+		printSearchText(w, stderr, res, hits)
+	}
+
+	if e == nil {
+		e = w.Flush()
+	}
+
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	if len(hits) == 0 {
+		return exitNotFound
+	}
+
+	return exitOK
+}
+
+// searchQuery builds the query of the command line, the filters are parsed the same
+// way as the ones of /files/search
+func searchQuery(a *args.SearchArgs) (searcher.Query, error) {
+	values := url.Values{}
+	for name, v := range map[string]string{
+		"path_prefix":     a.PathPrefix,
+		"ext":             a.Ext,
+		"lang":            a.Lang,
+		"modified_after":  a.ModifiedAfter,
+		"modified_before": a.ModifiedBefore,
+		"min_size":        a.MinSize,
+		"max_size":        a.MaxSize,
+	} {
+		if v != "" {
+			values.Set(name, v)
+		}
+	}
+
+	filter, e := parseFilter(values)
+	if e != nil {
+		return searcher.Query{}, e
+	}
+
+	return searcher.Query{
+		Words:     a.Words,
+		Filter:    filter,
+		Sort:      searcher.SortOrder(a.Sort),
+		Limit:     a.Limit,
+		Offset:    a.Offset,
+		NameBoost: a.NameBoost,
+	}, nil
+}
+
+// openIndex loads the saved index or scans the directory
+func openIndex(a args.IndexArgs, index string) (*searcher.Searcher, error) {
+	// The queries of the command line are not limited, there is nobody to share with
+	noLimit := searcher.WithMaxQueryCost(0)
+
+	if index == "" {
+		srch, e := newSearcher(a, noLimit)
+		if e != nil {
+			return nil, e
+		}
+
+		return srch, srch.Scan()
+	}
+
+	snap, e := readSnapshotFile(index)
+	if e != nil {
+		return nil, e
+	}
+
+	// The directory, when given, is only read for the text of the segments
+	opts := []searcher.Option{noLimit}
+	if a.Path == "" {
+		opts = append(opts, searcher.WithFS(nil))
+	}
+
+	// The synonyms apply to the queries, not to the saved index
+	if a.Synonyms != "" {
+		syn, e := searcher.LoadSynonyms(a.Synonyms)
+		if e != nil {
+			return nil, e
+		}
+
+		opts = append(opts, searcher.WithSynonyms(syn))
+	}
+
+	srch, e := searcher.NewSearcher(a.Path, opts...)
+	if e != nil {
+		return nil, e
+	}
+
+	srch.Load(snap)
+
+	return srch, nil
+}
+
+// printSearchText prints a path per line, or a `path:N:text` line per matching line
+// or sentence numbered from 1. The notes go to stderr so the output can be piped
+func printSearchText(w, stderr io.Writer, res *searcher.Result, hits []searcher.Hit) {
+	for _, hit := range hits {
+		if len(hit.Segments) == 0 {
+			fmt.Fprintln(w, hit.Path)
+			continue
+		}
+
+		for _, sg := range hit.Segments {
+			fmt.Fprintf(w, "%s:%d:%s\n", hit.Path, sg.Index+1, strings.Join(strings.Fields(sg.Text), " "))
+		}
+	}
+
+	for _, e := range res.Errors {
+		fmt.Fprintf(stderr, "warning: %s\n", e)
+	}
+
+	if len(res.StopWords) > 0 {
+		fmt.Fprintf(stderr, "ignored stop words: %s\n", strings.Join(res.StopWords, ", "))
+	}
+
+	for _, x := range res.Expansions {
+		fmt.Fprintf(stderr, "%s: also searched for %s\n", x.Word, strings.Join(x.Synonyms, ", "))
+	}
+
+	for _, c := range res.Corrections {
+		fmt.Fprintf(stderr, "%s: did you mean %s?\n", c.Word, strings.Join(c.Candidates, ", "))
+	}
+}
+
+// printSearchJSON prints the envelope of /files/search with all the hits
+func printSearchJSON(w io.Writer, res *searcher.Result, hits []searcher.Hit) error {
+	results := make([]SearchHit, len(hits))
+	for i, hit := range hits {
+		results[i] = searchHit(hit)
+	}
+
+	return printEnvelope(w, Envelope{
+		Results: results,
+		Errors:  scanErrors(res.Errors),
+		Meta: &Meta{
+			Total:      res.Total,
+			Limit:      res.Limit,
+			Generation: res.Generation,
+			DidYouMean: corrections(res.Corrections),
+			StopWords:  res.StopWords,
+			Expansions: expansions(res.Expansions),
+		},
+	})
+}
+
+func printEnvelope(w io.Writer, env Envelope) error {
+	data, e := marshalEnvelope(env)
+	if e != nil {
+		return e
+	}
+
+	_, e = w.Write(append(data, '\n'))
+
+	return e
+}
+
+// indexMain runs `index`: scans the directory, saves the index as a snapshot and
+// prints the stats of the index
+func indexMain(arguments []string, stdout, stderr io.Writer) int {
+	a, e := args.IndexParse(arguments, stderr)
+	if errors.Is(e, flag.ErrHelp) {
+		return exitOK
+	}
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	srch, e := newSearcher(a.IndexArgs)
+	if e == nil {
+		e = srch.Scan()
+	}
+	if e == nil {
+		e = writeSnapshotFile(srch, a.Output)
+	}
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	st, e := srch.Stats(searcher.DefaultTopTerms, nil)
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	scan := srch.LastScan()
+
+	if a.Format == "json" {
+		e = printEnvelope(stdout, Envelope{
+			Results: []CorpusStats{corpusStats(st)},
+			Errors:  scanErrors(srch.Errors),
+			Meta:    &Meta{Total: 1, Limit: 1, Generation: st.Generation},
+		})
+	} else {
+		_, e = fmt.Fprintf(stdout, "indexed %d files, %d terms, %d words in %s to %s\n",
+			st.Files, st.Vocabulary, st.Tokens, scan.Duration.Round(time.Millisecond), a.Output)
+
+		for _, e := range srch.Errors {
+			fmt.Fprintf(stderr, "warning: %s\n", e)
+		}
+
+		for _, f := range srch.Files {
+			for _, l := range f.Limits {
+				fmt.Fprintf(stderr, "limited %s: %s\n", f.Path, l)
+			}
+		}
+
+		for _, sk := range srch.Skipped() {
+			if sk.Of != "" {
+				fmt.Fprintf(stderr, "skipped %s: %s of %s\n", sk.Path, sk.Reason, sk.Of)
+			} else {
+				fmt.Fprintf(stderr, "skipped %s: %s\n", sk.Path, sk.Reason)
+			}
+		}
+	}
+
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	if st.Files == 0 {
+		return exitNotFound
+	}
+
+	return exitOK
+}
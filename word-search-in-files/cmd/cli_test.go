@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSearchMain(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"a.txt":     "Hello World\nbye",
+		"dir/b.txt": "World",
+		"c.md":      "nothing here",
+	})
+
+	index := filepath.Join(t.TempDir(), "index.jsonl")
+
+	var stdout, stderr bytes.Buffer
+	if code := indexMain([]string{"-path", dir, "-o", index, "-granularity", "line"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("indexMain() = %d, stderr %q", code, stderr.String())
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+	}{
+		{name: "Found", args: []string{"-path", dir, "World"}, wantCode: exitOK, wantStdout: "a.txt\ndir/b.txt\n"},
+		{name: "All words", args: []string{"-path", dir, "Hello", "World"}, wantCode: exitOK, wantStdout: "a.txt\n"},
+		{name: "Filter", args: []string{"-path", dir, "-path-prefix", "dir", "World"}, wantCode: exitOK, wantStdout: "dir/b.txt\n"},
+		{name: "Not found", args: []string{"-path", dir, "nope"}, wantCode: exitNotFound},
+		{name: "Index", args: []string{"-index", index, "World"}, wantCode: exitOK, wantStdout: "a.txt:1:\ndir/b.txt:1:\n"},
+		{name: "Index with text", args: []string{"-index", index, "-path", dir, "World"}, wantCode: exitOK, wantStdout: "a.txt:1:Hello World\ndir/b.txt:1:World\n"},
+		{name: "E: no words", args: []string{"-path", dir}, wantCode: exitError},
+		{name: "E: no source", args: []string{"World"}, wantCode: exitError},
+		{name: "E: format", args: []string{"-path", dir, "-format", "xml", "World"}, wantCode: exitError},
+		{name: "E: filter", args: []string{"-path", dir, "-min-size", "x", "World"}, wantCode: exitError},
+		{name: "E: index", args: []string{"-index", filepath.Join(dir, "a.txt"), "World"}, wantCode: exitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			if code := searchMain(tt.args, &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("searchMain() = %d, want %d, stderr %q", code, tt.wantCode, stderr.String())
+			}
+
+			if tt.wantStdout != "" && stdout.String() != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantStdout)
+			}
+		})
+	}
+}
+
+func TestSearchMain_JSON(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{"a.txt": "Hello World"})
+
+	var stdout, stderr bytes.Buffer
+	if code := searchMain([]string{"-path", dir, "-format", "json", "Wrold"}, &stdout, &stderr); code != exitNotFound {
+		t.Fatalf("searchMain() = %d, stderr %q", code, stderr.String())
+	}
+
+	var env struct {
+		Results []SearchHit `json:"results"`
+		Meta    Meta        `json:"meta"`
+	}
+	if e := json.Unmarshal(stdout.Bytes(), &env); e != nil {
+		t.Fatal(e)
+	}
+
+	if len(env.Results) != 0 || len(env.Meta.DidYouMean) != 1 || !strings.Contains(strings.Join(env.Meta.DidYouMean[0].Suggestions, ","), "World") {
+		t.Errorf("envelope = %+v", env)
+	}
+}
+
+func TestDuplicatesMain(t *testing.T) {
+	text := "the quick brown fox jumps over the lazy dog while the cat sleeps on the warm mat near the door"
+
+	dir := writeTestFiles(t, map[string]string{
+		"a.txt":     text,
+		"dir/b.txt": text,
+		"c.txt":     "an unrelated note about the weather and the train schedule",
+	})
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+	}{
+		{name: "Found", args: []string{"-path", dir}, wantCode: exitOK, wantStdout: "similarity 1.00\na.txt\ndir/b.txt\n"},
+		{name: "SimHash", args: []string{"-path", dir, "-method", "simhash"}, wantCode: exitOK, wantStdout: "similarity 1.00\na.txt\ndir/b.txt\n"},
+		{name: "Not found", args: []string{"-path", dir, "-path-prefix", "dir"}, wantCode: exitNotFound},
+		{name: "E: threshold", args: []string{"-path", dir, "-threshold", "2"}, wantCode: exitError},
+		{name: "E: method", args: []string{"-path", dir, "-method", "md5"}, wantCode: exitError},
+		{name: "E: no source", args: []string{}, wantCode: exitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			if code := duplicatesMain(tt.args, &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("duplicatesMain() = %d, want %d, stderr %q", code, tt.wantCode, stderr.String())
+			}
+
+			if tt.wantStdout != "" && stdout.String() != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantStdout)
+			}
+		})
+	}
+}
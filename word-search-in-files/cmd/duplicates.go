@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"word-search-in-files/internal/args"
+	"word-search-in-files/pkg/searcher"
+)
+
+// DuplicateCluster is a group of near-identical files
+type DuplicateCluster struct {
+	Paths      []string `json:"paths"`
+	Similarity float64  `json:"similarity"`
+}
+
+func duplicateClusters(res *searcher.DuplicateResult) []DuplicateCluster {
+	clusters := make([]DuplicateCluster, len(res.Clusters))
+	for i, c := range res.Clusters {
+		clusters[i] = DuplicateCluster{Paths: c.Paths, Similarity: c.Similarity}
+	}
+
+	return clusters
+}
+
+// parseDuplicateQuery reads `threshold`, `method` and the filters of /files/search
+func parseDuplicateQuery(values url.Values) (searcher.DuplicateQuery, error) {
+	q := searcher.DuplicateQuery{Method: searcher.SimilarityMethod(values.Get("method"))}
+
+	if v := values.Get("threshold"); v != "" {
+		var e error
+		if q.Threshold, e = strconv.ParseFloat(v, 64); e != nil {
+			return q, fmt.Errorf("invalid threshold %q", v)
+		}
+	}
+
+	var e error
+	q.Filter, e = parseFilter(values)
+
+	return q, e
+}
+
+// duplicatesHandler replies with the clusters of near-identical files the caller may
+// see, `threshold` is the minimum similarity and `method` minhash or simhash
+func duplicatesHandler(w http.ResponseWriter, r *http.Request, srch *searcher.Searcher) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	if !srch.Ready() {
+		notReady(w)
+		return
+	}
+
+	q, e := parseDuplicateQuery(r.URL.Query())
+	if e != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, e.Error())
+		return
+	}
+
+	if !authorize(w, r, &q.Filter) {
+		return
+	}
+
+	res, e := srch.Duplicates(q)
+	if e != nil {
+		status, code := queryError(e)
+		writeError(w, status, code, e.Error())
+		return
+	}
+
+	clusters := duplicateClusters(res)
+
+	writeJSON(w, http.StatusOK, Envelope{
+		Results: clusters,
+		Meta:    &Meta{Total: len(clusters), Limit: len(clusters), Generation: res.Generation},
+	})
+}
+
+// duplicatesMain runs `duplicates` against a directory or a saved index and returns
+// the exit code
+func duplicatesMain(arguments []string, stdout, stderr io.Writer) int {
+	a, e := args.DuplicatesParse(arguments, stderr)
+	if errors.Is(e, flag.ErrHelp) {
+		return exitOK
+	}
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	srch, e := openIndex(a.IndexArgs, a.Index)
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	res, e := srch.Duplicates(searcher.DuplicateQuery{
+		Threshold: a.Threshold,
+		Method:    searcher.SimilarityMethod(a.Method),
+		Filter:    searcher.Filter{PathPrefix: a.PathPrefix},
+	})
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	clusters := duplicateClusters(res)
+	w := bufio.NewWriter(stdout)
+
+	if a.Format == "json" {
+		e = printEnvelope(w, Envelope{
+			Results: clusters,
+			Meta:    &Meta{Total: len(clusters), Limit: len(clusters), Generation: res.Generation},
+		})
+	} else {
+		// The clusters are separated by an empty line, each starts with its similarity
+		for i, c := range clusters {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+
+			fmt.Fprintf(w, "similarity %.2f\n", c.Similarity)
+			for _, p := range c.Paths {
+				fmt.Fprintln(w, p)
+			}
+		}
+	}
+
+	if e == nil {
+		e = w.Flush()
+	}
+
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	if len(clusters) == 0 {
+		return exitNotFound
+	}
+
+	return exitOK
+}
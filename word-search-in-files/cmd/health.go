@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"word-search-in-files/pkg/searcher"
+)
+
+// healthHandler reports that the process is alive
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// readyHandler reports whether the index can be searched, i.e. the first scan has completed
+func readyHandler(w http.ResponseWriter, r *http.Request, srch *searcher.Searcher) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if !srch.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("not ready: initial scan in progress\n"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
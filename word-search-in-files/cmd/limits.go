@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+	"word-search-in-files/internal/args"
+	"word-search-in-files/pkg/metrics"
+	"word-search-in-files/pkg/ratelimit"
+)
+
+// queryLimits protects the search endpoint from the clients sending more or heavier
+// queries than the server can take. The cost of a query is limited by the searcher
+type queryLimits struct {
+	// nil does not limit
+	rate *ratelimit.Limiter
+	gate *ratelimit.Gate
+
+	queueTimeout time.Duration
+
+	rejected *metrics.CounterVec
+}
+
+// newQueryLimits configures the limits and reports them in the metrics
+func newQueryLimits(a *args.Args, r *metrics.Registry) *queryLimits {
+	l := &queryLimits{
+		queueTimeout: a.QueueTimeout,
+		rejected: r.NewCounterVec("wordsearch_http_rejected_total",
+			"Searches rejected by the limits by reason.", "reason"),
+	}
+
+	if a.RateLimit > 0 {
+		l.rate = ratelimit.NewLimiter(a.RateLimit, a.RateBurst)
+	}
+
+	if a.MaxConcurrent > 0 {
+		l.gate = ratelimit.NewGate(a.MaxConcurrent)
+	}
+
+	r.NewGaugeFunc("wordsearch_limit_rate", "Searches per second of a client, 0 when not limited.", func() float64 {
+		return a.RateLimit
+	})
+	r.NewGaugeFunc("wordsearch_limit_burst", "Searches a client may send at once above the rate.", func() float64 {
+		return float64(a.RateBurst)
+	})
+	r.NewGaugeFunc("wordsearch_limit_max_concurrent", "Searches served at once, 0 when not limited.", func() float64 {
+		return float64(a.MaxConcurrent)
+	})
+	r.NewGaugeFunc("wordsearch_limit_queue_timeout_seconds", "How long a search waits for a free slot.", func() float64 {
+		return a.QueueTimeout.Seconds()
+	})
+	r.NewGaugeFunc("wordsearch_limit_max_query_cost", "Dictionary terms and postings a search may walk, 0 when not limited.", func() float64 {
+		return float64(a.MaxQueryCost)
+	})
+	r.NewGaugeFunc("wordsearch_searches_in_flight", "Searches being served.", func() float64 {
+		if l.gate == nil {
+			return 0
+		}
+		return float64(l.gate.InFlight())
+	})
+	r.NewGaugeFunc("wordsearch_searches_queued", "Searches waiting for a free slot.", func() float64 {
+		if l.gate == nil {
+			return 0
+		}
+		return float64(l.gate.Waiting())
+	})
+	r.NewGaugeFunc("wordsearch_rate_limited_clients", "Clients with a partly used rate limit.", func() float64 {
+		if l.rate == nil {
+			return 0
+		}
+		return float64(l.rate.Clients())
+	})
+
+	return l
+}
+
+// limitError is a search rejected by the limits
+type limitError struct {
+	status     int
+	code       string
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *limitError) Error() string {
+	return e.message
+}
+
+// admit applies the rate limit of the client, then waits for a free slot. The slot
+// must be given back by calling release. A client over its rate gets a 429 error, a
+// search that does not get a slot in time a 503 one
+func (l *queryLimits) admit(ctx context.Context, key string) (release func(), e error) {
+	if l.rate != nil {
+		if ok, wait := l.rate.Allow(key); !ok {
+			l.rejected.With("rate").Inc()
+			return nil, &limitError{status: http.StatusTooManyRequests, code: codeRateLimited, message: "too many requests, retry later", retryAfter: wait}
+		}
+	}
+
+	if l.gate == nil {
+		return func() {}, nil
+	}
+
+	release, e = l.gate.Acquire(ctx, l.queueTimeout)
+	if errors.Is(e, ratelimit.ErrQueueTimeout) {
+		l.rejected.With("queue").Inc()
+		return nil, &limitError{status: http.StatusServiceUnavailable, code: codeOverloaded, message: "too many searches in progress, retry later", retryAfter: time.Second}
+	}
+
+	return release, e
+}
+
+// wrap admits the searches of the handler, the rejected ones get Retry-After
+func (l *queryLimits) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		release, e := l.admit(r.Context(), clientKey(r))
+
+		var le *limitError
+		if errors.As(e, &le) {
+			w.Header().Set("Retry-After", retryAfter(le.retryAfter))
+			writeError(w, le.status, le.code, le.message)
+			return
+		}
+		if e != nil {
+			// The client has gone
+			return
+		}
+		defer release()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		if rec.status == http.StatusTooManyRequests {
+			l.rejected.With("cost").Inc()
+		}
+	}
+}
+
+// clientKey identifies the client of the rate limit: the authenticated caller, or
+// the IP address
+func clientKey(r *http.Request) string {
+	return clientKeyOf(accessOf(r), r.RemoteAddr)
+}
+
+func clientKeyOf(a *access, remoteAddr string) string {
+	if a != nil && a.principal != nil {
+		return "principal:" + a.principal.Name
+	}
+
+	host, _, e := net.SplitHostPort(remoteAddr)
+	if e != nil {
+		host = remoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// retryAfter returns the whole seconds of Retry-After, at least 1
+func retryAfter(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Max(1, math.Ceil(min(d, time.Hour).Seconds()))), 10)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"word-search-in-files/internal/args"
+	"word-search-in-files/pkg/metrics"
+	"word-search-in-files/pkg/searcher"
+)
+
+func TestQueryLimits(t *testing.T) {
+	srch, e := searcher.NewSearcher(writeTestFiles(t, map[string]string{
+		"a.txt": "apple apricot",
+		"b.txt": "avocado",
+	}), searcher.WithMaxQueryCost(4))
+	if e != nil {
+		t.Fatal(e)
+	}
+	srch.Scan()
+
+	reg := metrics.NewRegistry()
+	l := newQueryLimits(&args.Args{RateLimit: 1, RateBurst: 2, MaxConcurrent: 1, QueueTimeout: 10 * time.Millisecond, MaxQueryCost: 4}, reg)
+
+	handler := l.wrap(func(w http.ResponseWriter, r *http.Request) {
+		searchHandler(w, r, srch)
+	})
+
+	get := func(remote, url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.RemoteAddr = remote
+
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		return rec
+	}
+
+	tests := []struct {
+		name       string
+		remote     string
+		url        string
+		busy       bool
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "Ok", remote: "10.0.0.1:1000", url: "/files/search?word=apple", wantStatus: http.StatusOK},
+		{name: "Wildcard", remote: "10.0.0.1:1001", url: "/files/search?word=ap*", wantStatus: http.StatusOK},
+		{name: "E: rate", remote: "10.0.0.1:1002", url: "/files/search?word=apple", wantStatus: http.StatusTooManyRequests, wantCode: codeRateLimited},
+		{name: "E: cost", remote: "10.0.0.2:1000", url: "/files/search?word=*", wantStatus: http.StatusTooManyRequests, wantCode: codeQueryTooBroad},
+		{name: "E: busy", remote: "10.0.0.3:1000", url: "/files/search?word=apple", busy: true, wantStatus: http.StatusServiceUnavailable, wantCode: codeOverloaded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The only slot is taken by another search
+			if tt.busy {
+				release, e := l.gate.Acquire(context.Background(), 0)
+				if e != nil {
+					t.Fatal(e)
+				}
+				defer release()
+			}
+
+			rec := get(tt.remote, tt.url)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %q", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			if tt.wantCode != "" && !strings.Contains(rec.Body.String(), `"code":"`+tt.wantCode+`"`) {
+				t.Errorf("body = %q, want code %q", rec.Body.String(), tt.wantCode)
+			}
+
+			if tt.wantStatus != http.StatusOK && tt.wantCode != codeQueryTooBroad && rec.Header().Get("Retry-After") == "" {
+				t.Errorf("no Retry-After")
+			}
+		})
+	}
+
+	out := &strings.Builder{}
+	if _, e := reg.WriteTo(out); e != nil {
+		t.Fatal(e)
+	}
+
+	for _, want := range []string{
+		"wordsearch_limit_rate 1",
+		"wordsearch_limit_max_query_cost 4",
+		`wordsearch_http_rejected_total{reason="rate"} 1`,
+		`wordsearch_http_rejected_total{reason="cost"} 1`,
+		`wordsearch_http_rejected_total{reason="queue"} 1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRPC_ScanLimits(t *testing.T) {
+	srch := newTestSearcher(t, map[string]string{"a.txt": "apple"})
+	srch.Scan()
+
+	l := newQueryLimits(&args.Args{RateLimit: 0.001, RateBurst: 1}, metrics.NewRegistry())
+	handler := rpcHTTPHandler(newRPCServer(srch, l))
+
+	for i, wantCode := range []string{`"files":1`, `"code":-32029`} {
+		req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"Scan","id":1}`))
+		req.RemoteAddr = "10.0.0.1:1000"
+
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if !strings.Contains(rec.Body.String(), wantCode) {
+			t.Errorf("scan %d: body = %q, want %s", i+1, rec.Body.String(), wantCode)
+		}
+	}
+}
+
+func TestNewMux_QueryLimits(t *testing.T) {
+	srch, e := searcher.NewSearcher(writeTestFiles(t, map[string]string{
+		"a.txt": "apple apricot",
+		"b.txt": "avocado apple",
+	}))
+	if e != nil {
+		t.Fatal(e)
+	}
+	srch.Scan()
+
+	m := newServerMetrics(srch)
+	limits := newQueryLimits(&args.Args{RateLimit: 0.001, RateBurst: 1}, m.registry)
+	api := func(next http.HandlerFunc) http.HandlerFunc { return next }
+	mux := newMux(srch, m, api, limits, newRPCServer(srch, limits))
+
+	tests := []string{
+		"/files/search?word=apple",
+		"/files/similar?path=a.txt",
+		"/files/view?path=a.txt&q=apple",
+		"/terms/suggest?prefix=appl",
+		"/stats",
+		"/duplicates",
+		"/files/search?word=aple",
+	}
+
+	for i, url := range tests {
+		t.Run(url, func(t *testing.T) {
+			remote := "192.0.2." + strconv.Itoa(i+1) + ":1234"
+
+			for n := 0; n < 2; n++ {
+				req := httptest.NewRequest(http.MethodGet, url, nil)
+				req.RemoteAddr = remote
+
+				rec := httptest.NewRecorder()
+				mux.ServeHTTP(rec, req)
+
+				if n == 0 && rec.Code != http.StatusOK || n == 1 && rec.Code != http.StatusTooManyRequests {
+					t.Errorf("request %d: status %d", n+1, rec.Code)
+				}
+			}
+		})
+	}
+}
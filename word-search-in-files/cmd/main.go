@@ -2,72 +2,341 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 	"word-search-in-files/internal/args"
+	"word-search-in-files/pkg/auth"
+	"word-search-in-files/pkg/jsonrpc"
+	"word-search-in-files/pkg/remotefs"
 	"word-search-in-files/pkg/searcher"
 )
 
-type ErrorResponse struct {
-	Errors []error `json:"errors"`
+type SearchHit struct {
+	Path     string    `json:"path"`
+	Score    float64   `json:"score"`
+	Modified time.Time `json:"modified"`
+	Size     int64     `json:"size"`
+	Lang     string    `json:"lang,omitempty"`
+	Segments []Segment `json:"segments,omitempty"`
 }
 
+// Segment is a line or a sentence containing all the words, with its byte offsets
+type Segment struct {
+	Index int    `json:"index"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+func searchHit(hit searcher.Hit) SearchHit {
+	res := SearchHit{Path: hit.Path, Score: hit.Score, Modified: hit.Modified, Size: hit.Size, Lang: hit.Lang}
+
+	for _, sg := range hit.Segments {
+		res.Segments = append(res.Segments, Segment{Index: sg.Index, Start: sg.Start, End: sg.End, Text: sg.Text})
+	}
+
+	return res
+}
+
+// searchHandler replies with the envelope of the matched files. A word that is not
+// found is not an error: the results are empty. With `stream=ndjson|sse` (or the
+// matching Accept header) all the hits are streamed instead of a single page
 func searchHandler(w http.ResponseWriter, r *http.Request, srch *searcher.Searcher) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Err: only GET method is allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	if !srch.Ready() {
+		notReady(w)
 		return
 	}
 
-	word := r.URL.Query().Get("word")
-	if word == "" {
-		http.Error(w, "Err: word parameter is required", http.StatusBadRequest)
+	query, e := parseSearchQuery(r)
+	if e != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, e.Error())
+		return
+	}
+
+	if !authorize(w, r, &query.Filter) {
+		return
+	}
+
+	format, e := streamFormat(r)
+	if e != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, e.Error())
 		return
 	}
 
-	var jsonData []byte
+	if format != "" {
+		streamSearch(w, r, srch, query, format)
+		return
+	}
+
+	res, e := srch.Find(query)
+	if e != nil {
+		status, code := queryError(e)
+		writeError(w, status, code, e.Error())
+		return
+	}
+
+	// The reply only changes with the parameters, a new generation of the index or new
+	// synonyms
+	etag := resultETag(res.Generation, res.Synonyms, r.URL.Query(), query.Filter.Subtrees)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if accessOf(r) != nil {
+		w.Header().Set("Vary", "Authorization, X-API-Key")
+	}
+
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	hits := make([]SearchHit, len(res.Hits))
+	for i, hit := range res.Hits {
+		hits[i] = searchHit(hit)
+	}
+
+	writeJSON(w, http.StatusOK, Envelope{
+		Results: hits,
+		Errors:  scanErrors(res.Errors),
+		Meta: &Meta{
+			Total:      res.Total,
+			Limit:      res.Limit,
+			NextCursor: res.NextCursor,
+			Generation: res.Generation,
+			DidYouMean: corrections(res.Corrections),
+			StopWords:  res.StopWords,
+			Expansions: expansions(res.Expansions),
+		},
+	})
+}
+
+// parseSearchQuery reads `word` (repeated or space separated, all must match, `name:`
+// and `path:` for the fields), `limit`, `offset`, `cursor`, `sort`, `name_boost` and the
+// filters from the query string
+func parseSearchQuery(r *http.Request) (searcher.Query, error) {
+	values := r.URL.Query()
+
+	query := searcher.Query{
+		Words:  values["word"],
+		Sort:   searcher.SortOrder(values.Get("sort")),
+		Cursor: values.Get("cursor"),
+	}
+
+	if len(query.Words) == 0 || strings.TrimSpace(strings.Join(query.Words, "")) == "" {
+		return query, errors.New("word parameter is required")
+	}
+
+	var e error
+
+	if v := values.Get("limit"); v != "" {
+		if query.Limit, e = strconv.Atoi(v); e != nil {
+			return query, fmt.Errorf("invalid limit %q", v)
+		}
+	}
+
+	if v := values.Get("offset"); v != "" {
+		if query.Offset, e = strconv.Atoi(v); e != nil {
+			return query, fmt.Errorf("invalid offset %q", v)
+		}
+	}
+
+	if v := values.Get("name_boost"); v != "" {
+		if query.NameBoost, e = strconv.ParseFloat(v, 64); e != nil {
+			return query, fmt.Errorf("invalid name_boost %q", v)
+		}
+	}
+
+	query.Filter, e = parseFilter(values)
+
+	return query, e
+}
+
+// parseFilter reads `path_prefix`, `ext` and `lang` (repeated or comma separated),
+// `modified_after`, `modified_before` (RFC 3339 or YYYY-MM-DD) and `min_size`,
+// `max_size` (bytes)
+func parseFilter(values url.Values) (searcher.Filter, error) {
+	f := searcher.Filter{
+		PathPrefix: values.Get("path_prefix"),
+	}
+
+	for _, v := range values["ext"] {
+		for _, ext := range strings.Split(v, ",") {
+			if ext = strings.TrimSpace(ext); ext != "" {
+				f.Ext = append(f.Ext, ext)
+			}
+		}
+	}
+
+	for _, v := range values["lang"] {
+		for _, lang := range strings.Split(v, ",") {
+			if lang = strings.TrimSpace(lang); lang != "" {
+				f.Lang = append(f.Lang, lang)
+			}
+		}
+	}
+
 	var e error
-	var status int
 
-	files, errors := srch.Search(word)
-	if errors != nil {
-		strErrors := make([]string, len(errors))
+	if v := values.Get("modified_after"); v != "" {
+		if f.ModifiedAfter, e = parseTime(v); e != nil {
+			return f, fmt.Errorf("invalid modified_after %q", v)
+		}
+	}
+
+	if v := values.Get("modified_before"); v != "" {
+		if f.ModifiedBefore, e = parseTime(v); e != nil {
+			return f, fmt.Errorf("invalid modified_before %q", v)
+		}
+	}
 
-		for i, err := range errors {
-			strErrors[i] = err.Error()
+	if v := values.Get("min_size"); v != "" {
+		if f.MinSize, e = strconv.ParseInt(v, 10, 64); e != nil {
+			return f, fmt.Errorf("invalid min_size %q", v)
 		}
+	}
+
+	if v := values.Get("max_size"); v != "" {
+		if f.MaxSize, e = strconv.ParseInt(v, 10, 64); e != nil {
+			return f, fmt.Errorf("invalid max_size %q", v)
+		}
+	}
+
+	return f, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if t, e := time.Parse(time.RFC3339, v); e == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, v)
+}
 
-		jsonData, e = json.Marshal(strErrors)
+// loadStopWords merges the built-in lists of the comma separated languages and the file
+func loadStopWords(langs, file string) ([]string, error) {
+	var res []string
+
+	for _, lang := range strings.Split(langs, ",") {
+		if lang = strings.TrimSpace(lang); lang == "" {
+			continue
+		}
+
+		words, e := searcher.StopWords(lang)
 		if e != nil {
-			http.Error(w, "Err: encoding JSON", http.StatusInternalServerError)
-			return
+			return nil, e
 		}
-		status = http.StatusInternalServerError
-	} else {
-		jsonData, e = json.Marshal(files)
+		res = append(res, words...)
+	}
+
+	if file != "" {
+		f, e := os.Open(file)
 		if e != nil {
-			http.Error(w, "Err: encoding JSON", http.StatusInternalServerError)
-			return
+			return nil, e
 		}
-		status = http.StatusOK
+		defer f.Close()
+
+		words, e := searcher.ReadStopWords(f)
+		if e != nil {
+			return nil, fmt.Errorf("reading %s: %w", file, e)
+		}
+		res = append(res, words...)
+	}
+
+	return res, nil
+}
+
+// newSearcher creates the Searcher of the directory with the index options, then the
+// extra ones
+func newSearcher(idx args.IndexArgs, extra ...searcher.Option) (*searcher.Searcher, error) {
+	granularity, e := searcher.ParseGranularity(idx.Granularity)
+	if e != nil {
+		return nil, e
+	}
+
+	symlinks, e := searcher.ParseSymlinkPolicy(idx.Symlinks)
+	if e != nil {
+		return nil, e
 	}
 
-	w.WriteHeader(status)
-	_, e = w.Write(jsonData)
+	opts := []searcher.Option{
+		searcher.WithGranularity(granularity),
+		searcher.WithSymlinks(symlinks),
+		searcher.WithLimits(searcher.Limits{
+			MaxFileSize:   idx.MaxFileSize,
+			MaxLineLength: idx.MaxLineLength,
+			MaxTokens:     idx.MaxTokens,
+			MemoryBudget:  idx.MemoryBudget,
+		}),
+	}
+
+	stopWords, e := loadStopWords(idx.StopWords, idx.StopWordsFile)
 	if e != nil {
-		fmt.Println("Err: writing response:", e)
+		return nil, e
+	}
+
+	if len(stopWords) > 0 {
+		opts = append(opts, searcher.WithStopWords(stopWords))
+	}
+
+	if idx.Analyzers {
+		opts = append(opts, searcher.WithAnalyzers())
 	}
+
+	if idx.Synonyms != "" {
+		syn, e := searcher.LoadSynonyms(idx.Synonyms)
+		if e != nil {
+			return nil, e
+		}
+
+		opts = append(opts, searcher.WithSynonyms(syn))
+	}
+
+	// A URL is a remote store, see remotefs.Open
+	if remotefs.IsURL(idx.Path) {
+		fsys, e := remotefs.Open(idx.Path)
+		if e != nil {
+			return nil, e
+		}
+
+		opts = append(opts, searcher.WithFS(fsys))
+	}
+
+	return searcher.NewSearcher(idx.Path, append(opts, extra...)...)
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "search":
+			os.Exit(searchMain(os.Args[2:], os.Stdout, os.Stderr))
+		case "index":
+			os.Exit(indexMain(os.Args[2:], os.Stdout, os.Stderr))
+		case "snapshot":
+			os.Exit(snapshotMain(os.Args[2:], os.Stdout, os.Stderr))
+		case "token":
+			os.Exit(tokenMain(os.Args[2:], os.Stdout, os.Stderr))
+		case "duplicates":
+			os.Exit(duplicatesMain(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	args := args.ArgsParse()
 
-	srch, e := searcher.NewSearcher(args.Path)
+	srch, e := newSearcher(args.IndexArgs, searcher.WithMaxQueryCost(args.MaxQueryCost))
 	if e != nil {
 		log.Println(e)
 		return
@@ -77,18 +346,75 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// The server starts listening right away, /readyz reports when the 'init' scan is complete
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 	go srch.ScanPeriodically(ctx, wg, time.Hour)
-	// Wait for the 'init' scan to complete to do not run other code first (http handler)
-	wg.Wait()
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/files/search", func(w http.ResponseWriter, r *http.Request) {
-		searchHandler(w, r, srch)
-	})
+	if args.Synonyms != "" && args.SynonymsReload > 0 {
+		go reloadSynonyms(ctx, args.Synonyms, args.SynonymsReload, srch)
+	}
+
+	m := newServerMetrics(srch)
+
+	// The API requires the callers to authenticate when configured, the probes and
+	// the metrics stay open
+	api := func(h http.HandlerFunc) http.HandlerFunc { return h }
+
+	var authn auth.Authenticator
+	var audit *auth.AuditLog
+
+	if args.AuthConfig != "" {
+		if authn, audit, e = newAuthenticator(args); e != nil {
+			log.Println(e)
+			return
+		}
+
+		api = func(h http.HandlerFunc) http.HandlerFunc { return authenticate(authn, audit, h) }
+	}
+
+	limits := newQueryLimits(args, m.registry)
+	rpc := newRPCServer(srch, limits)
+
+	server := &http.Server{Addr: args.HttpAddr, Handler: newMux(srch, m, api, limits, rpc)}
+
+	if args.TLS() {
+		if server.TLSConfig, e = serverTLSConfig(args); e != nil {
+			log.Println(e)
+			return
+		}
+	}
+
+	// The connections of JSON-RPC share the certificate and the clients of HTTPS
+	if args.RPCAddr != "" {
+		ln, e := net.Listen("tcp", args.RPCAddr)
+		if e != nil {
+			log.Println(e)
+			return
+		}
+
+		if server.TLSConfig != nil {
+			ln = tls.NewListener(ln, server.TLSConfig)
+		}
 
-	e = http.ListenAndServe(args.HttpAddr, mux)
+		go func() {
+			if e := serveRPC(ln, rpc, authn, audit); e != nil {
+				log.Printf("rpc listener: %s", e)
+			}
+		}()
+	}
+
+	// HTTP/2 is negotiated on HTTPS
+	if args.TLS() {
+
+		if args.HSTS > 0 {
+			server.Handler = hsts(args.HSTS, server.Handler)
+		}
+
+		e = server.ListenAndServeTLS("", "")
+	} else {
+		e = server.ListenAndServe()
+	}
 
 	if errors.Is(e, http.ErrServerClosed) {
 		fmt.Printf("server closed\n")
@@ -98,3 +424,35 @@ func main() {
 	}
 
 }
+
+// newMux routes the API through the authentication of api, the queries are admitted by
+// the limits. The subscriptions wait for the scans, they are not queries
+func newMux(srch *searcher.Searcher, m *serverMetrics, api func(http.HandlerFunc) http.HandlerFunc, limits *queryLimits, rpc *jsonrpc.Server) *http.ServeMux {
+	query := func(name string, handler func(http.ResponseWriter, *http.Request, *searcher.Searcher)) http.HandlerFunc {
+		return m.instrument(name, api(limits.wrap(func(w http.ResponseWriter, r *http.Request) {
+			handler(w, r, srch)
+		})))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files/search", query("search", searchHandler))
+	mux.HandleFunc("/files/similar", query("similar", similarHandler))
+	mux.HandleFunc("/files/view", query("view", viewHandler))
+	mux.HandleFunc("/terms/suggest", query("suggest", suggestHandler))
+	mux.HandleFunc("/stats", query("stats", statsHandler))
+	mux.HandleFunc("/duplicates", query("duplicates", duplicatesHandler))
+	mux.HandleFunc("/subscriptions", m.instrument("subscriptions", api(func(w http.ResponseWriter, r *http.Request) {
+		subscriptionsHandler(w, r, srch)
+	})))
+	mux.HandleFunc("/subscriptions/", m.instrument("subscription", api(func(w http.ResponseWriter, r *http.Request) {
+		subscriptionHandler(w, r, srch)
+	})))
+	mux.HandleFunc("/rpc", m.instrument("rpc", api(rpcHTTPHandler(rpc))))
+	mux.HandleFunc("/healthz", healthHandler)
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		readyHandler(w, r, srch)
+	})
+	mux.Handle("/metrics", m.registry)
+
+	return mux
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"word-search-in-files/pkg/searcher"
+)
+
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+
+		if e := os.MkdirAll(filepath.Dir(path), 0o755); e != nil {
+			t.Fatal(e)
+		}
+
+		if e := os.WriteFile(path, []byte(data), 0o644); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	return dir
+}
+
+func newTestSearcher(t *testing.T, files map[string]string) *searcher.Searcher {
+	srch, e := searcher.NewSearcher(writeTestFiles(t, files))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return srch
+}
+
+func TestSearchHandler(t *testing.T) {
+	srch := newTestSearcher(t, map[string]string{
+		"file1.txt": "Hello World",
+		"file2.txt": "World",
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		scan        bool
+		wantStatus  int
+		wantResults int
+		wantCode    string
+	}{
+		{name: "Not ready", method: http.MethodGet, url: "/files/search?word=World", wantStatus: http.StatusServiceUnavailable, wantCode: codeNotReady},
+		{name: "Ok", method: http.MethodGet, url: "/files/search?word=World", scan: true, wantStatus: http.StatusOK, wantResults: 2},
+		{name: "Not found", method: http.MethodGet, url: "/files/search?word=nope", wantStatus: http.StatusOK},
+		{name: "Undetected language", method: http.MethodGet, url: "/files/search?word=World&lang=ru,und", wantStatus: http.StatusOK, wantResults: 2},
+		{name: "Other language", method: http.MethodGet, url: "/files/search?word=World&lang=en", wantStatus: http.StatusOK},
+		{name: "Name", method: http.MethodGet, url: "/files/search?word=name:file2", wantStatus: http.StatusOK, wantResults: 1},
+		{name: "E: name boost", method: http.MethodGet, url: "/files/search?word=World&name_boost=-1", wantStatus: http.StatusBadRequest, wantCode: codeBadRequest},
+		{name: "E: language", method: http.MethodGet, url: "/files/search?word=World&lang=xx", wantStatus: http.StatusBadRequest, wantCode: codeBadRequest},
+		{name: "E: no word", method: http.MethodGet, url: "/files/search", wantStatus: http.StatusBadRequest, wantCode: codeBadRequest},
+		{name: "E: limit", method: http.MethodGet, url: "/files/search?word=World&limit=x", wantStatus: http.StatusBadRequest, wantCode: codeBadRequest},
+		{name: "E: cursor", method: http.MethodGet, url: "/files/search?word=World&cursor=%21", wantStatus: http.StatusBadRequest, wantCode: codeBadCursor},
+		{name: "E: method", method: http.MethodPost, url: "/files/search?word=World", wantStatus: http.StatusMethodNotAllowed, wantCode: codeMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.scan {
+				srch.Scan()
+			}
+
+			rec := httptest.NewRecorder()
+			searchHandler(rec, httptest.NewRequest(tt.method, tt.url, nil), srch)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+
+			var env struct {
+				Version string      `json:"version"`
+				Results []SearchHit `json:"results"`
+				Errors  []ErrorItem `json:"errors"`
+			}
+
+			if e := json.Unmarshal(rec.Body.Bytes(), &env); e != nil {
+				t.Fatalf("decoding %q: %v", rec.Body.String(), e)
+			}
+
+			if env.Version != apiVersion || env.Results == nil || env.Errors == nil {
+				t.Errorf("malformed envelope %q", rec.Body.String())
+			}
+
+			if len(env.Results) != tt.wantResults {
+				t.Errorf("results = %v, want %d", env.Results, tt.wantResults)
+			}
+
+			if tt.wantCode != "" && (len(env.Errors) != 1 || env.Errors[0].Code != tt.wantCode) {
+				t.Errorf("errors = %v, want code %q", env.Errors, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSearchHandler_Stream(t *testing.T) {
+	srch := newTestSearcher(t, map[string]string{
+		"file1.txt": "Hello World",
+		"file2.txt": "World",
+		"file3.txt": "Hello",
+	})
+	srch.Scan()
+
+	tests := []struct {
+		name        string
+		url         string
+		accept      string
+		wantType    string
+		wantPrefix  string
+		wantResults int
+	}{
+		{name: "NDJSON", url: "/files/search?word=World&stream=ndjson", wantType: "application/x-ndjson; charset=utf-8", wantPrefix: `{"type":"result","result":{"path":"file1.txt"`, wantResults: 2},
+		{name: "SSE", url: "/files/search?word=Hello", accept: "text/event-stream", wantType: "text/event-stream; charset=utf-8", wantPrefix: "event: result\ndata: {\"path\":\"file1.txt\"", wantResults: 2},
+		{name: "Empty", url: "/files/search?word=nope&stream=ndjson", wantType: "application/x-ndjson; charset=utf-8", wantPrefix: `{"type":"meta","meta":{"total":0`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.Header.Set("Accept", tt.accept)
+
+			rec := httptest.NewRecorder()
+			searchHandler(rec, req, srch)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+			}
+
+			if ct := rec.Header().Get("Content-Type"); ct != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.wantType)
+			}
+
+			body := rec.Body.String()
+			if !strings.HasPrefix(body, tt.wantPrefix) {
+				t.Errorf("body = %q, want prefix %q", body, tt.wantPrefix)
+			}
+
+			if n := strings.Count(body, `"path"`); n != tt.wantResults {
+				t.Errorf("results = %d, want %d", n, tt.wantResults)
+			}
+
+			if !rec.Flushed {
+				t.Errorf("response was not flushed")
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"word-search-in-files/pkg/metrics"
+	"word-search-in-files/pkg/searcher"
+)
+
+type serverMetrics struct {
+	registry *metrics.Registry
+
+	requests *metrics.CounterVec
+	latency  *metrics.HistogramVec
+}
+
+func newServerMetrics(srch *searcher.Searcher) *serverMetrics {
+	r := metrics.NewRegistry()
+
+	m := &serverMetrics{
+		registry: r,
+		requests: r.NewCounterVec("wordsearch_http_requests_total",
+			"HTTP requests by handler and status code.", "handler", "code"),
+		latency: r.NewHistogramVec("wordsearch_http_request_duration_seconds",
+			"HTTP request latency by handler.", nil, "handler"),
+	}
+
+	r.NewCounterFunc("wordsearch_scans_total", "Completed scans.", func() float64 {
+		return float64(srch.Scans())
+	})
+	r.NewGaugeFunc("wordsearch_scan_duration_seconds", "Duration of the last completed scan.", func() float64 {
+		return srch.LastScan().Duration.Seconds()
+	})
+	r.NewGaugeFunc("wordsearch_scan_timestamp_seconds", "Start time of the last completed scan.", func() float64 {
+		if started := srch.LastScan().Started; !started.IsZero() {
+			return float64(started.UnixNano()) / 1e9
+		}
+		return 0
+	})
+	r.NewGaugeFunc("wordsearch_indexed_files", "Files indexed by the last scan.", func() float64 {
+		return float64(srch.LastScan().Files)
+	})
+	r.NewGaugeFunc("wordsearch_indexed_terms", "Distinct terms indexed by the last scan.", func() float64 {
+		return float64(srch.LastScan().Terms)
+	})
+	r.NewGaugeFunc("wordsearch_scan_reused_files", "Unchanged files the last scan took from the previous one.", func() float64 {
+		return float64(srch.LastScan().Reused)
+	})
+	r.NewGaugeFunc("wordsearch_scan_errors", "Per-file errors of the last scan.", func() float64 {
+		return float64(srch.LastScan().Errors)
+	})
+	r.NewGaugeVecFunc("wordsearch_scan_skipped_entries", "Entries left out by the walker in the last scan by reason.", "reason", func() map[string]float64 {
+		res := make(map[string]float64)
+		for reason, n := range srch.LastScan().Skipped {
+			res[string(reason)] = float64(n)
+		}
+		return res
+	})
+	r.NewGaugeVecFunc("wordsearch_scan_limited_files", "Files of the last scan on which a resource limit fired by limit.", "limit", func() map[string]float64 {
+		res := make(map[string]float64)
+		for limit, n := range srch.LastScan().Limited {
+			res[string(limit)] = float64(n)
+		}
+		return res
+	})
+	r.NewGaugeFunc("wordsearch_pool_queue_depth", "Lines waiting for a worker in the scan in progress.", func() float64 {
+		return float64(srch.QueueDepth())
+	})
+	r.NewCounterFunc("wordsearch_cache_hits_total", "Queries answered from the result cache.", func() float64 {
+		return float64(srch.CacheStats().Hits)
+	})
+	r.NewCounterFunc("wordsearch_cache_misses_total", "Queries not found in the result cache.", func() float64 {
+		return float64(srch.CacheStats().Misses)
+	})
+	r.NewGaugeFunc("wordsearch_cache_entries", "Entries in the result cache.", func() float64 {
+		return float64(srch.CacheStats().Entries)
+	})
+	r.NewGaugeFunc("wordsearch_cache_bytes", "Estimated memory used by the result cache.", func() float64 {
+		return float64(srch.CacheStats().Bytes)
+	})
+	r.NewGaugeFunc("wordsearch_ready", "1 when the first scan has completed.", func() float64 {
+		if srch.Ready() {
+			return 1
+		}
+		return 0
+	})
+
+	return m
+}
+
+// instrument counts the requests of the handler by status code and observes their latency
+func (m *serverMetrics) instrument(handler string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next(rec, r)
+
+		m.latency.With(handler).Observe(time.Since(start).Seconds())
+		m.requests.With(handler, strconv.Itoa(rec.status)).Inc()
+	}
+}
+
+// statusRecorder remembers the status code written by the wrapped handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"word-search-in-files/pkg/searcher"
+)
+
+// Version of the JSON envelope, bumped on incompatible changes
+const apiVersion = "1"
+
+const (
+	codeBadRequest       = "bad_request"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeNotReady         = "not_ready"
+	codeInternal         = "internal"
+	codeNotFound         = "not_found"
+	codeScanError        = "scan_error"
+	codeBadCursor        = "bad_cursor"
+	codeStaleCursor      = "stale_cursor"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeRateLimited      = "rate_limited"
+	codeOverloaded       = "overloaded"
+	codeQueryTooBroad    = "query_too_broad"
+)
+
+// Envelope is the body of every JSON response of the API
+type Envelope struct {
+	Version string      `json:"version"`
+	Results any         `json:"results"`
+	Errors  []ErrorItem `json:"errors"`
+	Meta    *Meta       `json:"meta,omitempty"`
+}
+
+type ErrorItem struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Path    string `json:"path,omitempty"`
+}
+
+type Meta struct {
+	Total      int          `json:"total"`
+	Limit      int          `json:"limit"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	Generation uint64       `json:"generation"`
+	DidYouMean []Correction `json:"did_you_mean,omitempty"`
+	StopWords  []string     `json:"ignored_stop_words,omitempty"`
+	Expansions []Expansion  `json:"expansions,omitempty"`
+}
+
+// marshalEnvelope encodes the envelope of the current version, results and errors
+// are never null in the output
+func marshalEnvelope(env Envelope) ([]byte, error) {
+	env.Version = apiVersion
+
+	if env.Results == nil {
+		env.Results = []struct{}{}
+	}
+
+	if env.Errors == nil {
+		env.Errors = []ErrorItem{}
+	}
+
+	return json.Marshal(env)
+}
+
+// writeJSON writes the envelope, results and errors are never null in the output
+func writeJSON(w http.ResponseWriter, status int, env Envelope) {
+	jsonData, e := marshalEnvelope(env)
+	if e != nil {
+		status = http.StatusInternalServerError
+		jsonData = []byte(`{"version":"` + apiVersion + `","results":[],"errors":[{"code":"` + codeInternal + `","message":"encoding JSON"}]}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	_, e = w.Write(append(jsonData, '\n'))
+	if e != nil {
+		fmt.Println("Err: writing response:", e)
+	}
+}
+
+// writeError replies with a single error and no results
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, Envelope{Errors: []ErrorItem{{Code: code, Message: message}}})
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "only "+allowed+" method is allowed")
+}
+
+func notReady(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "5")
+	writeError(w, http.StatusServiceUnavailable, codeNotReady, "index is not ready yet")
+}
+
+// queryError maps an error of the searcher query API to the status and code of the reply
+func queryError(e error) (int, string) {
+	switch {
+	case errors.Is(e, searcher.ErrQueryTooBroad):
+		return http.StatusTooManyRequests, codeQueryTooBroad
+	case errors.Is(e, searcher.ErrUnknownQuery), errors.Is(e, searcher.ErrUnknownFile):
+		return http.StatusNotFound, codeNotFound
+	case errors.Is(e, searcher.ErrStaleCursor):
+		return http.StatusBadRequest, codeStaleCursor
+	case errors.Is(e, searcher.ErrBadCursor):
+		return http.StatusBadRequest, codeBadCursor
+	case errors.Is(e, searcher.ErrEmptyQuery),
+		errors.Is(e, searcher.ErrEmptyPrefix),
+		errors.Is(e, searcher.ErrBadSort),
+		errors.Is(e, searcher.ErrBadLimit),
+		errors.Is(e, searcher.ErrBadFilter),
+		errors.Is(e, searcher.ErrBadThreshold),
+		errors.Is(e, searcher.ErrBadMethod),
+		errors.Is(e, searcher.ErrBadBoost):
+		return http.StatusBadRequest, codeBadRequest
+	}
+
+	return http.StatusInternalServerError, codeInternal
+}
+
+// scanErrors converts the errors of the scan, the path is reported separately when known
+func scanErrors(errs []error) []ErrorItem {
+	items := make([]ErrorItem, 0, len(errs))
+
+	for _, e := range errs {
+		item := ErrorItem{Code: codeScanError, Message: e.Error()}
+
+		var pe *fs.PathError
+		if errors.As(e, &pe) {
+			item.Path = pe.Path
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// resultETag identifies the reply to the query parameters against a generation of the
+// index and a version of the synonyms, for the subtrees the caller may see
+func resultETag(generation, synonyms uint64, values url.Values, subtrees []string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strconv.FormatUint(synonyms, 10) + "\x00" + values.Encode()))
+	if subtrees != nil {
+		_, _ = h.Write([]byte("\x00" + strings.Join(subtrees, "\x00")))
+	}
+
+	return `"` + strconv.FormatUint(generation, 10) + "-" + strconv.FormatUint(h.Sum64(), 16) + `"`
+}
+
+// notModified reports whether the If-None-Match header of the request matches the etag
+func notModified(r *http.Request, etag string) bool {
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+	"word-search-in-files/pkg/auth"
+	"word-search-in-files/pkg/jsonrpc"
+	"word-search-in-files/pkg/searcher"
+)
+
+// Error codes of the application, the string code of the REST API is in the data
+const (
+	rpcCodeNotReady     = -32000
+	rpcCodeUnauthorized = -32001
+	rpcCodeForbidden    = -32003
+	rpcCodeLimited      = -32029
+)
+
+type SearchParams struct {
+	Words  []string    `json:"words"`
+	Filter QueryFilter `json:"filter"`
+	Sort   string      `json:"sort,omitempty"`
+	Limit  int         `json:"limit,omitempty"`
+	Offset int         `json:"offset,omitempty"`
+	Cursor string      `json:"cursor,omitempty"`
+	// Weight of the matches in the file names, 0 for the default
+	NameBoost float64 `json:"name_boost,omitempty"`
+	// Every hit is sent as a Search.hit notification, the result has no hits. A zero
+	// limit then streams all of them
+	Stream bool `json:"stream,omitempty"`
+}
+
+type SearchResult struct {
+	Hits   []SearchHit `json:"hits"`
+	Errors []ErrorItem `json:"errors"`
+	Meta   Meta        `json:"meta"`
+}
+
+// SearchHitNotification is the params of a Search.hit notification
+type SearchHitNotification struct {
+	// Of the Search request
+	ID  json.RawMessage `json:"id"`
+	Hit SearchHit       `json:"hit"`
+}
+
+type SuggestParams struct {
+	Prefix string `json:"prefix"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+type ScanSummary struct {
+	Started  time.Time `json:"started"`
+	Duration float64   `json:"duration_seconds"`
+	Files    int       `json:"files"`
+	Terms    int       `json:"terms"`
+	Errors   int       `json:"errors"`
+	Reused   int       `json:"reused"`
+}
+
+type AuthenticateParams struct {
+	APIKey string `json:"api_key,omitempty"`
+	Token  string `json:"token,omitempty"`
+}
+
+type rpcPeerKey struct{}
+
+// rpcPeer is the caller of a connection or of an HTTP request
+type rpcPeer struct {
+	remote string
+
+	// Of an authenticated server, the principal of a connection is set by the
+	// certificate or by the Authenticate method
+	authn auth.Authenticator
+	audit *auth.AuditLog
+
+	mu     sync.Mutex
+	access *access
+}
+
+func peerOf(ctx context.Context) *rpcPeer {
+	p, _ := ctx.Value(rpcPeerKey{}).(*rpcPeer)
+	if p == nil {
+		return &rpcPeer{}
+	}
+
+	return p
+}
+
+// caller returns the access of the caller, nil when the server is open, an error
+// when it has not authenticated
+func (p *rpcPeer) caller(ctx context.Context, method string) (*access, error) {
+	// Authenticated by the middleware of the HTTP endpoint
+	if a, ok := ctx.Value(accessKey{}).(*access); ok {
+		return a, nil
+	}
+
+	if p.authn == nil {
+		return nil, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.access == nil {
+		_ = p.audit.Log(auth.AuditEvent{Remote: p.remote, Method: "RPC", Path: method, Status: http.StatusUnauthorized, Reason: "authentication required"})
+		return nil, &jsonrpc.Error{Code: rpcCodeUnauthorized, Message: "authentication required", Data: errorData(codeUnauthorized)}
+	}
+
+	return p.access, nil
+}
+
+// restrict narrows the filter to the subtrees of the caller the way the HTTP endpoint
+// does, a nil access is an open server
+func (p *rpcPeer) restrict(a *access, method string, f *searcher.Filter) error {
+	if a == nil {
+		return nil
+	}
+
+	if reason := a.restrict(f); reason != "" {
+		a.record(auth.AuditEvent{Remote: p.remote, Method: "RPC", Path: method, Status: http.StatusForbidden, Reason: reason})
+		return &jsonrpc.Error{Code: rpcCodeForbidden, Message: reason, Data: errorData(codeForbidden)}
+	}
+
+	return nil
+}
+
+// authenticate checks the credentials the way the HTTP endpoint does
+func (p *rpcPeer) authenticate(r *http.Request) error {
+	principal, e := p.authn.Authenticate(r)
+	if e != nil {
+		reason := "invalid credentials"
+		if errors.Is(e, auth.ErrNoCredentials) {
+			reason = "authentication required"
+		}
+
+		_ = p.audit.Log(auth.AuditEvent{Remote: p.remote, Method: "RPC", Path: "Authenticate", Status: http.StatusUnauthorized, Reason: reason})
+		return &jsonrpc.Error{Code: rpcCodeUnauthorized, Message: reason, Data: errorData(codeUnauthorized)}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.access = &access{principal: principal, audit: p.audit}
+
+	return nil
+}
+
+func errorData(code string) map[string]string {
+	return map[string]string{"code": code}
+}
+
+// rpcError maps an error of the searcher to the error of the reply
+func rpcError(e error) error {
+	var le *limitError
+	if errors.As(e, &le) {
+		return &jsonrpc.Error{Code: rpcCodeLimited, Message: le.message, Data: errorData(le.code)}
+	}
+
+	status, code := queryError(e)
+
+	switch {
+	case status == http.StatusTooManyRequests:
+		return &jsonrpc.Error{Code: rpcCodeLimited, Message: e.Error(), Data: errorData(code)}
+	case status < http.StatusInternalServerError:
+		return &jsonrpc.Error{Code: jsonrpc.CodeInvalidParams, Message: e.Error(), Data: errorData(code)}
+	}
+
+	return &jsonrpc.Error{Code: jsonrpc.CodeInternalError, Message: e.Error(), Data: errorData(code)}
+}
+
+var errRPCNotReady = &jsonrpc.Error{Code: rpcCodeNotReady, Message: "index is not ready yet", Data: errorData(codeNotReady)}
+
+// newRPCServer exposes the searcher as JSON-RPC 2.0 methods: Search, Suggest, Stats,
+// Scan and Authenticate for the connections of an authenticated server
+func newRPCServer(srch *searcher.Searcher, limits *queryLimits) *jsonrpc.Server {
+	s := jsonrpc.NewServer()
+
+	s.Register("Search", func(ctx context.Context, params json.RawMessage) (any, error) {
+		return rpcSearch(ctx, params, srch, limits)
+	})
+
+	s.Register("Suggest", func(ctx context.Context, params json.RawMessage) (any, error) {
+		peer := peerOf(ctx)
+
+		a, e := peer.caller(ctx, "Suggest")
+		if e != nil {
+			return nil, e
+		}
+
+		var p SuggestParams
+		if e := jsonrpc.DecodeParams(params, &p); e != nil {
+			return nil, e
+		}
+
+		if !srch.Ready() {
+			return nil, errRPCNotReady
+		}
+
+		var f searcher.Filter
+		if e := peer.restrict(a, "Suggest", &f); e != nil {
+			return nil, e
+		}
+
+		terms, _, e := srch.Suggest(p.Prefix, p.Limit, f.Subtrees)
+		if e != nil {
+			return nil, rpcError(e)
+		}
+
+		hits := make([]TermHit, len(terms))
+		for i, t := range terms {
+			hits[i] = TermHit{Term: t.Term, Docs: t.Docs}
+		}
+
+		return hits, nil
+	})
+
+	s.Register("Stats", func(ctx context.Context, params json.RawMessage) (any, error) {
+		peer := peerOf(ctx)
+
+		a, e := peer.caller(ctx, "Stats")
+		if e != nil {
+			return nil, e
+		}
+
+		var p struct {
+			Top int `json:"top"`
+		}
+		if e := jsonrpc.DecodeParams(params, &p); e != nil {
+			return nil, e
+		}
+
+		if !srch.Ready() {
+			return nil, errRPCNotReady
+		}
+
+		var f searcher.Filter
+		if e := peer.restrict(a, "Stats", &f); e != nil {
+			return nil, e
+		}
+
+		st, e := srch.Stats(p.Top, f.Subtrees)
+		if e != nil {
+			return nil, rpcError(e)
+		}
+
+		return corpusStats(st), nil
+	})
+
+	// A scan reads every file and holds the searches off meanwhile, only the callers
+	// granted the whole tree may start one, within the limits of the searches
+	s.Register("Scan", func(ctx context.Context, params json.RawMessage) (any, error) {
+		peer := peerOf(ctx)
+
+		a, e := peer.caller(ctx, "Scan")
+		if e != nil {
+			return nil, e
+		}
+
+		if a != nil && !a.principal.Allows("") {
+			a.record(auth.AuditEvent{Remote: peer.remote, Method: "RPC", Path: "Scan", Status: http.StatusForbidden, Reason: "scan needs the whole tree"})
+			return nil, &jsonrpc.Error{Code: rpcCodeForbidden, Message: "scan needs the whole tree", Data: errorData(codeForbidden)}
+		}
+
+		if limits != nil {
+			release, e := limits.admit(ctx, clientKeyOf(a, peer.remote))
+			if e != nil {
+				return nil, rpcError(e)
+			}
+			defer release()
+		}
+
+		if e := srch.Scan(); e != nil {
+			return nil, rpcError(e)
+		}
+
+		st := srch.LastScan()
+
+		return ScanSummary{
+			Started:  st.Started,
+			Duration: st.Duration.Seconds(),
+			Files:    st.Files,
+			Terms:    st.Terms,
+			Errors:   st.Errors,
+			Reused:   st.Reused,
+		}, nil
+	})
+
+	s.Register("Authenticate", func(ctx context.Context, params json.RawMessage) (any, error) {
+		peer := peerOf(ctx)
+		if peer.authn == nil {
+			return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidRequest, "the connection is not authenticated by a method")
+		}
+
+		var p AuthenticateParams
+		if e := jsonrpc.DecodeParams(params, &p); e != nil {
+			return nil, e
+		}
+
+		// The credentials as the HTTP endpoint gets them
+		r := &http.Request{Header: make(http.Header), RemoteAddr: peer.remote}
+		if p.APIKey != "" {
+			r.Header.Set("X-API-Key", p.APIKey)
+		}
+		if p.Token != "" {
+			r.Header.Set("Authorization", "Bearer "+p.Token)
+		}
+
+		if e := peer.authenticate(r); e != nil {
+			return nil, e
+		}
+
+		return map[string]string{"principal": peer.access.principal.Name}, nil
+	})
+
+	return s
+}
+
+func rpcSearch(ctx context.Context, params json.RawMessage, srch *searcher.Searcher, limits *queryLimits) (any, error) {
+	peer := peerOf(ctx)
+
+	a, e := peer.caller(ctx, "Search")
+	if e != nil {
+		return nil, e
+	}
+
+	var p SearchParams
+	if e := jsonrpc.DecodeParams(params, &p); e != nil {
+		return nil, e
+	}
+
+	if !srch.Ready() {
+		return nil, errRPCNotReady
+	}
+
+	query := searcher.Query{
+		Words:     p.Words,
+		Filter:    p.Filter.filter(),
+		Sort:      searcher.SortOrder(p.Sort),
+		Limit:     p.Limit,
+		Offset:    p.Offset,
+		Cursor:    p.Cursor,
+		NameBoost: p.NameBoost,
+	}
+
+	if e := peer.restrict(a, "Search", &query.Filter); e != nil {
+		return nil, e
+	}
+
+	if p.Stream && !jsonrpc.CanNotify(ctx) {
+		return nil, jsonrpc.Errorf(jsonrpc.CodeInvalidParams, "streaming needs a connection or the application/x-ndjson Accept header")
+	}
+
+	if limits != nil {
+		release, e := limits.admit(ctx, clientKeyOf(a, peer.remote))
+		if e != nil {
+			return nil, rpcError(e)
+		}
+		defer release()
+	}
+
+	res := &searcher.Result{}
+	var hits []SearchHit
+
+	if p.Stream {
+		id := jsonrpc.RequestID(ctx)
+
+		res, e = srch.Stream(ctx, query, func(hit searcher.Hit) error {
+			return jsonrpc.Notify(ctx, "Search.hit", SearchHitNotification{ID: id, Hit: searchHit(hit)})
+		})
+	} else if res, e = srch.Find(query); e == nil {
+		for _, hit := range res.Hits {
+			hits = append(hits, searchHit(hit))
+		}
+	}
+
+	if e != nil {
+		if errors.Is(e, searcher.ErrQueryTooBroad) && limits != nil {
+			limits.rejected.With("cost").Inc()
+		}
+		return nil, rpcError(e)
+	}
+
+	if hits == nil {
+		hits = []SearchHit{}
+	}
+
+	return SearchResult{
+		Hits:   hits,
+		Errors: scanErrors(res.Errors),
+		Meta: Meta{
+			Total:      res.Total,
+			Limit:      res.Limit,
+			NextCursor: res.NextCursor,
+			Generation: res.Generation,
+			DidYouMean: corrections(res.Corrections),
+			StopWords:  res.StopWords,
+			Expansions: expansions(res.Expansions),
+		},
+	}, nil
+}
+
+// rpcHTTPHandler serves the JSON-RPC endpoint, the peer is the remote address of
+// the request
+func rpcHTTPHandler(s *jsonrpc.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), rpcPeerKey{}, &rpcPeer{remote: r.RemoteAddr})
+		s.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// serveRPC accepts the JSON-RPC connections, one message per line. With TLS a
+// verified client certificate authenticates the connection, the others call
+// Authenticate first when authn is set
+func serveRPC(ln net.Listener, s *jsonrpc.Server, authn auth.Authenticator, audit *auth.AuditLog) error {
+	return s.Serve(ln, func(ctx context.Context, conn net.Conn) context.Context {
+		peer := &rpcPeer{remote: conn.RemoteAddr().String(), authn: authn, audit: audit}
+
+		if tc, ok := conn.(*tls.Conn); ok && authn != nil {
+			hctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+
+			if tc.HandshakeContext(hctx) == nil {
+				state := tc.ConnectionState()
+				r := &http.Request{Header: make(http.Header), RemoteAddr: peer.remote, TLS: &state}
+
+				if p, e := authn.Authenticate(r); e == nil {
+					peer.access = &access{principal: p, audit: audit}
+				}
+			}
+		}
+
+		return context.WithValue(ctx, rpcPeerKey{}, peer)
+	})
+}
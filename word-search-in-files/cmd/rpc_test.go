@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"word-search-in-files/pkg/auth"
+)
+
+func TestRPC_HTTP(t *testing.T) {
+	srch := newTestSearcher(t, map[string]string{
+		"docs/a.txt": "secret plan",
+		"src/b.txt":  "secret code",
+	})
+	srch.Scan()
+
+	keys := auth.NewAPIKeys()
+	keys.Add("all", &auth.Principal{Name: "admin", Paths: []string{"."}})
+	keys.Add("docs", &auth.Principal{Name: "docs-team", Paths: []string{"docs"}})
+
+	handler := authenticate(keys, auth.NewAuditLog(&bytes.Buffer{}), rpcHTTPHandler(newRPCServer(srch, nil)))
+
+	tests := []struct {
+		name   string
+		key    string
+		accept string
+		body   string
+		want   []string
+	}{
+		{name: "Search", key: "all", body: `{"jsonrpc":"2.0","method":"Search","params":{"words":["secret"]},"id":1}`,
+			want: []string{`"path":"docs/a.txt"`, `"path":"src/b.txt"`, `"total":2`}},
+		{name: "Search in the subtrees", key: "docs", body: `{"jsonrpc":"2.0","method":"Search","params":{"words":["secret"]},"id":1}`,
+			want: []string{`"path":"docs/a.txt"`, `"total":1`}},
+		{name: "Filter", key: "all", body: `{"jsonrpc":"2.0","method":"Search","params":{"words":["secret"],"filter":{"path_prefix":"src"}},"id":1}`,
+			want: []string{`"path":"src/b.txt"`, `"total":1`}},
+		{name: "Stream", key: "all", accept: "application/x-ndjson", body: `{"jsonrpc":"2.0","method":"Search","params":{"words":["plan"],"stream":true},"id":5}`,
+			want: []string{`{"jsonrpc":"2.0","method":"Search.hit","params":{"id":5,"hit":{"path":"docs/a.txt"`, `"result":{"hits":[]`}},
+		{name: "Batch", key: "all", body: `[{"jsonrpc":"2.0","method":"Suggest","params":{"prefix":"se"},"id":1},{"jsonrpc":"2.0","method":"Stats","id":2}]`,
+			want: []string{`"term":"secret"`, `"files":2`}},
+		{name: "Scan", key: "all", body: `{"jsonrpc":"2.0","method":"Scan","id":1}`, want: []string{`"files":2`}},
+		{name: "E: stream without ndjson", key: "all", body: `{"jsonrpc":"2.0","method":"Search","params":{"words":["plan"],"stream":true},"id":1}`,
+			want: []string{`"code":-32602`}},
+		{name: "E: prefix out of the subtrees", key: "docs", body: `{"jsonrpc":"2.0","method":"Search","params":{"words":["secret"],"filter":{"path_prefix":"src"}},"id":1}`,
+			want: []string{`"code":-32003`, `"data":{"code":"forbidden"}`}},
+		{name: "E: scan in the subtrees", key: "docs", body: `{"jsonrpc":"2.0","method":"Scan","id":1}`, want: []string{`"code":-32003`}},
+		{name: "E: unknown field", key: "all", body: `{"jsonrpc":"2.0","method":"Search","params":{"word":"secret"},"id":1}`, want: []string{`"code":-32602`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(tt.body))
+			req.Header.Set("X-API-Key", tt.key)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body = %q, want %s in it", rec.Body.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestRPC_Conn(t *testing.T) {
+	srch := newTestSearcher(t, map[string]string{"a.txt": "secret"})
+	srch.Scan()
+
+	keys := auth.NewAPIKeys()
+	keys.Add("all", &auth.Principal{Name: "admin", Paths: []string{"."}})
+
+	audit := &bytes.Buffer{}
+	s := newRPCServer(srch, nil)
+
+	client, conn := net.Pipe()
+	defer client.Close()
+
+	peer := &rpcPeer{remote: "pipe", authn: keys, audit: auth.NewAuditLog(audit)}
+	go s.ServeConn(context.WithValue(context.Background(), rpcPeerKey{}, peer), conn)
+
+	sc := bufio.NewScanner(client)
+	call := func(msg string) string {
+		go func() { _, _ = client.Write([]byte(msg + "\n")) }()
+
+		if !sc.Scan() {
+			t.Fatalf("no reply to %s", msg)
+		}
+
+		return sc.Text()
+	}
+
+	steps := []struct {
+		msg  string
+		want string
+	}{
+		{msg: `{"jsonrpc":"2.0","method":"Search","params":{"words":["secret"]},"id":1}`, want: `"code":-32001`},
+		{msg: `{"jsonrpc":"2.0","method":"Authenticate","params":{"api_key":"nope"},"id":2}`, want: `"message":"invalid credentials"`},
+		{msg: `{"jsonrpc":"2.0","method":"Authenticate","params":{"api_key":"all"},"id":3}`, want: `"result":{"principal":"admin"}`},
+		{msg: `{"jsonrpc":"2.0","method":"Search","params":{"words":["secret"]},"id":4}`, want: `"path":"a.txt"`},
+	}
+
+	for _, st := range steps {
+		if got := call(st.msg); !strings.Contains(got, st.want) {
+			t.Errorf("%s: got %s, want %s in it", st.msg, got, st.want)
+		}
+	}
+
+	if !strings.Contains(audit.String(), `"path":"Search"`) {
+		t.Errorf("audit = %q, want the denied search", audit.String())
+	}
+}
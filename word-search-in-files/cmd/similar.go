@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"word-search-in-files/pkg/searcher"
+)
+
+// SimilarHit is a file close to the one of the query
+type SimilarHit struct {
+	Path  string  `json:"path"`
+	Score float64 `json:"score"`
+}
+
+// parseSimilarQuery reads `path`, `k` and the filters of /files/search
+func parseSimilarQuery(values url.Values) (searcher.SimilarQuery, error) {
+	q := searcher.SimilarQuery{Path: values.Get("path")}
+
+	if q.Path == "" {
+		return q, errors.New("path parameter is required")
+	}
+
+	if v := values.Get("k"); v != "" {
+		var e error
+		if q.K, e = strconv.Atoi(v); e != nil {
+			return q, fmt.Errorf("invalid k %q", v)
+		}
+	}
+
+	var e error
+	q.Filter, e = parseFilter(values)
+
+	return q, e
+}
+
+// similarHandler replies with the `k` files the caller may see most similar to the
+// one of `path`, by the cosine similarity of their tf-idf vectors
+func similarHandler(w http.ResponseWriter, r *http.Request, srch *searcher.Searcher) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	if !srch.Ready() {
+		notReady(w)
+		return
+	}
+
+	q, e := parseSimilarQuery(r.URL.Query())
+	if e != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, e.Error())
+		return
+	}
+
+	if !authorize(w, r, &q.Filter) {
+		return
+	}
+
+	res, e := srch.Similar(q)
+	if e != nil {
+		status, code := queryError(e)
+		writeError(w, status, code, e.Error())
+		return
+	}
+
+	hits := make([]SimilarHit, len(res.Hits))
+	for i, h := range res.Hits {
+		hits[i] = SimilarHit{Path: h.Path, Score: h.Score}
+	}
+
+	k := q.K
+	if k == 0 {
+		k = searcher.DefaultSimilarK
+	}
+
+	writeJSON(w, http.StatusOK, Envelope{
+		Results: hits,
+		Meta:    &Meta{Total: len(hits), Limit: min(k, searcher.MaxLimit), Generation: res.Generation},
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSimilarHandler(t *testing.T) {
+	srch := newTestSearcher(t, map[string]string{
+		"a.txt":     "Hello World",
+		"dir/b.txt": "Hello World again",
+		"c.txt":     "nothing here",
+	})
+	srch.Scan()
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		wantPaths  []string
+		wantCode   string
+	}{
+		{name: "Ok", url: "/files/similar?path=a.txt", wantStatus: http.StatusOK, wantPaths: []string{"dir/b.txt"}},
+		{name: "Filter", url: "/files/similar?path=a.txt&path_prefix=c", wantStatus: http.StatusOK},
+		{name: "E: no path", url: "/files/similar", wantStatus: http.StatusBadRequest, wantCode: codeBadRequest},
+		{name: "E: k", url: "/files/similar?path=a.txt&k=x", wantStatus: http.StatusBadRequest, wantCode: codeBadRequest},
+		{name: "E: negative k", url: "/files/similar?path=a.txt&k=-1", wantStatus: http.StatusBadRequest, wantCode: codeBadRequest},
+		{name: "E: unknown", url: "/files/similar?path=nope.txt", wantStatus: http.StatusNotFound, wantCode: codeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			similarHandler(rec, httptest.NewRequest(http.MethodGet, tt.url, nil), srch)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			var env struct {
+				Results []SimilarHit `json:"results"`
+				Errors  []ErrorItem  `json:"errors"`
+			}
+
+			if e := json.Unmarshal(rec.Body.Bytes(), &env); e != nil {
+				t.Fatalf("decoding %q: %v", rec.Body.String(), e)
+			}
+
+			if len(env.Results) != len(tt.wantPaths) {
+				t.Fatalf("results = %+v, want %v", env.Results, tt.wantPaths)
+			}
+
+			for i, h := range env.Results {
+				if h.Path != tt.wantPaths[i] || h.Score <= 0 {
+					t.Errorf("results = %+v, want %v", env.Results, tt.wantPaths)
+				}
+			}
+
+			if tt.wantCode != "" && (len(env.Errors) != 1 || env.Errors[0].Code != tt.wantCode) {
+				t.Errorf("errors = %v, want code %q", env.Errors, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"word-search-in-files/internal/args"
+	"word-search-in-files/pkg/searcher"
+)
+
+// snapshotMain runs `snapshot export|import|diff` and returns the exit code
+func snapshotMain(arguments []string, stdout, stderr io.Writer) int {
+	a, e := args.SnapshotParse(arguments, stderr)
+	if errors.Is(e, flag.ErrHelp) {
+		return exitOK
+	}
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	code := exitOK
+
+	switch a.Command {
+	case "export":
+		e = snapshotExport(a, stdout)
+	case "import":
+		e = snapshotImport(a.Files[0], stdout)
+	case "diff":
+		var differs bool
+		differs, e = snapshotDiff(a.Files[0], a.Files[1], stdout)
+		if differs {
+			code = exitDiffers
+		}
+	}
+
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	return code
+}
+
+// snapshotExport scans the directory and writes the snapshot to the file or stdout
+func snapshotExport(a *args.SnapshotArgs, stdout io.Writer) error {
+	srch, e := newSearcher(a.IndexArgs)
+	if e != nil {
+		return e
+	}
+
+	if e = srch.Scan(); e != nil {
+		return e
+	}
+
+	if a.Output != "" {
+		return writeSnapshotFile(srch, a.Output)
+	}
+
+	w := bufio.NewWriter(stdout)
+	if e = srch.Export(w); e != nil {
+		return e
+	}
+
+	return w.Flush()
+}
+
+func writeSnapshotFile(srch *searcher.Searcher, path string) (e error) {
+	f, e := os.Create(path)
+	if e != nil {
+		return e
+	}
+	defer func() {
+		if ce := f.Close(); e == nil {
+			e = ce
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	if e = srch.Export(w); e != nil {
+		return e
+	}
+
+	return w.Flush()
+}
+
+// snapshotImport loads the snapshot into a Searcher and prints what it holds
+func snapshotImport(path string, stdout io.Writer) error {
+	snap, e := readSnapshotFile(path)
+	if e != nil {
+		return e
+	}
+
+	srch, e := searcher.NewSearcher("", searcher.WithFS(nil))
+	if e != nil {
+		return e
+	}
+
+	srch.Load(snap)
+
+	stats, e := srch.Stats(10, nil)
+	if e != nil {
+		return e
+	}
+
+	fmt.Fprintf(stdout, "snapshot:    %s\n", path)
+	fmt.Fprintf(stdout, "created:     %s\n", snap.Created.Format("2006-01-02 15:04:05Z07:00"))
+	fmt.Fprintf(stdout, "granularity: %s\n", snap.Granularity)
+	fmt.Fprintf(stdout, "files:       %d\n", stats.Files)
+	fmt.Fprintf(stdout, "terms:       %d\n", stats.Vocabulary)
+	fmt.Fprintf(stdout, "tokens:      %d\n", stats.Tokens)
+	fmt.Fprintf(stdout, "stop words:  %d\n", stats.StopWords)
+	fmt.Fprintf(stdout, "errors:      %d\n", len(snap.Errors))
+
+	for _, e := range snap.Errors {
+		fmt.Fprintf(stdout, "  %s\n", e)
+	}
+
+	if len(stats.TopTerms) > 0 {
+		fmt.Fprintln(stdout, "top terms:")
+		for _, t := range stats.TopTerms {
+			fmt.Fprintf(stdout, "  %-20s %d\n", t.Term, t.Docs)
+		}
+	}
+
+	return nil
+}
+
+// snapshotDiff prints the files and the terms added (+), removed (-) and changed (~)
+// between the snapshots
+func snapshotDiff(from, to string, stdout io.Writer) (bool, error) {
+	a, e := readSnapshotFile(from)
+	if e != nil {
+		return false, e
+	}
+
+	b, e := readSnapshotFile(to)
+	if e != nil {
+		return false, e
+	}
+
+	d := searcher.DiffSnapshots(a, b)
+
+	w := bufio.NewWriter(stdout)
+
+	for _, path := range d.AddedFiles {
+		fmt.Fprintf(w, "+ file %s\n", path)
+	}
+	for _, path := range d.RemovedFiles {
+		fmt.Fprintf(w, "- file %s\n", path)
+	}
+	for _, path := range d.ChangedFiles {
+		fmt.Fprintf(w, "~ file %s\n", path)
+	}
+	for _, term := range d.AddedTerms {
+		fmt.Fprintf(w, "+ term %s\n", term)
+	}
+	for _, term := range d.RemovedTerms {
+		fmt.Fprintf(w, "- term %s\n", term)
+	}
+	for _, c := range d.ChangedTerms {
+		fmt.Fprintf(w, "~ term %s %d -> %d files\n", c.Term, c.DocsFrom, c.DocsTo)
+	}
+
+	return !d.Empty(), w.Flush()
+}
+
+func readSnapshotFile(path string) (*searcher.Snapshot, error) {
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, e
+	}
+	defer f.Close()
+
+	snap, e := searcher.ReadSnapshot(bufio.NewReader(f))
+	if e != nil {
+		return nil, fmt.Errorf("%s: %w", path, e)
+	}
+
+	return snap, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"word-search-in-files/pkg/searcher"
+)
+
+type CorpusStats struct {
+	Generation   uint64         `json:"generation"`
+	Files        int            `json:"files"`
+	Vocabulary   int            `json:"vocabulary"`
+	Tokens       int            `json:"tokens"`
+	AvgDocLength float64        `json:"avg_doc_length"`
+	TopTerms     []TermHit      `json:"top_terms"`
+	Extensions   map[string]int `json:"extensions"`
+	Languages    map[string]int `json:"languages"`
+	StopWords    int            `json:"stop_words"`
+}
+
+// statsHandler replies with the statistics of the files of the index the caller may
+// see, `top` sets the number of the most frequent terms
+func statsHandler(w http.ResponseWriter, r *http.Request, srch *searcher.Searcher) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	if !srch.Ready() {
+		notReady(w)
+		return
+	}
+
+	top := 0
+	if v := r.URL.Query().Get("top"); v != "" {
+		var e error
+		if top, e = strconv.Atoi(v); e != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "invalid top "+strconv.Quote(v))
+			return
+		}
+	}
+
+	var f searcher.Filter
+	if !authorize(w, r, &f) {
+		return
+	}
+
+	st, e := srch.Stats(top, f.Subtrees)
+	if e != nil {
+		status, code := queryError(e)
+		writeError(w, status, code, e.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Envelope{
+		Results: []CorpusStats{corpusStats(st)},
+		Meta:    &Meta{Total: 1, Limit: 1, Generation: st.Generation},
+	})
+}
+
+func corpusStats(st searcher.CorpusStats) CorpusStats {
+	res := CorpusStats{
+		Generation:   st.Generation,
+		Files:        st.Files,
+		Vocabulary:   st.Vocabulary,
+		Tokens:       st.Tokens,
+		AvgDocLength: st.AvgDocLength,
+		TopTerms:     make([]TermHit, len(st.TopTerms)),
+		Extensions:   st.Extensions,
+		Languages:    st.Languages,
+		StopWords:    st.StopWords,
+	}
+
+	for i, t := range st.TopTerms {
+		res.TopTerms[i] = TermHit{Term: t.Term, Docs: t.Docs}
+	}
+
+	return res
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"word-search-in-files/pkg/searcher"
+)
+
+const (
+	streamNDJSON = "ndjson"
+	streamSSE    = "sse"
+)
+
+// Results are flushed to the client in batches of this size, the first one at once
+const streamFlushEvery = 64
+
+// streamFormat returns the streaming format requested by the `stream` parameter or,
+// when it is missing, by the Accept header. Empty means a regular JSON reply
+func streamFormat(r *http.Request) (string, error) {
+	switch v := r.URL.Query().Get("stream"); v {
+	case streamNDJSON, streamSSE:
+		return v, nil
+	case "":
+	default:
+		return "", fmt.Errorf("invalid stream %q, expected %q or %q", v, streamNDJSON, streamSSE)
+	}
+
+	accept := r.Header.Get("Accept")
+
+	switch {
+	case strings.Contains(accept, "application/x-ndjson"):
+		return streamNDJSON, nil
+	case strings.Contains(accept, "text/event-stream"):
+		return streamSSE, nil
+	}
+
+	return "", nil
+}
+
+// streamEvent writes a single event: an NDJSON line {"type": ..., "<type>": ...}
+// or a Server-Sent Event with the JSON data
+func streamEvent(w io.Writer, format, typ string, v any) error {
+	data, e := json.Marshal(v)
+	if e != nil {
+		return e
+	}
+
+	if format == streamSSE {
+		_, e = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typ, data)
+		return e
+	}
+
+	_, e = fmt.Fprintf(w, "{\"type\":%q,%q:%s}\n", typ, typ, data)
+	return e
+}
+
+// streamSearch writes an event per hit as the searcher matches it, see
+// searcher.Stream, and finishes with the errors of the scan and the meta event. Invalid
+// queries are still replied with the regular envelope as nothing has been written yet
+func streamSearch(w http.ResponseWriter, r *http.Request, srch *searcher.Searcher, query searcher.Query, format string) {
+	rc := http.NewResponseController(w)
+	started := false
+
+	start := func() {
+		if format == streamSSE {
+			w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+			w.Header().Set("X-Accel-Buffering", "no")
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		started = true
+	}
+
+	n := 0
+
+	res, e := srch.Stream(r.Context(), query, func(hit searcher.Hit) error {
+		if !started {
+			start()
+		}
+
+		if e := streamEvent(w, format, "result", searchHit(hit)); e != nil {
+			return e
+		}
+
+		if n++; n == 1 || n%streamFlushEvery == 0 {
+			return rc.Flush()
+		}
+
+		return nil
+	})
+
+	if !started {
+		if e != nil && res == nil {
+			status, code := queryError(e)
+			writeError(w, status, code, e.Error())
+			return
+		}
+
+		start()
+	}
+
+	// The client has gone, nobody to report to
+	if errors.Is(e, context.Canceled) || errors.Is(e, context.DeadlineExceeded) || r.Context().Err() != nil {
+		return
+	}
+
+	if e != nil {
+		_ = streamEvent(w, format, "error", ErrorItem{Code: codeInternal, Message: e.Error()})
+		_ = rc.Flush()
+		return
+	}
+
+	for _, item := range scanErrors(res.Errors) {
+		_ = streamEvent(w, format, "error", item)
+	}
+
+	_ = streamEvent(w, format, "meta", Meta{
+		Total:      res.Total,
+		Limit:      res.Limit,
+		NextCursor: res.NextCursor,
+		Generation: res.Generation,
+		DidYouMean: corrections(res.Corrections),
+		StopWords:  res.StopWords,
+		Expansions: expansions(res.Expansions),
+	})
+	_ = rc.Flush()
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"word-search-in-files/pkg/searcher"
+)
+
+const (
+	defaultPollTimeout = 30 * time.Second
+	maxPollTimeout     = 2 * time.Minute
+
+	// Interval of the comments keeping idle event streams open through proxies
+	sseHeartbeat = 15 * time.Second
+)
+
+type SavedQuery struct {
+	ID      string      `json:"id"`
+	Words   []string    `json:"words"`
+	Filter  QueryFilter `json:"filter"`
+	Created time.Time   `json:"created"`
+	Matches int         `json:"matches"`
+}
+
+type QueryFilter struct {
+	PathPrefix     string     `json:"path_prefix,omitempty"`
+	Ext            []string   `json:"ext,omitempty"`
+	Lang           []string   `json:"lang,omitempty"`
+	ModifiedAfter  *time.Time `json:"modified_after,omitempty"`
+	ModifiedBefore *time.Time `json:"modified_before,omitempty"`
+	MinSize        int64      `json:"min_size,omitempty"`
+	MaxSize        int64      `json:"max_size,omitempty"`
+}
+
+type Change struct {
+	QueryID    string    `json:"query_id"`
+	Generation uint64    `json:"generation"`
+	Time       time.Time `json:"time"`
+	Added      []string  `json:"added"`
+	Removed    []string  `json:"removed"`
+}
+
+// filter is the inverse of savedQuery, the zero values do not restrict
+func (f QueryFilter) filter() searcher.Filter {
+	res := searcher.Filter{
+		PathPrefix: f.PathPrefix,
+		Ext:        f.Ext,
+		Lang:       f.Lang,
+		MinSize:    f.MinSize,
+		MaxSize:    f.MaxSize,
+	}
+
+	if f.ModifiedAfter != nil {
+		res.ModifiedAfter = *f.ModifiedAfter
+	}
+
+	if f.ModifiedBefore != nil {
+		res.ModifiedBefore = *f.ModifiedBefore
+	}
+
+	return res
+}
+
+func savedQuery(q searcher.SavedQuery) SavedQuery {
+	f := QueryFilter{
+		PathPrefix: q.Filter.PathPrefix,
+		Ext:        q.Filter.Ext,
+		Lang:       q.Filter.Lang,
+		MinSize:    q.Filter.MinSize,
+		MaxSize:    q.Filter.MaxSize,
+	}
+
+	if !q.Filter.ModifiedAfter.IsZero() {
+		f.ModifiedAfter = &q.Filter.ModifiedAfter
+	}
+
+	if !q.Filter.ModifiedBefore.IsZero() {
+		f.ModifiedBefore = &q.Filter.ModifiedBefore
+	}
+
+	return SavedQuery{ID: q.ID, Words: q.Words, Filter: f, Created: q.Created, Matches: q.Matches}
+}
+
+func change(c searcher.Change) Change {
+	res := Change{QueryID: c.QueryID, Generation: c.Generation, Time: c.Time, Added: c.Added, Removed: c.Removed}
+
+	if res.Added == nil {
+		res.Added = []string{}
+	}
+
+	if res.Removed == nil {
+		res.Removed = []string{}
+	}
+
+	return res
+}
+
+// subscriptionsHandler lists the saved queries of the caller (GET) or saves a new one
+// (POST) from the same parameters as /files/search. The saved queries of the others
+// do not exist for the caller
+func subscriptionsHandler(w http.ResponseWriter, r *http.Request, srch *searcher.Searcher) {
+	switch r.Method {
+	case http.MethodGet:
+		queries := srch.Watched(ownerOf(r))
+
+		res := make([]SavedQuery, len(queries))
+		for i, q := range queries {
+			res[i] = savedQuery(q)
+		}
+
+		writeJSON(w, http.StatusOK, Envelope{Results: res, Meta: &Meta{Total: len(res), Limit: len(res)}})
+
+	case http.MethodPost:
+		query, e := parseSearchQuery(r)
+		if e != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, e.Error())
+			return
+		}
+
+		// The changes only report the files the caller may see
+		if !authorize(w, r, &query.Filter) {
+			return
+		}
+
+		saved, e := srch.Watch(query, ownerOf(r))
+		if e != nil {
+			status, code := queryError(e)
+			writeError(w, status, code, e.Error())
+			return
+		}
+
+		w.Header().Set("Location", "/subscriptions/"+saved.ID)
+		writeJSON(w, http.StatusCreated, Envelope{Results: []SavedQuery{savedQuery(saved)}})
+
+	default:
+		methodNotAllowed(w, http.MethodGet+", "+http.MethodPost)
+	}
+}
+
+// subscriptionHandler serves /subscriptions/{id} (DELETE), /subscriptions/{id}/changes
+// (long-poll) and /subscriptions/{id}/events (Server-Sent Events)
+func subscriptionHandler(w http.ResponseWriter, r *http.Request, srch *searcher.Searcher) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/subscriptions/"), "/")
+
+	switch action {
+	case "":
+		if r.Method != http.MethodDelete {
+			methodNotAllowed(w, http.MethodDelete)
+			return
+		}
+
+		if e := srch.Unwatch(id, ownerOf(r)); e != nil {
+			writeError(w, http.StatusNotFound, codeNotFound, e.Error())
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	case "changes":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		pollChanges(w, r, srch, id)
+
+	case "events":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		streamChanges(w, r, srch, id)
+
+	default:
+		writeError(w, http.StatusNotFound, codeNotFound, "unknown resource "+strconv.Quote(r.URL.Path))
+	}
+}
+
+// pollChanges replies with the changes after the `since` generation, waiting up to
+// `timeout` for the next scan when there are none yet
+func pollChanges(w http.ResponseWriter, r *http.Request, srch *searcher.Searcher, id string) {
+	values := r.URL.Query()
+
+	since, e := parseGeneration(values.Get("since"))
+	if e != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, e.Error())
+		return
+	}
+
+	timeout := defaultPollTimeout
+	if v := values.Get("timeout"); v != "" {
+		if timeout, e = time.ParseDuration(v); e != nil || timeout < 0 {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "invalid timeout "+strconv.Quote(v))
+			return
+		}
+	}
+	timeout = min(timeout, maxPollTimeout)
+
+	// Subscribe first, so a change published in between is not missed
+	sub, e := srch.Subscribe(id, ownerOf(r))
+	if e != nil {
+		writeError(w, http.StatusNotFound, codeNotFound, e.Error())
+		return
+	}
+	defer sub.Close()
+
+	changes, e := srch.Changes(id, ownerOf(r), since)
+
+	if e == nil && len(changes) == 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-sub.C:
+			changes, e = srch.Changes(id, ownerOf(r), since)
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if e != nil {
+		writeError(w, http.StatusNotFound, codeNotFound, e.Error())
+		return
+	}
+
+	res := make([]Change, len(changes))
+	for i, c := range changes {
+		res[i] = change(c)
+	}
+
+	writeJSON(w, http.StatusOK, Envelope{Results: res, Meta: &Meta{Total: len(res), Limit: len(res)}})
+}
+
+// streamChanges sends the changes as Server-Sent Events with the generation as the
+// event id, a reconnecting client gets the missed ones after its Last-Event-ID
+func streamChanges(w http.ResponseWriter, r *http.Request, srch *searcher.Searcher, id string) {
+	since := r.Header.Get("Last-Event-ID")
+	if since == "" {
+		since = r.URL.Query().Get("since")
+	}
+
+	generation, e := parseGeneration(since)
+	if e != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, e.Error())
+		return
+	}
+
+	sub, e := srch.Subscribe(id, ownerOf(r))
+	if e != nil {
+		writeError(w, http.StatusNotFound, codeNotFound, e.Error())
+		return
+	}
+	defer sub.Close()
+
+	missed, e := srch.Changes(id, ownerOf(r), generation)
+	if e != nil {
+		writeError(w, http.StatusNotFound, codeNotFound, e.Error())
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(c searcher.Change) error {
+		if c.Generation <= generation {
+			return nil
+		}
+		generation = c.Generation
+
+		data, e := json.Marshal(change(c))
+		if e != nil {
+			return e
+		}
+
+		if _, e = fmt.Fprintf(w, "id: %d\nevent: change\ndata: %s\n\n", c.Generation, data); e != nil {
+			return e
+		}
+
+		return rc.Flush()
+	}
+
+	for _, c := range missed {
+		if send(c) != nil {
+			return
+		}
+	}
+
+	if rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case c, ok := <-sub.C:
+			if !ok {
+				// The saved query was deleted
+				_, _ = fmt.Fprint(w, "event: deleted\ndata: {}\n\n")
+				_ = rc.Flush()
+				return
+			}
+
+			if send(c) != nil {
+				return
+			}
+
+		case <-heartbeat.C:
+			if _, e := fmt.Fprint(w, ": heartbeat\n\n"); e != nil || rc.Flush() != nil {
+				return
+			}
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func parseGeneration(v string) (uint64, error) {
+	if v == "" {
+		return 0, nil
+	}
+
+	generation, e := strconv.ParseUint(v, 10, 64)
+	if e != nil {
+		return 0, errors.New("invalid generation " + strconv.Quote(v))
+	}
+
+	return generation, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"word-search-in-files/pkg/searcher"
+)
+
+type TermHit struct {
+	Term string `json:"term"`
+	Docs int    `json:"docs"`
+}
+
+type Correction struct {
+	Word        string   `json:"word"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// suggestHandler replies with the terms starting with `prefix`, the most frequent first,
+// of the files the caller may see
+func suggestHandler(w http.ResponseWriter, r *http.Request, srch *searcher.Searcher) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	if !srch.Ready() {
+		notReady(w)
+		return
+	}
+
+	values := r.URL.Query()
+
+	limit := 0
+	if v := values.Get("limit"); v != "" {
+		var e error
+		if limit, e = strconv.Atoi(v); e != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "invalid limit "+strconv.Quote(v))
+			return
+		}
+	}
+
+	var f searcher.Filter
+	if !authorize(w, r, &f) {
+		return
+	}
+
+	terms, total, e := srch.Suggest(values.Get("prefix"), limit, f.Subtrees)
+	if e != nil {
+		status, code := queryError(e)
+		writeError(w, status, code, e.Error())
+		return
+	}
+
+	hits := make([]TermHit, len(terms))
+	for i, t := range terms {
+		hits[i] = TermHit{Term: t.Term, Docs: t.Docs}
+	}
+
+	if limit == 0 {
+		limit = searcher.DefaultSuggestLimit
+	}
+
+	writeJSON(w, http.StatusOK, Envelope{
+		Results: hits,
+		Meta:    &Meta{Total: total, Limit: min(limit, searcher.MaxSuggestLimit)},
+	})
+}
+
+func corrections(cs []searcher.Correction) []Correction {
+	if len(cs) == 0 {
+		return nil
+	}
+
+	res := make([]Correction, len(cs))
+	for i, c := range cs {
+		res[i] = Correction{Word: c.Word, Suggestions: c.Candidates}
+	}
+
+	return res
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+	"word-search-in-files/pkg/searcher"
+)
+
+// Expansion is a query word with the synonyms it was expanded to
+type Expansion struct {
+	Word     string   `json:"word"`
+	Synonyms []string `json:"synonyms"`
+}
+
+func expansions(es []searcher.Expansion) []Expansion {
+	if len(es) == 0 {
+		return nil
+	}
+
+	res := make([]Expansion, len(es))
+	for i, e := range es {
+		res[i] = Expansion{Word: e.Word, Synonyms: e.Synonyms}
+	}
+
+	return res
+}
+
+// reloadSynonyms checks the synonyms file every interval and gives the searcher the
+// new rules when it changes. A file that does not parse leaves the previous rules
+func reloadSynonyms(ctx context.Context, file string, interval time.Duration, srch *searcher.Searcher) {
+	var modified time.Time
+	var size int64
+
+	if info, e := os.Stat(file); e == nil {
+		modified, size = info.ModTime(), info.Size()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, e := os.Stat(file)
+		if e != nil {
+			log.Printf("synonyms: %s", e)
+			continue
+		}
+
+		if info.ModTime().Equal(modified) && info.Size() == size {
+			continue
+		}
+		modified, size = info.ModTime(), info.Size()
+
+		syn, e := searcher.LoadSynonyms(file)
+		if e != nil {
+			log.Printf("synonyms: keeping the previous rules: %s", e)
+			continue
+		}
+
+		srch.SetSynonyms(syn)
+		log.Printf("synonyms: reloaded %d rules from %s", syn.Rules(), file)
+	}
+}
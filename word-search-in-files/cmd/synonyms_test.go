@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadSynonyms(t *testing.T) {
+	srch := newTestSearcher(t, map[string]string{
+		"a.txt": "пёс лает",
+		"b.txt": "собака спит",
+	})
+	srch.Scan()
+
+	file := filepath.Join(t.TempDir(), "synonyms.txt")
+	if e := os.WriteFile(file, []byte("# none yet\n"), 0o644); e != nil {
+		t.Fatal(e)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go reloadSynonyms(ctx, file, 10*time.Millisecond, srch)
+
+	// The reply of a client revalidating the ETag of the first one
+	var etag string
+
+	search := func() (total int, exps []Expansion) {
+		req := httptest.NewRequest(http.MethodGet, "/files/search?word=пёс", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+
+		rec := httptest.NewRecorder()
+		searchHandler(rec, req, srch)
+
+		if rec.Code == http.StatusNotModified {
+			return 1, nil
+		}
+
+		var env struct {
+			Meta Meta `json:"meta"`
+		}
+		if e := json.Unmarshal(rec.Body.Bytes(), &env); e != nil {
+			t.Fatalf("decoding %q: %v", rec.Body.String(), e)
+		}
+
+		if etag == "" {
+			etag = rec.Header().Get("ETag")
+		} else if rec.Header().Get("ETag") == etag {
+			t.Errorf("ETag = %s, the one of the previous rules", etag)
+		}
+
+		return env.Meta.Total, env.Meta.Expansions
+	}
+
+	if total, _ := search(); total != 1 {
+		t.Fatalf("total = %d before the synonyms, want 1", total)
+	}
+
+	// A broken file keeps the previous rules
+	if e := os.WriteFile(file, []byte("пёс\n"), 0o644); e != nil {
+		t.Fatal(e)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if total, _ := search(); total != 1 {
+		t.Fatalf("total = %d with a broken file, want 1", total)
+	}
+
+	if e := os.WriteFile(file, []byte("пёс, собака\n"), 0o644); e != nil {
+		t.Fatal(e)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		total, exps := search()
+		if total == 2 {
+			if len(exps) != 1 || exps[0].Word != "пёс" || len(exps[0].Synonyms) != 1 || exps[0].Synonyms[0] != "собака" {
+				t.Errorf("expansions = %v", exps)
+			}
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("total = %d, the synonyms were not reloaded", total)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+	"word-search-in-files/internal/args"
+	"word-search-in-files/pkg/certs"
+)
+
+// Validity of the certificate of -tls-self-signed
+const selfSignedValidity = 7 * 24 * time.Hour
+
+// serverTLSConfig returns the HTTPS configuration of the flags: the certificate of the
+// files, reloaded when they change, or an ephemeral self-signed one
+func serverTLSConfig(a *args.Args) (*tls.Config, error) {
+	clientAuth := tls.VerifyClientCertIfGiven
+	if a.TLSClientAuth == "require" {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if !a.TLSSelfSigned {
+		r, e := certs.NewReloader(a.TLSCert, a.TLSKey, a.TLSClientCA)
+		if e != nil {
+			return nil, e
+		}
+
+		r.OnReload = func(e error) {
+			if e != nil {
+				log.Printf("reloading the certificate: %s, still serving the previous one", e)
+				return
+			}
+			log.Println("certificate reloaded")
+		}
+
+		return r.TLSConfig(clientAuth), nil
+	}
+
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if host, _, e := net.SplitHostPort(a.HttpAddr); e == nil && host != "" && host != "localhost" && net.ParseIP(host) == nil {
+		hosts = append(hosts, host)
+	}
+
+	cert, e := certs.SelfSigned(hosts, selfSignedValidity)
+	if e != nil {
+		return nil, e
+	}
+
+	var pool *x509.CertPool
+	if a.TLSClientCA != "" {
+		if pool, e = certs.LoadCertPool(a.TLSClientCA); e != nil {
+			return nil, e
+		}
+	}
+
+	log.Printf("serving a self-signed certificate of %v, SHA-256 %s", hosts, certs.Fingerprint(cert))
+
+	return certs.Config(&cert, pool, clientAuth), nil
+}
+
+// hsts tells the browsers to only use HTTPS for the host during max-age
+func hsts(maxAge time.Duration, next http.Handler) http.Handler {
+	value := "max-age=" + strconv.FormatInt(int64(maxAge.Seconds()), 10)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			w.Header().Set("Strict-Transport-Security", value)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+	"word-search-in-files/internal/args"
+)
+
+func TestServerTLS(t *testing.T) {
+	cfg, e := serverTLSConfig(&args.Args{TLSSelfSigned: true, TLSClientAuth: "request"})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	ln, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	server := &http.Server{
+		Handler:   hsts(365*24*time.Hour, http.HandlerFunc(healthHandler)),
+		TLSConfig: cfg,
+	}
+	go server.ServeTLS(ln, "", "")
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cfg.Certificates[0].Leaf)
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		ForceAttemptHTTP2: true,
+	}}
+
+	resp, e := client.Get("https://" + ln.Addr().String() + "/healthz")
+	if e != nil {
+		t.Fatal(e)
+	}
+	resp.Body.Close()
+
+	if resp.ProtoMajor != 2 {
+		t.Errorf("protocol = %s, want HTTP/2", resp.Proto)
+	}
+
+	if got := resp.Header.Get("Strict-Transport-Security"); got != "max-age=31536000" {
+		t.Errorf("Strict-Transport-Security = %q", got)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+	"word-search-in-files/internal/args"
+	"word-search-in-files/pkg/auth"
+)
+
+// tokenMain runs `token`: prints a token signed with the first secret of the access
+// configuration
+func tokenMain(arguments []string, stdout, stderr io.Writer) int {
+	a, e := args.TokenParse(arguments, stderr)
+	if errors.Is(e, flag.ErrHelp) {
+		return exitOK
+	}
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	cfg, e := auth.LoadConfig(a.AuthConfig)
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	tokens, e := cfg.Tokens()
+	if e != nil {
+		fmt.Fprintln(stderr, e)
+		return exitError
+	}
+
+	if _, ok := tokens.Principals[a.Subject]; !ok && len(a.Paths) == 0 {
+		fmt.Fprintf(stderr, "token: unknown principal %q, give the -paths of the token\n", a.Subject)
+		return exitError
+	}
+
+	token, e := tokens.Sign(auth.Claims{
+		Subject: a.Subject,
+		Paths:   a.Paths,
+		Expires: time.Now().Add(a.TTL).Unix(),
+	})
+	if e != nil {
+		fmt.Fprintln(stderr, "token:", e)
+		return exitError
+	}
+
+	fmt.Fprintln(stdout, token)
+
+	return exitOK
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"word-search-in-files/pkg/searcher"
+)
+
+// FileView is the text of a range of a file with the byte offsets of the words of the
+// query
+type FileView struct {
+	Path string `json:"path"`
+	Lang string `json:"lang,omitempty"`
+	Size int64  `json:"size"`
+	// The range of the file, End excluded
+	Start int64      `json:"start"`
+	End   int64      `json:"end"`
+	Text  string     `json:"text"`
+	Marks []ViewMark `json:"marks"`
+}
+
+// ViewMark is a word of the query, the offsets are the ones of the file
+type ViewMark struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// viewHandler replies with the file of `path` and the words of `q` marked: as HTML,
+// escaped with <mark> around the words, or with `format=json` as the text and the
+// offsets of the words. A single Range of bytes of the file is served as 206, the
+// body is then the rendering of the range: a fragment of HTML, or the range in JSON
+func viewHandler(w http.ResponseWriter, r *http.Request, srch *searcher.Searcher) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	if !srch.Ready() {
+		notReady(w)
+		return
+	}
+
+	values := r.URL.Query()
+
+	path := values.Get("path")
+	if path == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "path parameter is required")
+		return
+	}
+
+	format := values.Get("format")
+	if format == "" {
+		format = "html"
+	}
+
+	if format != "html" && format != "json" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("unknown format %q, expected html or json", format))
+		return
+	}
+
+	f := searcher.Filter{PathPrefix: path}
+	if !authorize(w, r, &f) {
+		return
+	}
+
+	v, e := srch.View(searcher.ViewQuery{Path: path, Words: values["q"], Subtrees: f.Subtrees})
+	if errors.Is(e, searcher.ErrNoFS) {
+		writeError(w, http.StatusNotFound, codeNotFound, "the files of the index can not be read")
+		return
+	}
+	if e != nil {
+		status, code := queryError(e)
+		writeError(w, status, code, e.Error())
+		return
+	}
+	defer v.Close()
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Last-Modified", v.Modified.UTC().Format(http.TimeFormat))
+
+	start, end, partial := int64(0), v.Size, false
+
+	if spec := r.Header.Get("Range"); spec != "" && ifRange(r, v) {
+		var ok bool
+		if start, end, ok = parseRange(spec, v.Size); !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", v.Size))
+			writeError(w, http.StatusRequestedRangeNotSatisfiable, codeBadRequest, fmt.Sprintf("range %q is not satisfiable for %d bytes", spec, v.Size))
+			return
+		}
+
+		partial = start != 0 || end != v.Size
+	}
+
+	status := http.StatusOK
+	if partial {
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, v.Size))
+	}
+
+	if format == "json" {
+		fv := FileView{Path: v.Path, Lang: v.Lang, Size: v.Size, Start: start, End: end, Marks: []ViewMark{}}
+
+		var text strings.Builder
+		e = v.Read(start, end, func(offset int64, piece []byte, marked bool) error {
+			if marked {
+				fv.Marks = append(fv.Marks, ViewMark{Start: offset, End: offset + int64(len(piece))})
+			}
+			text.Write(piece)
+			return nil
+		})
+		if e != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, e.Error())
+			return
+		}
+
+		fv.Text = text.String()
+		writeJSON(w, status, Envelope{Results: []FileView{fv}})
+		return
+	}
+
+	// The content is never interpreted, even by the browsers sniffing it
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	if !partial {
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<pre>", html.EscapeString(v.Path))
+	}
+
+	e = v.Read(start, end, func(_ int64, piece []byte, marked bool) error {
+		text := html.EscapeString(string(piece))
+		if marked {
+			text = "<mark>" + text + "</mark>"
+		}
+
+		_, e := w.Write([]byte(text))
+		return e
+	})
+	if e != nil {
+		// The status is sent already, the client sees a short body
+		log.Printf("view %s: %s", v.Path, e)
+		return
+	}
+
+	if !partial {
+		fmt.Fprint(w, "</pre>\n")
+	}
+}
+
+// ifRange reports whether the Range of the request applies: without If-Range, or with
+// the current Last-Modified of the file
+func ifRange(r *http.Request, v *searcher.View) bool {
+	cond := r.Header.Get("If-Range")
+	return cond == "" || cond == v.Modified.UTC().Format(http.TimeFormat)
+}
+
+// parseRange reads a single range of bytes, "bytes=a-b", "bytes=a-" or "bytes=-n",
+// and returns its bounds, end excluded. ok is false when it is not satisfiable. The
+// malformed and the multiple ranges are served as the whole file
+func parseRange(spec string, size int64) (start, end int64, ok bool) {
+	spec, found := strings.CutPrefix(spec, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, size, true
+	}
+
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, size, true
+	}
+
+	if first == "" {
+		n, e := strconv.ParseInt(last, 10, 64)
+		if e != nil || n < 0 {
+			return 0, size, true
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false
+		}
+
+		return max(0, size-n), size, true
+	}
+
+	start, e := strconv.ParseInt(first, 10, 64)
+	if e != nil || start < 0 {
+		return 0, size, true
+	}
+	if start >= size {
+		return 0, 0, false
+	}
+
+	end = size
+	if last != "" {
+		n, e := strconv.ParseInt(last, 10, 64)
+		if e != nil || n < start {
+			return 0, size, true
+		}
+		end = min(n+1, size)
+	}
+
+	return start, end, true
+}
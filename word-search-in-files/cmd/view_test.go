@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestViewHandler(t *testing.T) {
+	srch := newTestSearcher(t, map[string]string{
+		"a.txt":     "Hello <World>, world",
+		"dir/b.txt": "World",
+	})
+	srch.Scan()
+
+	tests := []struct {
+		name             string
+		url              string
+		rangeSpec        string
+		wantStatus       int
+		wantBody         string
+		wantContentRange string
+	}{
+		{name: "HTML", url: "/files/view?path=a.txt&q=World", wantStatus: http.StatusOK, wantBody: "<pre>Hello &lt;<mark>World</mark>&gt;, world</pre>"},
+		{name: "Range", url: "/files/view?path=a.txt&q=World", rangeSpec: "bytes=6-9", wantStatus: http.StatusPartialContent, wantBody: "&lt;<mark>Wor</mark>", wantContentRange: "bytes 6-9/20"},
+		{name: "Suffix range", url: "/files/view?path=a.txt", rangeSpec: "bytes=-5", wantStatus: http.StatusPartialContent, wantBody: "world", wantContentRange: "bytes 15-19/20"},
+		{name: "Multiple ranges", url: "/files/view?path=dir/b.txt", rangeSpec: "bytes=0-1,3-4", wantStatus: http.StatusOK, wantBody: "<pre>World</pre>"},
+		{name: "E: range", url: "/files/view?path=a.txt", rangeSpec: "bytes=20-", wantStatus: http.StatusRequestedRangeNotSatisfiable, wantContentRange: "bytes */20"},
+		{name: "E: no path", url: "/files/view", wantStatus: http.StatusBadRequest},
+		{name: "E: format", url: "/files/view?path=a.txt&format=xml", wantStatus: http.StatusBadRequest},
+		{name: "E: traversal", url: "/files/view?path=../a.txt", wantStatus: http.StatusNotFound},
+		{name: "E: unknown", url: "/files/view?path=dir", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.rangeSpec != "" {
+				req.Header.Set("Range", tt.rangeSpec)
+			}
+
+			rec := httptest.NewRecorder()
+			viewHandler(rec, req, srch)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d, body %q", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+
+			if cr := rec.Header().Get("Content-Range"); cr != tt.wantContentRange {
+				t.Errorf("Content-Range = %q, want %q", cr, tt.wantContentRange)
+			}
+		})
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		viewHandler(rec, httptest.NewRequest(http.MethodGet, "/files/view?path=a.txt&q=World&format=json", nil), srch)
+
+		var env struct {
+			Results []FileView `json:"results"`
+		}
+		if e := json.Unmarshal(rec.Body.Bytes(), &env); e != nil {
+			t.Fatal(e)
+		}
+
+		want := []FileView{{Path: "a.txt", Size: 20, End: 20, Text: "Hello <World>, world", Marks: []ViewMark{{Start: 7, End: 12}}}}
+		if !reflect.DeepEqual(env.Results, want) {
+			t.Errorf("results = %+v, want %+v", env.Results, want)
+		}
+	})
+}
@@ -2,17 +2,113 @@ package args
 
 import (
 	"flag"
+	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 )
 
+// IndexArgs are the options of building an index, shared by the server and the subcommands
+type IndexArgs struct {
+	Path          string
+	Granularity   string
+	StopWords     string
+	StopWordsFile string
+	Symlinks      string
+	// Stem and drop the stop words by the detected language of every file
+	Analyzers bool
+	// Synonym groups and expansions of the query words
+	Synonyms string
+
+	MaxFileSize   int64
+	MaxLineLength int
+	MaxTokens     int
+	MemoryBudget  int64
+}
+
+func (a *IndexArgs) register(fs *flag.FlagSet) {
+	fs.StringVar(&a.Path, "path", "", "dir path to scan, or the URL of a remote store: s3://bucket/prefix, webdav(s)://host/path, http(s)://host/listing/")
+	fs.StringVar(&a.Granularity, "granularity", "file", "unit of the index: `file`, line or sentence")
+	fs.StringVar(&a.StopWords, "stopwords", "", "comma separated languages of the built-in stop-word lists: `ru,en`")
+	fs.StringVar(&a.StopWordsFile, "stopwords-file", "", "file with extra stop words, one or more per line, # for comments")
+	fs.BoolVar(&a.Analyzers, "analyzers", false, "index the words of every file with the stemmer and the stop words of its detected language, ru or en")
+	fs.StringVar(&a.Synonyms, "synonyms", "", "`file` of the synonyms of the query words: a group `a, b, c` or an expansion `a => b, c` per line")
+	fs.StringVar(&a.Symlinks, "symlinks", "skip", "symbolic links: `skip` or follow, cycles and duplicates are skipped")
+	fs.Int64Var(&a.MaxFileSize, "max-file-size", 256<<20, "larger files are not indexed, in `bytes`, 0 for no limit but the -memory-budget")
+	fs.IntVar(&a.MaxLineLength, "max-line-length", 0, "longer lines or sentences are cut, in `bytes`, 0 for no limit")
+	fs.IntVar(&a.MaxTokens, "max-tokens", 0, "words of a file past the `number` are not indexed, 0 for no limit")
+	fs.Int64Var(&a.MemoryBudget, "memory-budget", 256<<20, "`bytes` of the files read but not indexed yet, the larger files are not indexed, 0 for no limit")
+}
+
 type Args struct {
 	HttpAddr string
-	Path     string
+	// TCP listener of JSON-RPC, a message per line, none when empty
+	RPCAddr string
+	IndexArgs
+
+	// Access configuration of auth.LoadConfig, the API is open when empty
+	AuthConfig string
+	// File the denied requests are appended to, stderr when empty
+	AuditLog string
+
+	// Reloaded when the files change
+	TLSCert string
+	TLSKey  string
+	// Ephemeral certificate generated at start, for the local tests
+	TLSSelfSigned bool
+	// CA bundle of the client certificates of mutual TLS
+	TLSClientCA string
+	// request or require a client certificate
+	TLSClientAuth string
+	// max-age of Strict-Transport-Security, 0 does not send it
+	HSTS time.Duration
+
+	// Searches per second of a client, by API key or IP, 0 does not limit
+	RateLimit float64
+	RateBurst int
+	// Searches served at once, 0 does not limit
+	MaxConcurrent int
+	// How long a search waits for a free slot
+	QueueTimeout time.Duration
+	// Dictionary terms and postings a search may walk, 0 does not limit
+	MaxQueryCost int
+
+	// How often the synonyms file is checked for changes, 0 does not reload it
+	SynonymsReload time.Duration
 }
 
 func ArgsParse() *Args {
-	addr := flag.String("addr", "", "address of http server: `localhost:3333` for example")
-	path := flag.String("path", "", "dir path to scan")
+	args := &Args{}
+
+	flag.StringVar(&args.HttpAddr, "addr", "", "address of http server: `localhost:3333` for example")
+	flag.StringVar(&args.RPCAddr, "rpc-addr", "", "address of the JSON-RPC listener, a message per line, TLS with the certificate of HTTPS: `localhost:3334` for example")
+	args.IndexArgs.register(flag.CommandLine)
+	flag.StringVar(&args.AuthConfig, "auth-config", "", "JSON `file` of the API keys, token secrets and client certificates of the callers and their paths")
+	flag.StringVar(&args.AuditLog, "audit-log", "", "`file` the denied requests are appended to, stderr by default")
+	flag.StringVar(&args.TLSCert, "tls-cert", "", "certificate `file` of HTTPS, PEM, reloaded when it changes")
+	flag.StringVar(&args.TLSKey, "tls-key", "", "private key `file` of the certificate, PEM")
+	flag.BoolVar(&args.TLSSelfSigned, "tls-self-signed", false, "serve HTTPS with an ephemeral self-signed certificate of localhost, for development")
+	flag.StringVar(&args.TLSClientCA, "tls-client-ca", "", "CA `file` verifying the client certificates, PEM")
+	flag.StringVar(&args.TLSClientAuth, "tls-client-auth", "request", "client certificates with -tls-client-ca: `request` or require")
+	flag.DurationVar(&args.HSTS, "hsts", 0, "max-age of the Strict-Transport-Security header of HTTPS, 0 to not send it")
+	flag.Float64Var(&args.RateLimit, "rate-limit", 0, "searches per second of a client, by API key or IP, 0 for no limit")
+	flag.IntVar(&args.RateBurst, "rate-burst", 20, "searches a client may send at once above the rate")
+	flag.IntVar(&args.MaxConcurrent, "max-concurrent", 64, "searches served at once, 0 for no limit")
+	flag.DurationVar(&args.QueueTimeout, "queue-timeout", 5*time.Second, "how long a search waits for a free slot")
+	flag.DurationVar(&args.SynonymsReload, "synonyms-reload", 10*time.Second, "how often the -synonyms file is checked for changes, 0 to not reload it")
+	flag.IntVar(&args.MaxQueryCost, "max-query-cost", 1_000_000, "dictionary terms and postings a search may walk, wildcards are the broad ones, 0 for no limit")
+
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: %s -addr ADDR -path DIR [options]\n", os.Args[0])
+		fmt.Fprintf(out, "       %s search [options] WORD...\n", os.Args[0])
+		fmt.Fprintf(out, "       %s index -path DIR -o FILE [options]\n", os.Args[0])
+		fmt.Fprintf(out, "       %s snapshot export|import|diff [options]\n", os.Args[0])
+		fmt.Fprintf(out, "       %s duplicates -path DIR [options]\n", os.Args[0])
+		fmt.Fprintf(out, "       %s token -auth-config FILE -sub NAME [options]\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 
 	flag.Parse()
 
@@ -24,8 +120,299 @@ func ArgsParse() *Args {
 		os.Exit(0)
 	}
 
-	return &Args{
-		HttpAddr: *addr,
-		Path:     *path,
+	var problem string
+	switch {
+	case (args.TLSCert == "") != (args.TLSKey == ""):
+		problem = "-tls-cert and -tls-key go together"
+	case args.TLSSelfSigned && args.TLSCert != "":
+		problem = "-tls-self-signed replaces -tls-cert and -tls-key"
+	case args.TLSClientCA != "" && !args.TLS():
+		problem = "-tls-client-ca needs -tls-cert or -tls-self-signed"
+	case args.TLSClientAuth != "request" && args.TLSClientAuth != "require":
+		problem = "-tls-client-auth is request or require"
+	case args.HSTS < 0:
+		problem = "-hsts can not be negative"
+	case args.SynonymsReload < 0:
+		problem = "-synonyms-reload can not be negative"
+	}
+
+	if problem != "" {
+		fmt.Fprintln(flag.CommandLine.Output(), problem)
+		os.Exit(2)
+	}
+
+	return args
+}
+
+// TLS reports whether the server speaks HTTPS
+func (a *Args) TLS() bool {
+	return a.TLSCert != "" || a.TLSSelfSigned
+}
+
+// SnapshotArgs are the options of the `snapshot` subcommand
+type SnapshotArgs struct {
+	// export, import or diff
+	Command string
+	IndexArgs
+	// File to export to, stdout when empty
+	Output string
+	// Snapshots to import or to diff
+	Files []string
+}
+
+// SnapshotParse parses the arguments following `snapshot`, the usage is written to out
+func SnapshotParse(arguments []string, out io.Writer) (*SnapshotArgs, error) {
+	usage := func() {
+		fmt.Fprintln(out, "Usage: snapshot export -path DIR [-o FILE] [index options]")
+		fmt.Fprintln(out, "       snapshot import FILE")
+		fmt.Fprintln(out, "       snapshot diff OLD NEW")
+	}
+
+	if len(arguments) == 0 {
+		usage()
+		return nil, flag.ErrHelp
+	}
+
+	args := &SnapshotArgs{Command: arguments[0]}
+
+	fs := flag.NewFlagSet("snapshot "+args.Command, flag.ContinueOnError)
+	fs.SetOutput(out)
+
+	switch args.Command {
+	case "export":
+		args.IndexArgs.register(fs)
+		fs.StringVar(&args.Output, "o", "", "`file` to write the snapshot to, stdout by default")
+	case "import", "diff":
+	default:
+		usage()
+		return nil, fmt.Errorf("unknown snapshot command %q", args.Command)
+	}
+
+	if e := fs.Parse(arguments[1:]); e != nil {
+		return nil, e
+	}
+
+	args.Files = fs.Args()
+
+	switch {
+	case args.Command == "export" && args.Path == "":
+		return nil, fmt.Errorf("snapshot export: -path is required")
+	case args.Command == "import" && len(args.Files) != 1:
+		return nil, fmt.Errorf("snapshot import: expected one file")
+	case args.Command == "diff" && len(args.Files) != 2:
+		return nil, fmt.Errorf("snapshot diff: expected two files")
+	}
+
+	return args, nil
+}
+
+// SearchArgs are the options of the `search` subcommand
+type SearchArgs struct {
+	IndexArgs
+	// Saved index to search instead of scanning Path, Path is then only read for the
+	// text of the lines or sentences
+	Index string
+	// text or json
+	Format string
+	Sort   string
+	// 0 prints all the hits
+	Limit  int
+	Offset int
+	// Weight of the matches in the file names, 0 for the default
+	NameBoost float64
+
+	PathPrefix     string
+	Ext            string
+	Lang           string
+	ModifiedAfter  string
+	ModifiedBefore string
+	MinSize        string
+	MaxSize        string
+
+	Words []string
+}
+
+// SearchParse parses the arguments following `search`, the usage is written to out
+func SearchParse(arguments []string, out io.Writer) (*SearchArgs, error) {
+	args := &SearchArgs{}
+
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprintln(out, "Usage: search (-path DIR | -index FILE) [options] WORD...")
+		fmt.Fprintln(out, "A WORD of name:WORD or path:DIR/NAME matches the file names or the paths instead of the content")
+		fmt.Fprintln(out, "Exit status is 0 when a file matches, 1 when none does and 2 on error")
+		fs.PrintDefaults()
 	}
+
+	args.IndexArgs.register(fs)
+	fs.StringVar(&args.Index, "index", "", "saved index `file` written by the index command")
+	fs.StringVar(&args.Format, "format", "text", "output format: `text` or json")
+	fs.StringVar(&args.Sort, "sort", "", "order of the hits: `path`, mtime or score")
+	fs.IntVar(&args.Limit, "limit", 0, "maximum number of hits, 0 for all")
+	fs.IntVar(&args.Offset, "offset", 0, "number of hits to skip")
+	fs.Float64Var(&args.NameBoost, "name-boost", 0, "`weight` of the matches in the file names against 1 for the content, 0 for 2")
+	fs.StringVar(&args.PathPrefix, "path-prefix", "", "only the files under the `path`")
+	fs.StringVar(&args.Ext, "ext", "", "only the files with the comma separated `extensions`")
+	fs.StringVar(&args.Lang, "lang", "", "only the files in the comma separated `languages`: ru, en or und for the undetected ones")
+	fs.StringVar(&args.ModifiedAfter, "modified-after", "", "only the files modified after the `time`, RFC 3339 or YYYY-MM-DD")
+	fs.StringVar(&args.ModifiedBefore, "modified-before", "", "only the files modified before the `time`, RFC 3339 or YYYY-MM-DD")
+	fs.StringVar(&args.MinSize, "min-size", "", "only the files of at least `bytes`")
+	fs.StringVar(&args.MaxSize, "max-size", "", "only the files of at most `bytes`")
+
+	if e := fs.Parse(arguments); e != nil {
+		return nil, e
+	}
+
+	args.Words = fs.Args()
+
+	switch {
+	case args.Path == "" && args.Index == "":
+		return nil, fmt.Errorf("search: -path or -index is required")
+	case len(args.Words) == 0:
+		return nil, fmt.Errorf("search: no words to search")
+	case args.Format != "text" && args.Format != "json":
+		return nil, fmt.Errorf("search: unknown format %q, expected text or json", args.Format)
+	}
+
+	return args, nil
+}
+
+// IndexCmdArgs are the options of the `index` subcommand
+type IndexCmdArgs struct {
+	IndexArgs
+	// File to save the index to
+	Output string
+	// Format of the summary: text or json
+	Format string
+}
+
+// IndexParse parses the arguments following `index`, the usage is written to out
+func IndexParse(arguments []string, out io.Writer) (*IndexCmdArgs, error) {
+	args := &IndexCmdArgs{}
+
+	fs := flag.NewFlagSet("index", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprintln(out, "Usage: index -path DIR -o FILE [options]")
+		fmt.Fprintln(out, "Exit status is 0 when files were indexed, 1 when there were none and 2 on error")
+		fs.PrintDefaults()
+	}
+
+	args.IndexArgs.register(fs)
+	fs.StringVar(&args.Output, "o", "", "`file` to save the index to")
+	fs.StringVar(&args.Format, "format", "text", "format of the summary: `text` or json")
+
+	if e := fs.Parse(arguments); e != nil {
+		return nil, e
+	}
+
+	switch {
+	case args.Path == "" || args.Output == "":
+		return nil, fmt.Errorf("index: -path and -o are required")
+	case fs.NArg() > 0:
+		return nil, fmt.Errorf("index: unexpected arguments %q", fs.Args())
+	case args.Format != "text" && args.Format != "json":
+		return nil, fmt.Errorf("index: unknown format %q, expected text or json", args.Format)
+	}
+
+	return args, nil
+}
+
+// DuplicatesArgs are the options of the `duplicates` subcommand
+type DuplicatesArgs struct {
+	IndexArgs
+	// Saved index to compare the files of instead of scanning Path
+	Index string
+	// text or json
+	Format string
+	// Minimum similarity of the files of a cluster
+	Threshold float64
+	// minhash or simhash
+	Method     string
+	PathPrefix string
+}
+
+// DuplicatesParse parses the arguments following `duplicates`, the usage is written to out
+func DuplicatesParse(arguments []string, out io.Writer) (*DuplicatesArgs, error) {
+	args := &DuplicatesArgs{}
+
+	fs := flag.NewFlagSet("duplicates", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprintln(out, "Usage: duplicates (-path DIR | -index FILE) [options]")
+		fmt.Fprintln(out, "Prints the clusters of near-identical files separated by an empty line")
+		fmt.Fprintln(out, "Exit status is 0 when there are some, 1 when there are none and 2 on error")
+		fs.PrintDefaults()
+	}
+
+	args.IndexArgs.register(fs)
+	fs.StringVar(&args.Index, "index", "", "saved index `file` written by the index command")
+	fs.StringVar(&args.Format, "format", "text", "output format: `text` or json")
+	fs.Float64Var(&args.Threshold, "threshold", 0.8, "minimum similarity of the files of a cluster, above 0 and at most 1")
+	fs.StringVar(&args.Method, "method", "minhash", "similarity of the terms: `minhash` (Jaccard) or simhash (weighted by the occurrences)")
+	fs.StringVar(&args.PathPrefix, "path-prefix", "", "only the files under the `path`")
+
+	if e := fs.Parse(arguments); e != nil {
+		return nil, e
+	}
+
+	switch {
+	case args.Path == "" && args.Index == "":
+		return nil, fmt.Errorf("duplicates: -path or -index is required")
+	case fs.NArg() > 0:
+		return nil, fmt.Errorf("duplicates: unexpected arguments %q", fs.Args())
+	case args.Format != "text" && args.Format != "json":
+		return nil, fmt.Errorf("duplicates: unknown format %q, expected text or json", args.Format)
+	}
+
+	return args, nil
+}
+
+// TokenArgs are the options of the `token` subcommand
+type TokenArgs struct {
+	AuthConfig string
+	Subject    string
+	// Subtrees granted by the token, the ones of the principal when empty
+	Paths []string
+	TTL   time.Duration
+}
+
+// TokenParse parses the arguments following `token`, the usage is written to out
+func TokenParse(arguments []string, out io.Writer) (*TokenArgs, error) {
+	args := &TokenArgs{}
+	var paths string
+
+	fs := flag.NewFlagSet("token", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprintln(out, "Usage: token -auth-config FILE -sub NAME [-paths DIR,...] [-ttl DURATION]")
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&args.AuthConfig, "auth-config", "", "access configuration `file` with the token secrets")
+	fs.StringVar(&args.Subject, "sub", "", "`name` of the caller")
+	fs.StringVar(&paths, "paths", "", "comma separated `dirs` the token grants, the ones of the principal by default")
+	fs.DurationVar(&args.TTL, "ttl", time.Hour, "validity of the token")
+
+	if e := fs.Parse(arguments); e != nil {
+		return nil, e
+	}
+
+	for _, p := range strings.Split(paths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			args.Paths = append(args.Paths, p)
+		}
+	}
+
+	switch {
+	case args.AuthConfig == "" || args.Subject == "":
+		return nil, fmt.Errorf("token: -auth-config and -sub are required")
+	case args.TTL <= 0:
+		return nil, fmt.Errorf("token: -ttl must be positive")
+	case fs.NArg() > 0:
+		return nil, fmt.Errorf("token: unexpected arguments %q", fs.Args())
+	}
+
+	return args, nil
 }
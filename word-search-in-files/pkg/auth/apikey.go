@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// APIKeys authenticates the static keys of the X-API-Key header or of an
+// `Authorization: ApiKey <key>` header. Only the SHA-256 of the keys is kept
+type APIKeys struct {
+	byHash map[[sha256.Size]byte]*Principal
+}
+
+func NewAPIKeys() *APIKeys {
+	return &APIKeys{byHash: make(map[[sha256.Size]byte]*Principal)}
+}
+
+// Add registers the key of the principal
+func (k *APIKeys) Add(key string, p *Principal) {
+	k.byHash[sha256.Sum256([]byte(key))] = p
+}
+
+// AddHash registers the hex encoded SHA-256 of a key, so the configuration does not
+// have to hold the key itself
+func (k *APIKeys) AddHash(hexHash string, p *Principal) error {
+	b, e := hex.DecodeString(hexHash)
+	if e != nil || len(b) != sha256.Size {
+		return ErrInvalidCredentials
+	}
+
+	var h [sha256.Size]byte
+	copy(h[:], b)
+	k.byHash[h] = p
+
+	return nil
+}
+
+// Len returns the number of keys
+func (k *APIKeys) Len() int {
+	return len(k.byHash)
+}
+
+func (k *APIKeys) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		scheme, value, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "ApiKey") {
+			return nil, ErrNoCredentials
+		}
+		key = strings.TrimSpace(value)
+	}
+
+	// The lookup is by the hash, the time does not depend on a prefix of the key
+	p, ok := k.byHash[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Principal{Name: p.Name, Paths: p.Paths, Method: MethodAPIKey}, nil
+}
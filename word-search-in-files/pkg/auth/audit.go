@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditEvent is a denied request
+type AuditEvent struct {
+	Time time.Time `json:"time"`
+	// Empty when the caller was not authenticated
+	Principal string `json:"principal,omitempty"`
+	Auth      string `json:"auth,omitempty"`
+	Remote    string `json:"remote"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Query     string `json:"query,omitempty"`
+	Status    int    `json:"status"`
+	Reason    string `json:"reason"`
+}
+
+// AuditLog writes the events as JSON lines. A nil log discards them
+type AuditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{w: w}
+}
+
+// Log writes the event, the time is set when zero
+func (l *AuditLog) Log(ev AuditEvent) error {
+	if l == nil {
+		return nil
+	}
+
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+
+	data, e := json.Marshal(ev)
+	if e != nil {
+		return e
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, e = l.w.Write(append(data, '\n'))
+	return e
+}
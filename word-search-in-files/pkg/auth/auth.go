@@ -0,0 +1,96 @@
+// Package auth authenticates the callers of the HTTP API and describes what they may
+// search. A Principal is found by an Authenticator from a static API key, an HMAC
+// signed token or the client certificate of a mutual TLS connection, its Paths are
+// the subtrees of the index it may see.
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var (
+	// ErrNoCredentials is returned when the request carries none of the credentials
+	// of the authenticator, the next one of a Chain is tried
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned for unknown keys, bad signatures, expired
+	// tokens or unknown certificates
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+const (
+	MethodAPIKey = "api_key"
+	MethodHMAC   = "hmac"
+	MethodMTLS   = "mtls"
+)
+
+type Principal struct {
+	Name string
+	// Subtrees of the index the principal may search, "." for the whole tree
+	Paths []string
+	// How the principal was authenticated: api_key, hmac or mtls
+	Method string
+}
+
+// Allows reports whether the file is in one of the subtrees of the principal
+func (p *Principal) Allows(file string) bool {
+	file = clean(file)
+
+	for _, dir := range p.Paths {
+		if within(file, clean(dir)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanSee reports whether some of the files under the directory may be searched by
+// the principal: the directory is in one of its subtrees or contains one
+func (p *Principal) CanSee(dir string) bool {
+	dir = clean(dir)
+
+	for _, allowed := range p.Paths {
+		if allowed = clean(allowed); within(dir, allowed) || within(allowed, dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// within reports whether the cleaned path is the directory or under it
+func within(name, dir string) bool {
+	return dir == "" || name == dir || strings.HasPrefix(name, dir+"/")
+}
+
+// clean returns the path relative to the root without the slashes around, "" for
+// the root
+func clean(name string) string {
+	return path.Clean("/" + strings.TrimSpace(name))[1:]
+}
+
+type Authenticator interface {
+	// Authenticate returns the principal of the request, ErrNoCredentials when the
+	// request has no credentials of the kind
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain tries the authenticators in order, the first one that finds credentials in
+// the request decides
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		p, e := a.Authenticate(r)
+		if errors.Is(e, ErrNoCredentials) {
+			continue
+		}
+
+		return p, e
+	}
+
+	return nil, ErrNoCredentials
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrincipal(t *testing.T) {
+	p := &Principal{Name: "docs", Paths: []string{"docs", "wiki/public/"}}
+
+	tests := []struct {
+		path       string
+		wantAllows bool
+		wantCanSee bool
+	}{
+		{path: "docs/a.txt", wantAllows: true, wantCanSee: true},
+		{path: "./docs/b/c.txt", wantAllows: true, wantCanSee: true},
+		{path: "docs2/a.txt", wantAllows: false, wantCanSee: false},
+		{path: "wiki/public", wantAllows: true, wantCanSee: true},
+		{path: "wiki", wantAllows: false, wantCanSee: true},
+		{path: "", wantAllows: false, wantCanSee: true},
+		{path: "docs/../src/a.go", wantAllows: false, wantCanSee: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := p.Allows(tt.path); got != tt.wantAllows {
+				t.Errorf("Allows() = %v, want %v", got, tt.wantAllows)
+			}
+
+			if got := p.CanSee(tt.path); got != tt.wantCanSee {
+				t.Errorf("CanSee() = %v, want %v", got, tt.wantCanSee)
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	keys := NewAPIKeys()
+	keys.Add("k1", &Principal{Name: "ci", Paths: []string{"."}})
+
+	tokens := NewTokens([]byte("new secret of the tokens"), []byte("old secret of the tokens"))
+	tokens.now = func() time.Time { return now }
+	tokens.Principals["docs"] = &Principal{Name: "docs", Paths: []string{"docs"}}
+
+	old := NewTokens([]byte("old secret of the tokens"))
+	other := NewTokens([]byte("unknown secret of tokens"))
+
+	sign := func(tk *Tokens, c Claims) string {
+		s, e := tk.Sign(c)
+		if e != nil {
+			t.Fatal(e)
+		}
+		return s
+	}
+
+	certs := NewClientCerts()
+	certs.Add("svc.example.com", &Principal{Name: "svc", Paths: []string{"src"}})
+
+	chain := Chain{certs, keys, tokens}
+	exp := now.Add(time.Hour).Unix()
+
+	tests := []struct {
+		name      string
+		header    map[string]string
+		cert      *x509.Certificate
+		wantName  string
+		wantPaths []string
+		wantErr   error
+	}{
+		{name: "API key", header: map[string]string{"X-API-Key": "k1"}, wantName: "ci", wantPaths: []string{"."}},
+		{name: "API key scheme", header: map[string]string{"Authorization": "ApiKey k1"}, wantName: "ci", wantPaths: []string{"."}},
+		{name: "Token of a principal", header: map[string]string{"Authorization": "Bearer " + sign(tokens, Claims{Subject: "docs", Expires: exp})}, wantName: "docs", wantPaths: []string{"docs"}},
+		{name: "Token with paths", header: map[string]string{"Authorization": "Bearer " + sign(tokens, Claims{Subject: "bot", Paths: []string{"a"}, Expires: exp})}, wantName: "bot", wantPaths: []string{"a"}},
+		{name: "Token of the old secret", header: map[string]string{"Authorization": "Bearer " + sign(old, Claims{Subject: "docs", Expires: exp})}, wantName: "docs", wantPaths: []string{"docs"}},
+		{name: "Certificate", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "svc.example.com"}}, wantName: "svc", wantPaths: []string{"src"}},
+		{name: "Certificate by DNS name", cert: &x509.Certificate{DNSNames: []string{"svc.example.com"}}, wantName: "svc", wantPaths: []string{"src"}},
+		{name: "E: none", wantErr: ErrNoCredentials},
+		{name: "E: unknown key", header: map[string]string{"X-API-Key": "k2"}, wantErr: ErrInvalidCredentials},
+		{name: "E: unknown secret", header: map[string]string{"Authorization": "Bearer " + sign(other, Claims{Subject: "docs", Expires: exp})}, wantErr: ErrInvalidCredentials},
+		{name: "E: expired", header: map[string]string{"Authorization": "Bearer " + sign(tokens, Claims{Subject: "docs", Expires: now.Unix()})}, wantErr: ErrInvalidCredentials},
+		{name: "E: not yet", header: map[string]string{"Authorization": "Bearer " + sign(tokens, Claims{Subject: "docs", Expires: exp, NotBefore: exp - 1})}, wantErr: ErrInvalidCredentials},
+		{name: "E: unknown subject", header: map[string]string{"Authorization": "Bearer " + sign(tokens, Claims{Subject: "eve", Expires: exp})}, wantErr: ErrInvalidCredentials},
+		{name: "E: malformed token", header: map[string]string{"Authorization": "Bearer abc"}, wantErr: ErrInvalidCredentials},
+		{name: "E: unknown certificate", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "eve"}}, wantErr: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/files/search", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if tt.cert != nil {
+				r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{tt.cert}}}
+			}
+
+			p, e := chain.Authenticate(r)
+			if !errors.Is(e, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", e, tt.wantErr)
+			}
+			if e != nil {
+				return
+			}
+
+			if p.Name != tt.wantName || strings.Join(p.Paths, ",") != strings.Join(tt.wantPaths, ",") {
+				t.Errorf("Authenticate() = %+v, want %s %v", p, tt.wantName, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		wantChain int
+		wantErr   bool
+	}{
+		{name: "Ok", config: `{"principals":[{"name":"ci","paths":["."],"api_keys":["k1","sha256:` + strings.Repeat("ab", 32) + `"],"cert_names":["ci.example.com"]}],"token_secrets":["0123456789abcdef"]}`, wantChain: 3},
+		{name: "Keys only", config: `{"principals":[{"name":"ci","paths":["."],"api_keys":["k1"]}]}`, wantChain: 1},
+		{name: "E: unknown field", config: `{"principal":[]}`, wantErr: true},
+		{name: "E: duplicate", config: `{"principals":[{"name":"a"},{"name":"a"}]}`, wantErr: true},
+		{name: "E: short secret", config: `{"token_secrets":["short"]}`, wantErr: true},
+		{name: "E: hash", config: `{"principals":[{"name":"ci","api_keys":["sha256:zz"]}]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, e := ReadConfig(strings.NewReader(tt.config))
+
+			var chain Chain
+			if e == nil {
+				chain, e = cfg.Authenticator()
+			}
+
+			if (e != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", e, tt.wantErr)
+			}
+
+			if len(chain) != tt.wantChain {
+				t.Errorf("chain = %d authenticators, want %d", len(chain), tt.wantChain)
+			}
+		})
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewAuditLog(buf)
+
+	if e := l.Log(AuditEvent{Principal: "docs", Path: "/files/search", Status: 403, Reason: "denied"}); e != nil {
+		t.Fatal(e)
+	}
+
+	var ev AuditEvent
+	if e := json.Unmarshal(buf.Bytes(), &ev); e != nil {
+		t.Fatalf("decoding %q: %v", buf.String(), e)
+	}
+
+	if ev.Principal != "docs" || ev.Status != 403 || ev.Time.IsZero() {
+		t.Errorf("event = %+v", ev)
+	}
+
+	var none *AuditLog
+	if e := none.Log(ev); e != nil {
+		t.Errorf("nil log: %v", e)
+	}
+}
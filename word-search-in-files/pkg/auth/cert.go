@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"net/http"
+)
+
+// ClientCerts authenticates the verified client certificate of a mutual TLS
+// connection by the common name or a DNS name of its subject. The server verifies
+// the chain against its client CAs, ClientCerts only maps the names
+type ClientCerts struct {
+	byName map[string]*Principal
+}
+
+func NewClientCerts() *ClientCerts {
+	return &ClientCerts{byName: make(map[string]*Principal)}
+}
+
+// Add registers the certificate name of the principal
+func (c *ClientCerts) Add(name string, p *Principal) {
+	c.byName[name] = p
+}
+
+// Len returns the number of names
+func (c *ClientCerts) Len() int {
+	return len(c.byName)
+}
+
+func (c *ClientCerts) Authenticate(r *http.Request) (*Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	for _, name := range names {
+		if p, ok := c.byName[name]; ok && name != "" {
+			return &Principal{Name: p.Name, Paths: p.Paths, Method: MethodMTLS}, nil
+		}
+	}
+
+	return nil, ErrInvalidCredentials
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Config is the access configuration file:
+//
+//	{
+//	  "principals": [
+//	    {"name": "ci", "paths": ["."], "api_keys": ["sha256:9f86d0..."]},
+//	    {"name": "docs-team", "paths": ["docs", "wiki/public"], "cert_names": ["docs.example.com"]}
+//	  ],
+//	  "token_secrets": ["a long random string"]
+//	}
+//
+// An API key is given as is or as "sha256:" and the hex of its hash. The tokens
+// signed with one of the secrets name a principal or carry their own paths
+type Config struct {
+	Principals   []PrincipalConfig `json:"principals"`
+	TokenSecrets []string          `json:"token_secrets"`
+}
+
+type PrincipalConfig struct {
+	Name      string   `json:"name"`
+	Paths     []string `json:"paths"`
+	APIKeys   []string `json:"api_keys"`
+	CertNames []string `json:"cert_names"`
+}
+
+// LoadConfig reads the configuration file
+func LoadConfig(file string) (*Config, error) {
+	f, e := os.Open(file)
+	if e != nil {
+		return nil, e
+	}
+	defer f.Close()
+
+	cfg, e := ReadConfig(f)
+	if e != nil {
+		return nil, fmt.Errorf("reading %s: %w", file, e)
+	}
+
+	return cfg, nil
+}
+
+// ReadConfig decodes the configuration, unknown fields are errors
+func ReadConfig(r io.Reader) (*Config, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	cfg := &Config{}
+	if e := dec.Decode(cfg); e != nil {
+		return nil, e
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range cfg.Principals {
+		switch {
+		case p.Name == "":
+			return nil, fmt.Errorf("principal without a name")
+		case seen[p.Name]:
+			return nil, fmt.Errorf("duplicate principal %q", p.Name)
+		}
+		seen[p.Name] = true
+	}
+
+	return cfg, nil
+}
+
+// Tokens returns the authenticator and signer of the tokens, without secrets when
+// none is configured
+func (cfg *Config) Tokens() (*Tokens, error) {
+	tokens := NewTokens()
+
+	for _, secret := range cfg.TokenSecrets {
+		if len(secret) < 16 {
+			return nil, fmt.Errorf("token secret shorter than 16 bytes")
+		}
+		tokens.Secrets = append(tokens.Secrets, []byte(secret))
+	}
+
+	for _, pc := range cfg.Principals {
+		tokens.Principals[pc.Name] = &Principal{Name: pc.Name, Paths: pc.Paths}
+	}
+
+	return tokens, nil
+}
+
+// Authenticator returns the chain of the configured authenticators: the client
+// certificates, the API keys, then the tokens
+func (cfg *Config) Authenticator() (Chain, error) {
+	tokens, e := cfg.Tokens()
+	if e != nil {
+		return nil, e
+	}
+
+	keys := NewAPIKeys()
+	certs := NewClientCerts()
+
+	for _, pc := range cfg.Principals {
+		p := tokens.Principals[pc.Name]
+
+		for _, key := range pc.APIKeys {
+			if hash, ok := strings.CutPrefix(key, "sha256:"); ok {
+				if e := keys.AddHash(hash, p); e != nil {
+					return nil, fmt.Errorf("principal %q: invalid key hash", p.Name)
+				}
+				continue
+			}
+
+			keys.Add(key, p)
+		}
+
+		for _, name := range pc.CertNames {
+			certs.Add(name, p)
+		}
+	}
+
+	var chain Chain
+
+	if certs.Len() > 0 {
+		chain = append(chain, certs)
+	}
+
+	if keys.Len() > 0 {
+		chain = append(chain, keys)
+	}
+
+	if len(tokens.Secrets) > 0 {
+		chain = append(chain, tokens)
+	}
+
+	return chain, nil
+}
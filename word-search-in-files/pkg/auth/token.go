@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Claims are the content of a signed token
+type Claims struct {
+	Subject string `json:"sub"`
+	// Subtrees granted by the token, the ones of the principal of the subject when empty
+	Paths []string `json:"paths,omitempty"`
+	// Unix time
+	Expires   int64 `json:"exp"`
+	NotBefore int64 `json:"nbf,omitempty"`
+}
+
+// Tokens authenticates the `Authorization: Bearer <token>` header. A token is
+// base64url(claims JSON) "." base64url(HMAC-SHA256 of the first part). Any of the
+// secrets verifies a token, the first one signs: a secret is rotated by putting the
+// new one first and removing the old one once its tokens have expired
+type Tokens struct {
+	Secrets [][]byte
+	// Principals by name, for the tokens without paths
+	Principals map[string]*Principal
+
+	// For the tests
+	now func() time.Time
+}
+
+// NewTokens returns the authenticator of the tokens signed with one of the secrets
+func NewTokens(secrets ...[]byte) *Tokens {
+	return &Tokens{Secrets: secrets, Principals: make(map[string]*Principal), now: time.Now}
+}
+
+// Sign returns the token of the claims, signed with the first secret
+func (t *Tokens) Sign(c Claims) (string, error) {
+	if len(t.Secrets) == 0 {
+		return "", errors.New("no secret to sign with")
+	}
+
+	payload, e := json.Marshal(c)
+	if e != nil {
+		return "", e
+	}
+
+	head := base64.RawURLEncoding.EncodeToString(payload)
+
+	return head + "." + base64.RawURLEncoding.EncodeToString(mac(t.Secrets[0], head)), nil
+}
+
+// Verify checks the signature and the validity period of the token
+func (t *Tokens) Verify(token string) (*Claims, error) {
+	head, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	got, e := base64.RawURLEncoding.DecodeString(sig)
+	if e != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	valid := false
+	for _, secret := range t.Secrets {
+		if hmac.Equal(got, mac(secret, head)) {
+			valid = true
+			break
+		}
+	}
+
+	if !valid {
+		return nil, ErrInvalidCredentials
+	}
+
+	payload, e := base64.RawURLEncoding.DecodeString(head)
+	if e != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	c := &Claims{}
+	if e := json.Unmarshal(payload, c); e != nil || c.Subject == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	now := t.now().Unix()
+	if c.Expires == 0 || now >= c.Expires || now < c.NotBefore {
+		return nil, ErrInvalidCredentials
+	}
+
+	return c, nil
+}
+
+func (t *Tokens) Authenticate(r *http.Request) (*Principal, error) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrNoCredentials
+	}
+
+	c, e := t.Verify(strings.TrimSpace(token))
+	if e != nil {
+		return nil, e
+	}
+
+	p := &Principal{Name: c.Subject, Paths: c.Paths, Method: MethodHMAC}
+
+	if len(p.Paths) == 0 {
+		known, ok := t.Principals[c.Subject]
+		if !ok {
+			return nil, ErrInvalidCredentials
+		}
+		p.Paths = known.Paths
+	}
+
+	return p, nil
+}
+
+func mac(secret []byte, data string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,193 @@
+// Package certs provides the TLS configuration of the server: the certificate and
+// the client CAs are read from files and reloaded when the files change, so a
+// renewed certificate is served without a restart.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultCheckInterval is how often the files are checked for a change, on a handshake
+const DefaultCheckInterval = time.Second
+
+// Reloader serves the certificate of the files and verifies the client certificates
+// against the CAs of the file. A file that fails to load keeps the previous content
+// in use until it is fixed
+type Reloader struct {
+	certFile, keyFile string
+	// Empty when the client certificates are not verified
+	caFile string
+
+	CheckInterval time.Duration
+	// Called after every reload, with the error of a failed one
+	OnReload func(error)
+
+	mu        sync.Mutex
+	checked   time.Time
+	stamps    map[string]stamp
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+
+	// For the tests
+	now func() time.Time
+}
+
+// stamp is what tells a file changed
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader loads the certificate, the key and the client CAs when caFile is set
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		caFile:        caFile,
+		CheckInterval: DefaultCheckInterval,
+		stamps:        make(map[string]stamp),
+		now:           time.Now,
+	}
+
+	if e := r.load(); e != nil {
+		return nil, e
+	}
+	r.checked = r.now()
+
+	return r, nil
+}
+
+// TLSConfig returns the configuration of the server, see Config
+func (r *Reloader) TLSConfig(clientAuth tls.ClientAuthType) *tls.Config {
+	cfg := Config(nil, nil, clientAuth)
+
+	// For the checks of http.Server, the handshakes use GetConfigForClient
+	cfg.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, _ := r.current()
+		return cert, nil
+	}
+
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cert, pool := r.current()
+		return Config(cert, pool, clientAuth), nil
+	}
+
+	return cfg
+}
+
+// Config returns the configuration of a server with the certificate: TLS 1.2 or later,
+// HTTP/2 and HTTP/1.1, the client certificates verified against the CAs with the client
+// authentication type when the pool is not nil
+func Config(cert *tls.Certificate, clientCAs *x509.CertPool, clientAuth tls.ClientAuthType) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// http.Server adds h2 to its own configuration only, not to the ones of
+		// GetConfigForClient
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	if cert != nil {
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+
+	if clientCAs != nil {
+		cfg.ClientCAs = clientCAs
+		cfg.ClientAuth = clientAuth
+	}
+
+	return cfg
+}
+
+// current returns the certificate and the client CAs, after reloading them when the
+// files changed since the last check
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := r.now(); now.Sub(r.checked) >= r.CheckInterval {
+		r.checked = now
+
+		if r.changed() {
+			e := r.load()
+			if r.OnReload != nil {
+				r.OnReload(e)
+			}
+		}
+	}
+
+	return r.cert, r.clientCAs
+}
+
+// changed reports whether one of the files has another modification time or size,
+// the caller must hold mu
+func (r *Reloader) changed() bool {
+	for _, file := range r.files() {
+		info, e := os.Stat(file)
+		if e != nil {
+			// Being replaced, the next check will see the new one
+			continue
+		}
+
+		if st := (stamp{info.ModTime(), info.Size()}); st != r.stamps[file] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// load reads the files, the caller must hold mu unless the reloader is not shared yet
+func (r *Reloader) load() error {
+	stamps := make(map[string]stamp)
+	for _, file := range r.files() {
+		if info, e := os.Stat(file); e == nil {
+			stamps[file] = stamp{info.ModTime(), info.Size()}
+		}
+	}
+
+	cert, e := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if e != nil {
+		return fmt.Errorf("loading the certificate: %w", e)
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		if pool, e = LoadCertPool(r.caFile); e != nil {
+			return e
+		}
+	}
+
+	r.cert, r.clientCAs, r.stamps = &cert, pool, stamps
+
+	return nil
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+
+	return files
+}
+
+// LoadCertPool reads the PEM certificates of the file
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	pem, e := os.ReadFile(file)
+	if e != nil {
+		return nil, e
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New(file + ": no certificates")
+	}
+
+	return pool, nil
+}
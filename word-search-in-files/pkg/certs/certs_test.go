@@ -0,0 +1,153 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a new self-signed certificate and its key to the files
+func writeCert(t *testing.T, certFile, keyFile string) tls.Certificate {
+	cert, e := SelfSigned([]string{"localhost"}, time.Hour)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	key, e := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if e := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600); e != nil {
+		t.Fatal(e)
+	}
+
+	return cert
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem")
+
+	first := writeCert(t, certFile, keyFile)
+
+	data, _ := os.ReadFile(certFile)
+	if e := os.WriteFile(caFile, data, 0o600); e != nil {
+		t.Fatal(e)
+	}
+
+	r, e := NewReloader(certFile, keyFile, caFile)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	var reloads []error
+	r.OnReload = func(e error) { reloads = append(reloads, e) }
+
+	served := func() string {
+		cfg, e := r.TLSConfig(tls.RequireAndVerifyClientCert).GetConfigForClient(&tls.ClientHelloInfo{})
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		if len(cfg.NextProtos) == 0 || cfg.NextProtos[0] != "h2" {
+			t.Errorf("NextProtos = %v, want h2 first", cfg.NextProtos)
+		}
+
+		if cfg.ClientAuth != tls.RequireAndVerifyClientCert || cfg.ClientCAs == nil {
+			t.Errorf("client authentication %v, CAs %v", cfg.ClientAuth, cfg.ClientCAs)
+		}
+
+		return Fingerprint(cfg.Certificates[0])
+	}
+
+	if got := served(); got != Fingerprint(first) {
+		t.Fatalf("served %s, want the first certificate %s", got, Fingerprint(first))
+	}
+
+	// Renewed, but not checked before the interval
+	second := writeCert(t, certFile, keyFile)
+	later := now.Add(2 * time.Second)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+
+	if got := served(); got != Fingerprint(first) {
+		t.Errorf("served %s before the check interval, want the first certificate", got)
+	}
+
+	now = now.Add(DefaultCheckInterval)
+
+	if got := served(); got != Fingerprint(second) {
+		t.Errorf("served %s, want the renewed certificate %s", got, Fingerprint(second))
+	}
+
+	// A broken file keeps the previous certificate
+	if e := os.WriteFile(keyFile, []byte("garbage"), 0o600); e != nil {
+		t.Fatal(e)
+	}
+	now = now.Add(DefaultCheckInterval)
+
+	if got := served(); got != Fingerprint(second) {
+		t.Errorf("served %s after a failed reload, want the renewed certificate", got)
+	}
+
+	if len(reloads) != 2 || reloads[0] != nil || reloads[1] == nil {
+		t.Errorf("reloads = %v, want a success then a failure", reloads)
+	}
+}
+
+func TestNewReloader_Errors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCert(t, certFile, keyFile)
+
+	tests := []struct {
+		name              string
+		cert, key, caFile string
+	}{
+		{name: "No certificate", cert: filepath.Join(dir, "none.pem"), key: keyFile},
+		{name: "Key of another file", cert: certFile, key: certFile},
+		{name: "No CA", cert: certFile, key: keyFile, caFile: keyFile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, e := NewReloader(tt.cert, tt.key, tt.caFile); e == nil {
+				t.Errorf("NewReloader() error = nil")
+			}
+		})
+	}
+}
+
+func TestSelfSigned(t *testing.T) {
+	cert, e := SelfSigned([]string{"localhost", "127.0.0.1"}, time.Hour)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+
+	for _, host := range []string{"localhost", "127.0.0.1"} {
+		if _, e := cert.Leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: pool}); e != nil {
+			t.Errorf("verifying for %s: %v", host, e)
+		}
+	}
+
+	var he x509.HostnameError
+	if _, e := cert.Leaf.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: pool}); !errors.As(e, &he) {
+		t.Errorf("verifying for example.com: %v, want a host name error", e)
+	}
+}
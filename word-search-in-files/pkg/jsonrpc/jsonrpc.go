@@ -0,0 +1,260 @@
+// Package jsonrpc is a JSON-RPC 2.0 server over HTTP and over stream connections
+// such as TCP, one message per line. Batches are supported, and a method can send
+// notifications to the caller while it runs, to stream its results.
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const Version = "2.0"
+
+// The error codes of the specification, the ones from -32000 to -32099 are left to
+// the applications
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// ErrNoNotifications is returned by Notify when the transport of the request can not
+// carry notifications
+var ErrNoNotifications = errors.New("the transport does not carry notifications")
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// Errorf returns the error of the code with the formatted message
+func Errorf(code int, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+type Request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	// Absent for a notification, which gets no response
+	ID json.RawMessage `json:"id,omitempty"`
+}
+
+type Response struct {
+	Result any
+	Error  *Error
+	ID     json.RawMessage
+}
+
+// MarshalJSON writes either the result, null included, or the error
+func (r Response) MarshalJSON() ([]byte, error) {
+	id := r.ID
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	if r.Error != nil {
+		return json.Marshal(struct {
+			Version string          `json:"jsonrpc"`
+			Error   *Error          `json:"error"`
+			ID      json.RawMessage `json:"id"`
+		}{Version, r.Error, id})
+	}
+
+	return json.Marshal(struct {
+		Version string          `json:"jsonrpc"`
+		Result  any             `json:"result"`
+		ID      json.RawMessage `json:"id"`
+	}{Version, r.Result, id})
+}
+
+// Notification is a message of the server to the caller, without a response
+type Notification struct {
+	Version string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// Handler runs a method. An *Error is returned as is, the other errors as internal
+// errors
+type Handler func(ctx context.Context, params json.RawMessage) (any, error)
+
+type Server struct {
+	methods map[string]Handler
+	// Requests of a batch beyond the number are rejected, 0 does not limit
+	MaxBatch int
+}
+
+func NewServer() *Server {
+	return &Server{methods: make(map[string]Handler), MaxBatch: 100}
+}
+
+// Register adds the method, replacing the one of the same name
+func (s *Server) Register(method string, h Handler) {
+	s.methods[method] = h
+}
+
+type ctxKey int
+
+const (
+	notifierKey ctxKey = iota
+	idKey
+)
+
+// notifier sends a notification to the caller of the request
+type notifier func(Notification) error
+
+// Notify sends a notification to the caller of the method running with ctx
+func Notify(ctx context.Context, method string, params any) error {
+	n, _ := ctx.Value(notifierKey).(notifier)
+	if n == nil {
+		return ErrNoNotifications
+	}
+
+	return n(Notification{Version: Version, Method: method, Params: params})
+}
+
+// CanNotify reports whether Notify can reach the caller of the method
+func CanNotify(ctx context.Context) bool {
+	n, _ := ctx.Value(notifierKey).(notifier)
+	return n != nil
+}
+
+// RequestID returns the id of the request the method runs for, to tie its
+// notifications to it
+func RequestID(ctx context.Context) json.RawMessage {
+	id, _ := ctx.Value(idKey).(json.RawMessage)
+	return id
+}
+
+// handle runs the message, a request or a batch, and returns the encoded reply, nil
+// when there is none
+func (s *Server) handle(ctx context.Context, msg []byte, notify notifier) []byte {
+	if notify != nil {
+		ctx = context.WithValue(ctx, notifierKey, notify)
+	}
+
+	msg = bytes.TrimSpace(msg)
+
+	if len(msg) == 0 || msg[0] != '[' {
+		res, ok := s.call(ctx, msg)
+		if !ok {
+			return nil
+		}
+
+		return mustMarshal(res)
+	}
+
+	var batch []json.RawMessage
+	if e := json.Unmarshal(msg, &batch); e != nil {
+		return mustMarshal(Response{Error: Errorf(CodeParseError, "parse error: %s", e)})
+	}
+
+	if len(batch) == 0 {
+		return mustMarshal(Response{Error: Errorf(CodeInvalidRequest, "empty batch")})
+	}
+
+	if s.MaxBatch > 0 && len(batch) > s.MaxBatch {
+		return mustMarshal(Response{Error: Errorf(CodeInvalidRequest, "batch of %d requests, the limit is %d", len(batch), s.MaxBatch)})
+	}
+
+	var responses []Response
+	for _, m := range batch {
+		if res, ok := s.call(ctx, m); ok {
+			responses = append(responses, res)
+		}
+	}
+
+	// Only notifications
+	if len(responses) == 0 {
+		return nil
+	}
+
+	return mustMarshal(responses)
+}
+
+// call runs a single request, ok is false for a notification
+func (s *Server) call(ctx context.Context, msg json.RawMessage) (res Response, ok bool) {
+	var req Request
+	if e := json.Unmarshal(msg, &req); e != nil {
+		var syntax *json.SyntaxError
+		if errors.As(e, &syntax) {
+			return Response{Error: Errorf(CodeParseError, "parse error: %s", e)}, true
+		}
+		return Response{Error: Errorf(CodeInvalidRequest, "invalid request: %s", e)}, true
+	}
+
+	if req.Version != Version || req.Method == "" || !validID(req.ID) {
+		return Response{Error: Errorf(CodeInvalidRequest, "invalid request"), ID: req.ID}, true
+	}
+
+	notification := len(req.ID) == 0
+
+	h, found := s.methods[req.Method]
+	if !found {
+		return Response{Error: Errorf(CodeMethodNotFound, "method %q not found", req.Method), ID: req.ID}, !notification
+	}
+
+	ctx = context.WithValue(ctx, idKey, req.ID)
+
+	result, e := h(ctx, req.Params)
+	if e != nil {
+		var rpcErr *Error
+		if !errors.As(e, &rpcErr) {
+			rpcErr = &Error{Code: CodeInternalError, Message: e.Error()}
+		}
+
+		return Response{Error: rpcErr, ID: req.ID}, !notification
+	}
+
+	return Response{Result: result, ID: req.ID}, !notification
+}
+
+// validID accepts no id, a string, a number or null
+func validID(id json.RawMessage) bool {
+	if len(id) == 0 {
+		return true
+	}
+
+	switch id[0] {
+	case '"', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return true
+	}
+
+	return false
+}
+
+func mustMarshal(v any) []byte {
+	data, e := json.Marshal(v)
+	if e != nil {
+		data, _ = json.Marshal(Response{Error: Errorf(CodeInternalError, "encoding the response: %s", e)})
+	}
+
+	return data
+}
+
+// DecodeParams decodes the params of a method into v, unknown fields are invalid
+func DecodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.DisallowUnknownFields()
+
+	if e := dec.Decode(v); e != nil {
+		return Errorf(CodeInvalidParams, "invalid params: %s", e)
+	}
+
+	return nil
+}
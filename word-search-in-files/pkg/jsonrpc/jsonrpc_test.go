@@ -0,0 +1,185 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testServer() *Server {
+	s := NewServer()
+
+	s.Register("sum", func(ctx context.Context, params json.RawMessage) (any, error) {
+		var p []int
+		if e := DecodeParams(params, &p); e != nil {
+			return nil, e
+		}
+
+		sum := 0
+		for _, n := range p {
+			sum += n
+		}
+
+		return sum, nil
+	})
+
+	s.Register("count", func(ctx context.Context, params json.RawMessage) (any, error) {
+		var p struct {
+			To int `json:"to"`
+		}
+		if e := DecodeParams(params, &p); e != nil {
+			return nil, e
+		}
+
+		for i := 1; i <= p.To; i++ {
+			if e := Notify(ctx, "count.tick", map[string]any{"id": RequestID(ctx), "n": i}); e != nil {
+				return nil, e
+			}
+		}
+
+		return p.To, nil
+	})
+
+	return s
+}
+
+func TestServer_Handle(t *testing.T) {
+	s := testServer()
+
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{name: "Single", msg: `{"jsonrpc":"2.0","method":"sum","params":[1,2,3],"id":1}`, want: `{"jsonrpc":"2.0","result":6,"id":1}`},
+		{name: "String id", msg: `{"jsonrpc":"2.0","method":"sum","params":[],"id":"a"}`, want: `{"jsonrpc":"2.0","result":0,"id":"a"}`},
+		{name: "Notification", msg: `{"jsonrpc":"2.0","method":"sum","params":[1]}`, want: ``},
+		{name: "Batch", msg: `[{"jsonrpc":"2.0","method":"sum","params":[1],"id":1},{"jsonrpc":"2.0","method":"sum"},{"jsonrpc":"2.0","method":"nope","id":2}]`,
+			want: `[{"jsonrpc":"2.0","result":1,"id":1},{"jsonrpc":"2.0","error":{"code":-32601,"message":"method \"nope\" not found"},"id":2}]`},
+		{name: "Only notifications", msg: `[{"jsonrpc":"2.0","method":"sum"}]`, want: ``},
+		{name: "E: parse", msg: `{"jsonrpc":`, want: `"code":-32700`},
+		{name: "E: empty batch", msg: `[]`, want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"empty batch"},"id":null}`},
+		{name: "E: version", msg: `{"jsonrpc":"1.0","method":"sum","id":1}`, want: `{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"},"id":1}`},
+		{name: "E: id", msg: `{"jsonrpc":"2.0","method":"sum","id":{}}`, want: `"code":-32600`},
+		{name: "E: params", msg: `{"jsonrpc":"2.0","method":"sum","params":{"a":1},"id":1}`, want: `"code":-32602`},
+		{name: "E: no notifications", msg: `{"jsonrpc":"2.0","method":"count","params":{"to":1},"id":1}`, want: `"code":-32603`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(s.handle(context.Background(), []byte(tt.msg), nil))
+
+			if tt.want == "" || tt.want[0] == '{' || tt.want[0] == '[' {
+				if got != tt.want {
+					t.Errorf("got %s, want %s", got, tt.want)
+				}
+			} else if !strings.Contains(got, tt.want) {
+				t.Errorf("got %s, want %s in it", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_ServeHTTP(t *testing.T) {
+	srv := httptest.NewServer(testServer())
+	defer srv.Close()
+
+	post := func(accept, body string) (*http.Response, []string) {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		resp, e := http.DefaultClient.Do(req)
+		if e != nil {
+			t.Fatal(e)
+		}
+		defer resp.Body.Close()
+
+		var lines []string
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+		}
+
+		return resp, lines
+	}
+
+	resp, lines := post("", `{"jsonrpc":"2.0","method":"sum","params":[2,2],"id":1}`)
+	if resp.StatusCode != http.StatusOK || len(lines) != 1 || lines[0] != `{"jsonrpc":"2.0","result":4,"id":1}` {
+		t.Errorf("single: status %d, lines %q", resp.StatusCode, lines)
+	}
+
+	resp, _ = post("", `{"jsonrpc":"2.0","method":"sum","params":[2,2]}`)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("notification: status %d, want 204", resp.StatusCode)
+	}
+
+	resp, lines = post("application/x-ndjson", `{"jsonrpc":"2.0","method":"count","params":{"to":2},"id":7}`)
+	want := []string{
+		`{"jsonrpc":"2.0","method":"count.tick","params":{"id":7,"n":1}}`,
+		`{"jsonrpc":"2.0","method":"count.tick","params":{"id":7,"n":2}}`,
+		`{"jsonrpc":"2.0","result":2,"id":7}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("stream: got %q, want %q", lines, want)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/x-ndjson") {
+		t.Errorf("stream: Content-Type %q", ct)
+	}
+
+	resp2, e := http.Get(srv.URL)
+	if e != nil {
+		t.Fatal(e)
+	}
+	body, _ := io.ReadAll(resp2.Body)
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusMethodNotAllowed || !strings.HasPrefix(string(body), `{"jsonrpc":"2.0","error":{"code":-32600,`) || !strings.HasSuffix(string(body), `"id":null}`+"\n") {
+		t.Errorf("GET: status %d, body %q, want 405 and an invalid request", resp2.StatusCode, body)
+	}
+
+	resp, lines = post("", `{"jsonrpc":"2.0","method":"sum","params":"`+strings.Repeat("x", MaxMessageSize)+`","id":1}`)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge || len(lines) != 1 || !strings.HasPrefix(lines[0], `{"jsonrpc":"2.0","error":{"code":-32600,`) {
+		t.Errorf("too large: status %d, lines %q, want 413 and an invalid request", resp.StatusCode, lines)
+	}
+}
+
+func TestServer_ServeConn(t *testing.T) {
+	client, conn := net.Pipe()
+	defer client.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- testServer().ServeConn(context.Background(), conn) }()
+
+	go func() {
+		_, _ = client.Write([]byte(`{"jsonrpc":"2.0","method":"count","params":{"to":2},"id":"c"}` + "\n"))
+	}()
+
+	sc := bufio.NewScanner(client)
+
+	var got []string
+	for len(got) < 3 && sc.Scan() {
+		got = append(got, sc.Text())
+	}
+
+	want := []string{
+		`{"jsonrpc":"2.0","method":"count.tick","params":{"id":"c","n":1}}`,
+		`{"jsonrpc":"2.0","method":"count.tick","params":{"id":"c","n":2}}`,
+		`{"jsonrpc":"2.0","result":2,"id":"c"}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	client.Close()
+	if e := <-done; e != nil {
+		t.Errorf("ServeConn: %s", e)
+	}
+}
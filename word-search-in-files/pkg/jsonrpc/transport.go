@@ -0,0 +1,178 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// Largest message accepted, a request or a batch
+	MaxMessageSize = 1 << 20
+	// Requests of a connection running at once, the next ones wait to be read
+	maxConnRequests = 16
+)
+
+// ServeHTTP runs the message of the POST body. The notifications need the caller to
+// accept application/x-ndjson: they are then written one per line before the response.
+// The requests the server can not read are replied with an invalid request error
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		httpError(w, http.StatusMethodNotAllowed, Errorf(CodeInvalidRequest, "only POST method is allowed"))
+		return
+	}
+
+	msg, e := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxMessageSize))
+	if e != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(e, &tooLarge) {
+			httpError(w, http.StatusRequestEntityTooLarge, Errorf(CodeInvalidRequest, "message larger than %d bytes", MaxMessageSize))
+		} else {
+			httpError(w, http.StatusBadRequest, Errorf(CodeInvalidRequest, "reading the message: %s", e))
+		}
+		return
+	}
+
+	var notify notifier
+	started := false
+
+	contentType := "application/json; charset=utf-8"
+
+	if strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		contentType = "application/x-ndjson; charset=utf-8"
+		rc := http.NewResponseController(w)
+
+		notify = func(n Notification) error {
+			if !started {
+				w.Header().Set("Content-Type", contentType)
+				w.WriteHeader(http.StatusOK)
+				started = true
+			}
+
+			if _, e := w.Write(append(mustMarshal(n), '\n')); e != nil {
+				return e
+			}
+
+			return rc.Flush()
+		}
+	}
+
+	reply := s.handle(r.Context(), msg, notify)
+
+	if !started {
+		if reply == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if reply != nil {
+		_, _ = w.Write(append(reply, '\n'))
+	}
+}
+
+// httpError replies with the error of a request without an id
+func httpError(w http.ResponseWriter, status int, e *Error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(mustMarshal(Response{Error: e}), '\n'))
+}
+
+// ServeConn reads the messages of the connection, one per line, and writes the
+// replies and the notifications the same way, in the order they are ready. It
+// returns when the connection is closed or ctx is done
+func (s *Server) ServeConn(ctx context.Context, conn io.ReadWriteCloser) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	var mu sync.Mutex
+	write := func(data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		_, e := conn.Write(append(data, '\n'))
+		return e
+	}
+
+	notify := func(n Notification) error {
+		return write(mustMarshal(n))
+	}
+
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 0, 64*1024), MaxMessageSize)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	slots := make(chan struct{}, maxConnRequests)
+
+	for sc.Scan() {
+		msg := append([]byte(nil), sc.Bytes()...)
+		if len(strings.TrimSpace(string(msg))) == 0 {
+			continue
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			if reply := s.handle(ctx, msg, notify); reply != nil {
+				if e := write(reply); e != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	if e := sc.Err(); e != nil && ctx.Err() == nil && !errors.Is(e, net.ErrClosed) {
+		if errors.Is(e, bufio.ErrTooLong) {
+			_ = write(mustMarshal(Response{Error: Errorf(CodeInvalidRequest, "message larger than %d bytes", MaxMessageSize)}))
+		}
+		return e
+	}
+
+	return nil
+}
+
+// Serve accepts the connections of the listener until it is closed. connContext,
+// when set, derives the context of the requests of a connection
+func (s *Server) Serve(ln net.Listener, connContext func(context.Context, net.Conn) context.Context) error {
+	for {
+		conn, e := ln.Accept()
+		if e != nil {
+			if errors.Is(e, net.ErrClosed) {
+				return nil
+			}
+			return e
+		}
+
+		// connContext may block on the handshake, not the other connections
+		go func() {
+			ctx := context.Background()
+			if connContext != nil {
+				ctx = connContext(ctx, conn)
+			}
+
+			_ = s.ServeConn(ctx, conn)
+		}()
+	}
+}
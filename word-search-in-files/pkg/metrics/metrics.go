@@ -0,0 +1,426 @@
+// Package metrics is a tiny, dependency free implementation of counters,
+// gauges and histograms that can be exposed in the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets are the default histogram buckets (seconds), the same as the Prometheus client uses
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+type Registry struct {
+	mu      sync.Mutex
+	names   map[string]struct{}
+	metrics []metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[string]struct{}),
+	}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.names[name]; ok {
+		panic(fmt.Sprintf("metrics: %q is already registered", name))
+	}
+
+	r.names[name] = struct{}{}
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo writes all registered metrics in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := make([]metric, len(r.metrics))
+	copy(metrics, r.metrics)
+	r.mu.Unlock()
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	for _, m := range metrics {
+		m.write(bw)
+	}
+
+	e := bw.Flush()
+
+	return cw.n, e
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+
+	_, _ = r.WriteTo(w)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, e := c.w.Write(p)
+	c.n += int64(n)
+	return n, e
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	bits uint64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add increases the counter, negative values are ignored
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+
+	addFloat(&c.bits, v)
+}
+
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&c.bits))
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	bits uint64
+}
+
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+func (g *Gauge) Add(v float64) {
+	addFloat(&g.bits, v)
+}
+
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+func addFloat(bits *uint64, v float64) {
+	for {
+		old := atomic.LoadUint64(bits)
+		updated := math.Float64bits(math.Float64frombits(old) + v)
+
+		if atomic.CompareAndSwapUint64(bits, old, updated) {
+			return
+		}
+	}
+}
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+
+	b := make([]float64, len(buckets))
+	copy(b, buckets)
+	sort.Float64s(b)
+
+	return &Histogram{
+		buckets: b,
+		counts:  make([]uint64, len(b)),
+	}
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+
+	h.sum += v
+	h.count++
+}
+
+type simple struct {
+	name, help, typ string
+	value           func() float64
+}
+
+func (s *simple) write(w *bufio.Writer) {
+	writeHeader(w, s.name, s.help, s.typ)
+	writeSample(w, s.name, "", s.value())
+}
+
+// NewCounter registers a new counter
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	r.register(name, &simple{name: name, help: help, typ: "counter", value: c.Value})
+	return c
+}
+
+// NewCounterFunc registers a counter whose value is taken from fn on every scrape
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(name, &simple{name: name, help: help, typ: "counter", value: fn})
+}
+
+// NewGauge registers a new gauge
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	r.register(name, &simple{name: name, help: help, typ: "gauge", value: g.Value})
+	return g
+}
+
+// NewGaugeFunc registers a gauge whose value is taken from fn on every scrape
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &simple{name: name, help: help, typ: "gauge", value: fn})
+}
+
+// NewHistogram registers a new histogram, nil buckets means DefBuckets
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := newHistogram(buckets)
+	r.register(name, &histogramMetric{name: name, help: help, h: h})
+	return h
+}
+
+type histogramMetric struct {
+	name, help string
+	h          *Histogram
+}
+
+func (m *histogramMetric) write(w *bufio.Writer) {
+	writeHeader(w, m.name, m.help, "histogram")
+	writeHistogram(w, m.name, "", m.h)
+}
+
+func writeHistogram(w *bufio.Writer, name, labels string, h *Histogram) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+
+	for i, upper := range h.buckets {
+		le := `le="` + formatFloat(upper) + `"`
+		writeSample(w, name+"_bucket", labels+sep+le, float64(h.counts[i]))
+	}
+
+	writeSample(w, name+"_bucket", labels+sep+`le="+Inf"`, float64(h.count))
+	writeSample(w, name+"_sum", labels, h.sum)
+	writeSample(w, name+"_count", labels, float64(h.count))
+}
+
+// vec is a set of metrics of the same name partitioned by label values
+type vec struct {
+	name, help, typ string
+	labels          []string
+
+	mu       sync.Mutex
+	children map[string]any
+	newChild func() any
+}
+
+func (v *vec) with(values []string) any {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %q expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+
+	pairs := make([]string, len(values))
+	for i, value := range values {
+		pairs[i] = v.labels[i] + `="` + escapeLabel(value) + `"`
+	}
+	key := strings.Join(pairs, ",")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	child, ok := v.children[key]
+	if !ok {
+		child = v.newChild()
+		v.children[key] = child
+	}
+
+	return child
+}
+
+func (v *vec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	v.mu.Unlock()
+
+	// Stable output makes the exposition easier to diff
+	sort.Strings(keys)
+
+	writeHeader(w, v.name, v.help, v.typ)
+
+	for _, key := range keys {
+		v.mu.Lock()
+		child := v.children[key]
+		v.mu.Unlock()
+
+		switch c := child.(type) {
+		case *Counter:
+			writeSample(w, v.name, key, c.Value())
+		case *Gauge:
+			writeSample(w, v.name, key, c.Value())
+		case *Histogram:
+			writeHistogram(w, v.name, key, c)
+		}
+	}
+}
+
+type CounterVec struct{ v *vec }
+
+// NewCounterVec registers a counter partitioned by the given labels
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &vec{name: name, help: help, typ: "counter", labels: labels, children: make(map[string]any)}
+	v.newChild = func() any { return &Counter{} }
+	r.register(name, v)
+	return &CounterVec{v: v}
+}
+
+// With returns the counter for the given label values (in the order of the labels)
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.v.with(values).(*Counter)
+}
+
+type GaugeVec struct{ v *vec }
+
+// NewGaugeVec registers a gauge partitioned by the given labels
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	v := &vec{name: name, help: help, typ: "gauge", labels: labels, children: make(map[string]any)}
+	v.newChild = func() any { return &Gauge{} }
+	r.register(name, v)
+	return &GaugeVec{v: v}
+}
+
+// With returns the gauge for the given label values (in the order of the labels)
+func (g *GaugeVec) With(values ...string) *Gauge {
+	return g.v.with(values).(*Gauge)
+}
+
+// funcVec is a metric partitioned by a single label whose values are taken on every scrape
+type funcVec struct {
+	name, help, typ string
+	label           string
+	values          func() map[string]float64
+}
+
+func (v *funcVec) write(w *bufio.Writer) {
+	values := v.values()
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	writeHeader(w, v.name, v.help, v.typ)
+
+	for _, key := range keys {
+		writeSample(w, v.name, v.label+`="`+escapeLabel(key)+`"`, values[key])
+	}
+}
+
+// NewGaugeVecFunc registers a gauge partitioned by the label whose values, by label
+// value, are taken from fn on every scrape
+func (r *Registry) NewGaugeVecFunc(name, help, label string, fn func() map[string]float64) {
+	r.register(name, &funcVec{name: name, help: help, typ: "gauge", label: label, values: fn})
+}
+
+type HistogramVec struct{ v *vec }
+
+// NewHistogramVec registers a histogram partitioned by the given labels
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	v := &vec{name: name, help: help, typ: "histogram", labels: labels, children: make(map[string]any)}
+	v.newChild = func() any { return newHistogram(buckets) }
+	r.register(name, v)
+	return &HistogramVec{v: v}
+}
+
+// With returns the histogram for the given label values (in the order of the labels)
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.v.with(values).(*Histogram)
+}
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	if help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name)
+
+	if labels != "" {
+		w.WriteByte('{')
+		w.WriteString(labels)
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
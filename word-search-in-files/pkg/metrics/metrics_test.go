@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.NewCounter("test_total", "Total things.")
+	c.Add(2)
+	c.Inc()
+	c.Add(-5)
+
+	g := r.NewGauge("test_gauge", "")
+	g.Set(1.5)
+
+	v := r.NewCounterVec("test_requests_total", "Requests.", "code")
+	v.With("500").Inc()
+	v.With("200").Add(2)
+
+	h := r.NewHistogram("test_seconds", "Latency.", []float64{1, 0.1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	r.NewGaugeVecFunc("test_skipped", "Skipped.", "reason", func() map[string]float64 {
+		return map[string]float64{"symlink": 2, "cycle": 1}
+	})
+
+	sb := &strings.Builder{}
+	if _, e := r.WriteTo(sb); e != nil {
+		t.Fatalf("WriteTo() error = %v", e)
+	}
+
+	want := `# HELP test_total Total things.
+# TYPE test_total counter
+test_total 3
+# TYPE test_gauge gauge
+test_gauge 1.5
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{code="200"} 2
+test_requests_total{code="500"} 1
+# HELP test_seconds Latency.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 1
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 5.55
+test_seconds_count 3
+# HELP test_skipped Skipped.
+# TYPE test_skipped gauge
+test_skipped{reason="cycle"} 1
+test_skipped{reason="symlink"} 2
+`
+
+	if got := sb.String(); got != want {
+		t.Errorf("WriteTo() got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -10,6 +10,7 @@ type Pool interface {
 	Stop()
 	StopForce()
 	AddWork(Task)
+	QueueLen() int
 }
 
 type Task interface {
@@ -108,6 +109,11 @@ func (p *WorkingPool) AddWork(t Task) {
 	}
 }
 
+// QueueLen returns the number of tasks waiting for a free worker
+func (p *WorkingPool) QueueLen() int {
+	return len(p.tasks)
+}
+
 // AddWorkNonBlocking adds work to the WorkingPool and returns immediately
 // func (p *WorkingPool) AddWorkNonBlocking(t Task) {
 // go p.AddWork(t)
@@ -0,0 +1,167 @@
+// Package ratelimit bounds the load the clients put on a server: token buckets per
+// client key limit the request rate, a Gate caps the requests served at once.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Idle buckets are dropped at this interval, a full bucket is the same as none
+const sweepInterval = time.Minute
+
+var ErrQueueTimeout = errors.New("timed out waiting for a free slot")
+
+// Limiter keeps a token bucket per key: rate tokens are added per second up to
+// burst, every request takes one
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	// For the tests
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns the limiter of the rate per second with the burst, a burst
+// below 1 is 1
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   math.Max(float64(burst), 1),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Rate returns the tokens added per second
+func (l *Limiter) Rate() float64 {
+	return l.rate
+}
+
+// Burst returns the size of the buckets
+func (l *Limiter) Burst() int {
+	return int(l.burst)
+}
+
+// Allow takes a token from the bucket of the key. Without one it returns false and
+// the time until the next one
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	if l.rate <= 0 {
+		return false, time.Duration(math.MaxInt64)
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+
+	return false, wait
+}
+
+// Clients returns the number of the keys with a bucket
+func (l *Limiter) Clients() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+// sweep drops the buckets refilled by now, the caller must hold mu
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Gate lets at most n holders in at once, the others wait in line
+type Gate struct {
+	slots   chan struct{}
+	waiting atomic.Int64
+}
+
+func NewGate(n int) *Gate {
+	return &Gate{slots: make(chan struct{}, n)}
+}
+
+// Acquire waits for a slot up to the timeout, 0 does not wait. The slot must be given
+// back by calling release
+func (g *Gate) Acquire(ctx context.Context, timeout time.Duration) (release func(), e error) {
+	release = func() { <-g.slots }
+
+	select {
+	case g.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if timeout <= 0 {
+		return nil, ErrQueueTimeout
+	}
+
+	g.waiting.Add(1)
+	defer g.waiting.Add(-1)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case g.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Cap returns the number of the slots
+func (g *Gate) Cap() int {
+	return cap(g.slots)
+}
+
+// InFlight returns the number of the slots taken
+func (g *Gate) InFlight() int {
+	return len(g.slots)
+}
+
+// Waiting returns the number of the callers waiting for a slot
+func (g *Gate) Waiting() int {
+	return int(g.waiting.Load())
+}
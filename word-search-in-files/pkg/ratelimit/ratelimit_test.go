@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	l := NewLimiter(2, 3)
+	l.now = func() time.Time { return now }
+
+	steps := []struct {
+		name     string
+		advance  time.Duration
+		key      string
+		want     bool
+		wantWait time.Duration
+	}{
+		{name: "Burst 1", key: "a", want: true},
+		{name: "Burst 2", key: "a", want: true},
+		{name: "Burst 3", key: "a", want: true},
+		{name: "Empty", key: "a", want: false, wantWait: 500 * time.Millisecond},
+		{name: "Other key", key: "b", want: true},
+		{name: "Half a token", advance: 250 * time.Millisecond, key: "a", want: false, wantWait: 250 * time.Millisecond},
+		{name: "Refilled", advance: 250 * time.Millisecond, key: "a", want: true},
+	}
+
+	for _, st := range steps {
+		now = now.Add(st.advance)
+
+		got, wait := l.Allow(st.key)
+		if got != st.want || wait != st.wantWait {
+			t.Errorf("%s: Allow() = %v, %v, want %v, %v", st.name, got, wait, st.want, st.wantWait)
+		}
+	}
+
+	// Both buckets are full again and dropped
+	now = now.Add(time.Hour)
+	l.Allow("c")
+
+	if n := l.Clients(); n != 1 {
+		t.Errorf("Clients() = %d, want 1", n)
+	}
+}
+
+func TestGate(t *testing.T) {
+	g := NewGate(1)
+
+	release, e := g.Acquire(context.Background(), 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if _, e := g.Acquire(context.Background(), 0); !errors.Is(e, ErrQueueTimeout) {
+		t.Errorf("Acquire() without waiting error = %v, want %v", e, ErrQueueTimeout)
+	}
+
+	if _, e := g.Acquire(context.Background(), 10*time.Millisecond); !errors.Is(e, ErrQueueTimeout) {
+		t.Errorf("Acquire() error = %v, want %v", e, ErrQueueTimeout)
+	}
+
+	done := make(chan error)
+	go func() {
+		release, e := g.Acquire(context.Background(), time.Minute)
+		if e == nil {
+			release()
+		}
+		done <- e
+	}()
+
+	for g.Waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	release()
+
+	if e := <-done; e != nil {
+		t.Errorf("queued Acquire() error = %v", e)
+	}
+
+	if g.InFlight() != 0 || g.Waiting() != 0 {
+		t.Errorf("in flight %d, waiting %d after the releases", g.InFlight(), g.Waiting())
+	}
+}
@@ -0,0 +1,163 @@
+package remotefs
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// The links of an HTML page: the value of the href attribute of the anchors
+var hrefRe = regexp.MustCompile(`(?is)<a\s[^>]*?href\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+
+type HTTPConfig struct {
+	// URL of the listed directory
+	URL string
+
+	Client *http.Client
+}
+
+// httpBackend reads the HTML directory listings of a web server, the ones of Apache,
+// nginx autoindex or `python -m http.server`. The links to the children of a
+// directory are its entries, the ones with a trailing slash are directories. The
+// size and the version of a file come from a HEAD request
+type httpBackend struct {
+	base   *url.URL
+	client *http.Client
+}
+
+// NewHTTP returns the file system of the directory listing
+func NewHTTP(cfg HTTPConfig) (*FS, error) {
+	base, e := url.Parse(cfg.URL)
+	if e != nil {
+		return nil, fmt.Errorf("http: invalid URL: %w", e)
+	}
+
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	base.RawQuery, base.Fragment = "", ""
+
+	return &FS{b: &httpBackend{base: base, client: defaultClient(cfg.Client)}}, nil
+}
+
+func (b *httpBackend) url(name string, dir bool) *url.URL {
+	u := *b.base
+
+	if name != "." {
+		u.Path += name
+		if dir {
+			u.Path += "/"
+		}
+	}
+
+	return &u
+}
+
+func (b *httpBackend) stat(ctx context.Context, name string) (*entry, error) {
+	if name == "." {
+		return rootEntry(), nil
+	}
+
+	req, e := http.NewRequestWithContext(ctx, http.MethodHead, b.url(name, false).String(), nil)
+	if e != nil {
+		return nil, e
+	}
+
+	resp, e := do(b.client, req)
+	if e != nil {
+		return nil, e
+	}
+	resp.Body.Close()
+
+	// The servers redirect a directory without the trailing slash to the listing
+	if strings.HasSuffix(resp.Request.URL.Path, "/") {
+		return &entry{name: path.Base(name), dir: true}, nil
+	}
+
+	return fileEntry(name, resp), nil
+}
+
+func (b *httpBackend) list(ctx context.Context, dir string) ([]*entry, error) {
+	u := b.url(dir, true)
+
+	req, e := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if e != nil {
+		return nil, e
+	}
+
+	resp, e := do(b.client, req)
+	if e != nil {
+		return nil, e
+	}
+	defer resp.Body.Close()
+
+	page, e := io.ReadAll(resp.Body)
+	if e != nil {
+		return nil, e
+	}
+
+	// The links are relative to the URL of the listing after the redirects
+	u = resp.Request.URL
+
+	seen := make(map[string]struct{})
+	var entries []*entry
+
+	for _, m := range hrefRe.FindAllSubmatch(page, -1) {
+		href := html.UnescapeString(string(m[1]) + string(m[2]) + string(m[3]))
+
+		ref, e := url.Parse(href)
+		if e != nil {
+			continue
+		}
+
+		target := u.ResolveReference(ref)
+		if target.Host != u.Host || !strings.HasPrefix(target.Path, u.Path) {
+			continue
+		}
+
+		// Only the children, not the parent, the sort links or the descendants
+		rest := strings.TrimPrefix(target.Path, u.Path)
+		name := strings.TrimSuffix(rest, "/")
+		if name == "" || strings.Contains(name, "/") || target.RawQuery != "" {
+			continue
+		}
+
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		if strings.HasSuffix(rest, "/") {
+			entries = append(entries, &entry{name: name, dir: true})
+			continue
+		}
+
+		child := path.Join(dir, name)
+		entries = append(entries, &entry{
+			name: name,
+			load: func() (*entry, error) { return b.stat(ctx, child) },
+		})
+	}
+
+	return entries, nil
+}
+
+func (b *httpBackend) get(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, e := http.NewRequestWithContext(ctx, http.MethodGet, b.url(name, false).String(), nil)
+	if e != nil {
+		return nil, e
+	}
+
+	resp, e := do(b.client, req)
+	if e != nil {
+		return nil, e
+	}
+
+	return resp.Body, nil
+}
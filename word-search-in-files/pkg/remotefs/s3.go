@@ -0,0 +1,347 @@
+package remotefs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Payload hash of the requests without a body the signature does not cover
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+type S3Config struct {
+	// Base URL of the service, https://s3.<region>.amazonaws.com when empty
+	Endpoint string
+	// us-east-1 when empty
+	Region string
+	Bucket string
+	// Key prefix the file system is rooted at
+	Prefix string
+
+	// Anonymous requests without the keys
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	// The bucket in the path of the requests instead of the host name, as MinIO and
+	// most of the compatible stores expect
+	PathStyle bool
+
+	Client *http.Client
+}
+
+type s3Backend struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	// Prefix of the keys with the trailing slash, empty for the whole bucket
+	prefix string
+	// For the tests
+	now func() time.Time
+}
+
+// NewS3 returns the file system of the keys of the bucket under the prefix, the
+// slashes of the keys separate the directories
+func NewS3(cfg S3Config) (*FS, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3: no bucket")
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+
+	endpoint, e := url.Parse(cfg.Endpoint)
+	if e != nil {
+		return nil, fmt.Errorf("s3: invalid endpoint: %w", e)
+	}
+
+	b := &s3Backend{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   defaultClient(cfg.Client),
+		now:      time.Now,
+	}
+
+	if p := strings.Trim(cfg.Prefix, "/"); p != "" {
+		b.prefix = p + "/"
+	}
+
+	return &FS{b: b}, nil
+}
+
+func (b *s3Backend) key(name string) string {
+	if name == "." {
+		return b.prefix
+	}
+
+	return b.prefix + name
+}
+
+func (b *s3Backend) stat(ctx context.Context, name string) (*entry, error) {
+	if name == "." {
+		return rootEntry(), nil
+	}
+
+	resp, e := b.do(ctx, http.MethodHead, b.key(name), nil)
+	if e == nil {
+		resp.Body.Close()
+		return fileEntry(name, resp), nil
+	}
+
+	if !errors.Is(e, fs.ErrNotExist) {
+		return nil, e
+	}
+
+	// No such object, a directory is a common prefix of the keys
+	res, e := b.listPage(ctx, b.key(name)+"/", "", 1)
+	if e != nil {
+		return nil, e
+	}
+
+	if len(res.Contents) == 0 && len(res.CommonPrefixes) == 0 {
+		return nil, fs.ErrNotExist
+	}
+
+	return &entry{name: path.Base(name), dir: true}, nil
+}
+
+func (b *s3Backend) list(ctx context.Context, dir string) ([]*entry, error) {
+	prefix := b.prefix
+	if dir != "." {
+		prefix = b.key(dir) + "/"
+	}
+
+	var res []*entry
+
+	token := ""
+	for {
+		page, e := b.listPage(ctx, prefix, token, 0)
+		if e != nil {
+			return nil, e
+		}
+
+		for _, obj := range page.Contents {
+			name := strings.TrimPrefix(obj.Key, prefix)
+			// The marker object of the directory itself
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+
+			info := &ObjectInfo{ETag: strings.Trim(obj.ETag, `"`), LastModified: obj.LastModified.Truncate(time.Second).UTC(), Size: obj.Size}
+			res = append(res, &entry{name: name, size: obj.Size, modTime: info.LastModified, object: info})
+		}
+
+		for _, p := range page.CommonPrefixes {
+			if name := strings.TrimSuffix(strings.TrimPrefix(p.Prefix, prefix), "/"); name != "" {
+				res = append(res, &entry{name: name, dir: true})
+			}
+		}
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return res, nil
+		}
+
+		token = page.NextContinuationToken
+	}
+}
+
+func (b *s3Backend) get(ctx context.Context, name string) (io.ReadCloser, error) {
+	resp, e := b.do(ctx, http.MethodGet, b.key(name), nil)
+	if e != nil {
+		return nil, e
+	}
+
+	return resp.Body, nil
+}
+
+// listBucketResult is the response of ListObjectsV2
+type listBucketResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		LastModified time.Time
+		ETag         string
+		Size         int64
+	}
+	CommonPrefixes []struct {
+		Prefix string
+	}
+}
+
+func (b *s3Backend) listPage(ctx context.Context, prefix, token string, maxKeys int) (*listBucketResult, error) {
+	q := url.Values{
+		"list-type": {"2"},
+		"delimiter": {"/"},
+		"prefix":    {prefix},
+	}
+	if token != "" {
+		q.Set("continuation-token", token)
+	}
+	if maxKeys > 0 {
+		q.Set("max-keys", fmt.Sprint(maxKeys))
+	}
+
+	resp, e := b.do(ctx, http.MethodGet, "", q)
+	if e != nil {
+		return nil, e
+	}
+	defer resp.Body.Close()
+
+	res := &listBucketResult{}
+	if e := xml.NewDecoder(resp.Body).Decode(res); e != nil {
+		return nil, fmt.Errorf("s3: decoding the listing: %w", e)
+	}
+
+	return res, nil
+}
+
+// do sends a signed request for the key of the bucket, an empty key is the bucket
+func (b *s3Backend) do(ctx context.Context, method, key string, query url.Values) (*http.Response, error) {
+	host := b.endpoint.Host
+	uri := strings.TrimSuffix(b.endpoint.Path, "/")
+
+	if b.cfg.PathStyle {
+		uri += "/" + b.cfg.Bucket
+	} else {
+		host = b.cfg.Bucket + "." + host
+	}
+	uri = s3Escape(uri+"/"+key, false)
+
+	rawQuery := canonicalQuery(query)
+
+	target := b.endpoint.Scheme + "://" + host + uri
+	if rawQuery != "" {
+		target += "?" + rawQuery
+	}
+
+	req, e := http.NewRequestWithContext(ctx, method, target, nil)
+	if e != nil {
+		return nil, e
+	}
+
+	if b.cfg.AccessKey != "" {
+		if b.cfg.SessionToken != "" {
+			req.Header.Set("X-Amz-Security-Token", b.cfg.SessionToken)
+		}
+
+		signV4(req, uri, rawQuery, unsignedPayload, b.cfg.AccessKey, b.cfg.SecretKey, b.cfg.Region, b.now())
+	}
+
+	return do(b.client, req)
+}
+
+// signV4 signs the request for S3 with AWS Signature Version 4. The host, the range
+// and the x-amz-* headers are signed
+func signV4(req *http.Request, uri, rawQuery, payloadHash, accessKey, secretKey, region string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if name = strings.ToLower(name); name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method, uri, rawQuery, canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes the query sorted by key, the way the signature expects
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+
+		for _, v := range values {
+			pairs = append(pairs, s3Escape(key, true)+"="+s3Escape(v, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes all but the unreserved characters, and the slashes unless
+// escapeSlash is set
+func s3Escape(s string, escapeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&15])
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,230 @@
+package remotefs
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<propfind xmlns="DAV:"><prop>
+<resourcetype/><getcontentlength/><getlastmodified/><getetag/>
+</prop></propfind>`
+
+type WebDAVConfig struct {
+	// URL of the shared directory
+	URL string
+	// Basic authentication when set
+	Username string
+	Password string
+
+	Client *http.Client
+}
+
+type webdavBackend struct {
+	cfg    WebDAVConfig
+	base   *url.URL
+	client *http.Client
+}
+
+// NewWebDAV returns the file system of the WebDAV collection
+func NewWebDAV(cfg WebDAVConfig) (*FS, error) {
+	base, e := url.Parse(cfg.URL)
+	if e != nil {
+		return nil, fmt.Errorf("webdav: invalid URL: %w", e)
+	}
+
+	base.Path = strings.TrimSuffix(base.Path, "/")
+
+	return &FS{b: &webdavBackend{cfg: cfg, base: base, client: defaultClient(cfg.Client)}}, nil
+}
+
+// url returns the URL of the resource, with a trailing slash for a collection
+func (b *webdavBackend) url(name string, collection bool) string {
+	u := *b.base
+
+	if name != "." {
+		u.Path += "/" + name
+	}
+
+	if collection {
+		u.Path += "/"
+	}
+
+	return u.String()
+}
+
+func (b *webdavBackend) stat(ctx context.Context, name string) (*entry, error) {
+	if name == "." {
+		return rootEntry(), nil
+	}
+
+	res, e := b.propfind(ctx, name, false, "0")
+	if e != nil {
+		return nil, e
+	}
+
+	if len(res) == 0 {
+		return nil, errors.New("webdav: empty PROPFIND response")
+	}
+
+	ent := res[0].entry()
+	ent.name = path.Base(name)
+
+	return ent, nil
+}
+
+func (b *webdavBackend) list(ctx context.Context, dir string) ([]*entry, error) {
+	res, e := b.propfind(ctx, dir, true, "1")
+	if e != nil {
+		return nil, e
+	}
+
+	self := b.base.Path + "/"
+	if dir != "." {
+		self += dir + "/"
+	}
+
+	var entries []*entry
+	for _, r := range res {
+		p := r.path()
+		if strings.TrimSuffix(p, "/") == strings.TrimSuffix(self, "/") || !strings.HasPrefix(p, self) {
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(p, self), "/")
+		if name == "" || strings.Contains(name, "/") {
+			continue
+		}
+
+		e := r.entry()
+		e.name = name
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func (b *webdavBackend) get(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, e := http.NewRequestWithContext(ctx, http.MethodGet, b.url(name, false), nil)
+	if e != nil {
+		return nil, e
+	}
+
+	resp, e := b.do(req)
+	if e != nil {
+		return nil, e
+	}
+
+	return resp.Body, nil
+}
+
+func (b *webdavBackend) propfind(ctx context.Context, name string, collection bool, depth string) ([]davResponse, error) {
+	req, e := http.NewRequestWithContext(ctx, "PROPFIND", b.url(name, collection), strings.NewReader(propfindBody))
+	if e != nil {
+		return nil, e
+	}
+
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, e := b.do(req)
+	if e != nil {
+		return nil, e
+	}
+	defer resp.Body.Close()
+
+	var ms struct {
+		Responses []davResponse `xml:"response"`
+	}
+	if e := xml.NewDecoder(resp.Body).Decode(&ms); e != nil {
+		return nil, fmt.Errorf("webdav: decoding the PROPFIND response: %w", e)
+	}
+
+	return ms.Responses, nil
+}
+
+func (b *webdavBackend) do(req *http.Request) (*http.Response, error) {
+	if b.cfg.Username != "" {
+		req.SetBasicAuth(b.cfg.Username, b.cfg.Password)
+	}
+
+	return do(b.client, req)
+}
+
+// davResponse is a response of a multistatus, the names of the elements match any
+// namespace, the servers use DAV: with various prefixes
+type davResponse struct {
+	Href     string `xml:"href"`
+	Propstat []struct {
+		Prop struct {
+			ResourceType struct {
+				Collection *struct{} `xml:"collection"`
+			} `xml:"resourcetype"`
+			ContentLength string `xml:"getcontentlength"`
+			LastModified  string `xml:"getlastmodified"`
+			ETag          string `xml:"getetag"`
+		} `xml:"prop"`
+		Status string `xml:"status"`
+	} `xml:"propstat"`
+}
+
+// path returns the unescaped path of the href, which may be a full URL
+func (r *davResponse) path() string {
+	if u, e := url.Parse(r.Href); e == nil {
+		return u.Path
+	}
+
+	return r.Href
+}
+
+func (r *davResponse) entry() *entry {
+	e := &entry{}
+
+	for _, ps := range r.Propstat {
+		// "HTTP/1.1 200 OK", the other statuses are of the properties not found
+		if fields := strings.Fields(ps.Status); len(fields) < 2 || fields[1] != "200" {
+			continue
+		}
+
+		p := ps.Prop
+
+		if p.ResourceType.Collection != nil {
+			e.dir = true
+		}
+
+		if p.ContentLength != "" {
+			e.size, _ = strconv.ParseInt(strings.TrimSpace(p.ContentLength), 10, 64)
+		}
+
+		if t, err := http.ParseTime(strings.TrimSpace(p.LastModified)); err == nil {
+			e.modTime = t.UTC()
+		} else if t, err := time.Parse(time.RFC3339, strings.TrimSpace(p.LastModified)); err == nil {
+			e.modTime = t.UTC()
+		}
+
+		if etag := strings.Trim(strings.TrimSpace(p.ETag), `"`); etag != "" && !e.dir {
+			e.object = &ObjectInfo{ETag: etag}
+		}
+	}
+
+	if e.dir {
+		e.size = 0
+		return e
+	}
+
+	if e.object == nil {
+		e.object = &ObjectInfo{}
+	}
+	e.object.LastModified, e.object.Size = e.modTime, e.size
+
+	return e
+}
@@ -0,0 +1,109 @@
+package searcher
+
+import (
+	"slices"
+	"strings"
+)
+
+// analyzer turns the words of the documents of a language into terms: lower case,
+// without the stop words of the language, stemmed
+type analyzer struct {
+	lang      string
+	stopWords map[string]struct{}
+	stem      func(string) string
+}
+
+// Analyzers of the detected languages, the files of no detected language are only
+// lower cased
+var analyzers = func() map[string]*analyzer {
+	res := map[string]*analyzer{
+		"":   {},
+		"en": {lang: "en", stem: stemEnglish},
+		"ru": {lang: "ru", stem: stemRussian},
+	}
+
+	for lang, a := range res {
+		if lang == "" {
+			continue
+		}
+
+		words, _ := StopWords(lang)
+		a.stopWords = make(map[string]struct{}, len(words))
+		for _, word := range words {
+			a.stopWords[strings.ToLower(removePunctuation(word))] = struct{}{}
+		}
+	}
+
+	return res
+}()
+
+// WithAnalyzers indexes the words of every file with the analyzer of its detected
+// language: lower case, the built-in stop words of the language and its stemmer. The
+// query words are looked up as the terms of every analyzer, or of the ones of the
+// languages of the filter
+func WithAnalyzers() Option {
+	return func(s *Searcher) {
+		s.analyzers = true
+	}
+}
+
+// analyzerOf returns the analyzer of the detected language of a file, nil when the
+// words are indexed as they are
+func (s *Searcher) analyzerOf(lang string) *analyzer {
+	if !s.analyzers {
+		return nil
+	}
+
+	if a, ok := analyzers[lang]; ok {
+		return a
+	}
+
+	return analyzers[""]
+}
+
+// term returns the term of the normalized word, false for a stop word
+func (a *analyzer) term(word string) (string, bool) {
+	word = strings.ToLower(word)
+
+	if _, ok := a.stopWords[word]; ok {
+		return "", false
+	}
+
+	if a.stem != nil {
+		word = a.stem(word)
+	}
+
+	return word, true
+}
+
+// variants returns the terms the query word stands for in the files of the languages,
+// of all of them when langs is empty. Without analyzers it is the word itself. Empty
+// when the word is a stop word of every language
+func (s *Searcher) variants(word string, langs []string) []string {
+	if !s.analyzers {
+		return []string{word}
+	}
+
+	if len(langs) == 0 {
+		langs = append(Languages(), LangUnknown)
+	}
+
+	var res []string
+
+	for _, lang := range langs {
+		if lang == LangUnknown {
+			lang = ""
+		}
+
+		term, ok := s.analyzerOf(lang).term(word)
+		if !ok {
+			continue
+		}
+
+		if !slices.Contains(res, term) {
+			res = append(res, term)
+		}
+	}
+
+	return res
+}
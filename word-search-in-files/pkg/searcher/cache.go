@@ -0,0 +1,201 @@
+package searcher
+
+import (
+	"container/list"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCacheSize is the memory budget of the result cache of NewSearcher
+const DefaultCacheSize = 32 * 1024 * 1024
+
+// Rough size of a cached entry besides its key and hits
+const cacheEntryOverhead = 128
+
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+	Bytes   int64
+}
+
+// resultCache is an LRU of the sorted matches of a query, bounded by the
+// estimated memory of the entries. Entries belong to a generation of the index
+// and are dropped when a new one is published. A nil cache caches nothing
+type resultCache struct {
+	mu         sync.Mutex
+	maxBytes   int64
+	bytes      int64
+	generation uint64
+	ll         *list.List
+	items      map[string]*list.Element
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type cacheEntry struct {
+	key  string
+	hits []scoredHit
+	size int64
+}
+
+func newResultCache(maxBytes int64) *resultCache {
+	if maxBytes <= 0 {
+		return nil
+	}
+
+	return &resultCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached hits of the key computed against the generation,
+// the returned slice must not be modified
+func (c *resultCache) get(key string, generation uint64) ([]scoredHit, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok && c.generation == generation {
+		c.ll.MoveToFront(el)
+		c.hits.Add(1)
+		return el.Value.(*cacheEntry).hits, true
+	}
+
+	c.misses.Add(1)
+	return nil, false
+}
+
+func (c *resultCache) put(key string, generation uint64, hits []scoredHit) {
+	if c == nil {
+		return
+	}
+
+	size := int64(len(key)) + int64(len(hits))*40 + cacheEntryOverhead
+	for _, h := range hits {
+		size += int64(len(h.segments)) * 8
+	}
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, hits: hits, size: size})
+	c.bytes += size
+
+	for c.bytes > c.maxBytes {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// invalidate drops every entry and accepts only the entries of the generation from now on
+func (c *resultCache) invalidate(generation uint64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation = generation
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.bytes = 0
+}
+
+func (c *resultCache) removeElement(el *list.Element) {
+	entry := c.ll.Remove(el).(*cacheEntry)
+	delete(c.items, entry.key)
+	c.bytes -= entry.size
+}
+
+func (c *resultCache) stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: c.ll.Len(),
+		Bytes:   c.bytes,
+	}
+}
+
+// CacheStats returns the counters of the result cache
+func (s *Searcher) CacheStats() CacheStats {
+	return s.cache.stats()
+}
+
+// cacheKey identifies the matches of the normalized words, the filter, the order, the
+// boost of the scores and the synonyms expanding the words, it does not depend on the
+// paging or the order of the words
+func cacheKey(words []string, f Filter, order SortOrder, nameBoost float64, synonyms uint64) string {
+	sorted := make([]string, len(words))
+	copy(sorted, words)
+	sort.Strings(sorted)
+
+	exts := make([]string, 0, len(f.Ext))
+	for _, ext := range f.Ext {
+		exts = append(exts, strings.TrimPrefix(strings.ToLower(ext), "."))
+	}
+	sort.Strings(exts)
+
+	langs := make([]string, len(f.Lang))
+	copy(langs, f.Lang)
+	sort.Strings(langs)
+
+	sb := &strings.Builder{}
+
+	// Words can not contain the separator, it is removed by the normalization
+	sb.WriteString(strings.Join(sorted, " "))
+	sb.WriteString("\x00")
+	sb.WriteString(string(order))
+	sb.WriteString("\x00")
+	sb.WriteString(strings.TrimPrefix(f.PathPrefix, "./"))
+	sb.WriteString("\x00")
+	if subtrees := f.subtrees(); subtrees != nil {
+		sb.WriteString("/" + strings.Join(subtrees, "\x01/"))
+	}
+	sb.WriteString("\x00")
+	sb.WriteString(strings.Join(exts, ","))
+	sb.WriteString("\x00")
+	sb.WriteString(strings.Join(langs, ","))
+	sb.WriteString("\x00")
+	sb.WriteString(f.ModifiedAfter.UTC().Format(time.RFC3339Nano))
+	sb.WriteString("\x00")
+	sb.WriteString(f.ModifiedBefore.UTC().Format(time.RFC3339Nano))
+	sb.WriteString("\x00")
+	sb.WriteString(strconv.FormatInt(f.MinSize, 10))
+	sb.WriteString("\x00")
+	sb.WriteString(strconv.FormatInt(f.MaxSize, 10))
+	sb.WriteString("\x00")
+	sb.WriteString(strconv.FormatFloat(nameBoost, 'g', -1, 64))
+	sb.WriteString("\x00")
+	sb.WriteString(strconv.FormatUint(synonyms, 10))
+
+	return sb.String()
+}
@@ -0,0 +1,71 @@
+package searcher
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestResultCache(t *testing.T) {
+	hits := []scoredHit{{index: 1}, {index: 2}}
+	size := int64(len("a")) + 2*40 + cacheEntryOverhead
+
+	c := newResultCache(2 * size)
+	c.invalidate(1)
+
+	c.put("a", 1, hits)
+	c.put("b", 1, hits)
+
+	if _, ok := c.get("a", 1); !ok {
+		t.Fatalf("get(a) missed")
+	}
+
+	// "b" is the least recently used one
+	c.put("c", 1, hits)
+
+	if _, ok := c.get("b", 1); ok {
+		t.Errorf("get(b) hit after eviction")
+	}
+
+	if _, ok := c.get("a", 2); ok {
+		t.Errorf("get(a) hit for another generation")
+	}
+
+	c.invalidate(2)
+
+	if _, ok := c.get("a", 2); ok {
+		t.Errorf("get(a) hit after invalidation")
+	}
+
+	if got := c.stats(); got.Hits != 1 || got.Misses != 3 || got.Entries != 0 || got.Bytes != 0 {
+		t.Errorf("stats() = %+v", got)
+	}
+}
+
+func TestSearcher_FindCached(t *testing.T) {
+	s := &Searcher{
+		fs:    fstest.MapFS{"file1.txt": {Data: []byte("Hello World")}},
+		cache: newResultCache(DefaultCacheSize),
+	}
+	s.Scan()
+
+	for i := 0; i < 3; i++ {
+		if _, e := s.Find(Query{Words: []string{"World Hello"}, Limit: i + 1}); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	s.Scan()
+
+	res, e := s.Find(Query{Words: []string{"Hello", "World"}})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if len(res.Hits) != 1 {
+		t.Errorf("Find() hits = %v", res.Hits)
+	}
+
+	if got := s.CacheStats(); got.Hits != 2 || got.Misses != 2 || got.Entries != 1 {
+		t.Errorf("CacheStats() = %+v", got)
+	}
+}
@@ -0,0 +1,379 @@
+package searcher
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strconv"
+)
+
+const (
+	// Values of a MinHash signature
+	minHashSize = 64
+	// Bands of the locality-sensitive hashing of the MinHash signatures, the files
+	// sharing all the values of a band are compared
+	minHashBands = 16
+
+	// DefaultDuplicateThreshold is the similarity of the near-duplicates when the
+	// query does not set one
+	DefaultDuplicateThreshold = 0.8
+)
+
+var (
+	ErrBadThreshold = errors.New("threshold must be above 0 and at most 1")
+	ErrBadMethod    = errors.New("unknown similarity method")
+)
+
+// Signature summarizes the terms of a file, the signatures of the near-duplicates are
+// close. It is computed once per content of the file
+type Signature struct {
+	// The minimum of every hash function over the distinct terms, the share of the
+	// equal values estimates the Jaccard similarity of the terms
+	MinHash [minHashSize]uint32
+	// The bits of the hashes of the terms weighted by their occurrences, the share of
+	// the equal bits estimates the cosine similarity
+	SimHash uint64
+}
+
+// encode returns the MinHash values as base64 of their little-endian bytes and the
+// SimHash in hex, the way the snapshots store them
+func (sig *Signature) encode() (minHash, simHash string) {
+	b := make([]byte, 0, 4*minHashSize)
+	for _, v := range sig.MinHash {
+		b = binary.LittleEndian.AppendUint32(b, v)
+	}
+
+	return base64.StdEncoding.EncodeToString(b), fmt.Sprintf("%016x", sig.SimHash)
+}
+
+func decodeSignature(minHash, simHash string) (*Signature, error) {
+	b, e := base64.StdEncoding.DecodeString(minHash)
+	if e != nil || len(b) != 4*minHashSize {
+		return nil, fmt.Errorf("minhash is not %d base64 encoded values", minHashSize)
+	}
+
+	sig := &Signature{}
+	for i := range sig.MinHash {
+		sig.MinHash[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+
+	if sig.SimHash, e = strconv.ParseUint(simHash, 16, 64); e != nil {
+		return nil, fmt.Errorf("simhash %q is not a hex number", simHash)
+	}
+
+	return sig, nil
+}
+
+// SimilarityMethod is the signature the near-duplicates are compared by
+type SimilarityMethod string
+
+const (
+	MethodMinHash SimilarityMethod = "minhash"
+	MethodSimHash SimilarityMethod = "simhash"
+)
+
+// DuplicateQuery selects the clusters of near-duplicates
+type DuplicateQuery struct {
+	// Minimum similarity of the files of a cluster to another one of it, 0 means
+	// DefaultDuplicateThreshold
+	Threshold float64
+	// MethodMinHash when empty
+	Method SimilarityMethod
+	// Only the files accepted by the filter are compared
+	Filter Filter
+}
+
+// DuplicateCluster is a group of near-identical files: every one is similar to
+// another one of the group above the threshold
+type DuplicateCluster struct {
+	// Sorted
+	Paths []string
+	// The lowest similarity of the pairs joining the cluster
+	Similarity float64
+}
+
+type DuplicateResult struct {
+	// The largest first
+	Clusters []DuplicateCluster
+	// Snapshot of the index the clusters were computed against
+	Generation uint64
+}
+
+// Duplicates returns the clusters of near-duplicates of the current generation
+func (s *Searcher) Duplicates(q DuplicateQuery) (*DuplicateResult, error) {
+	threshold := q.Threshold
+	if threshold == 0 {
+		threshold = DefaultDuplicateThreshold
+	}
+
+	if threshold < 0 || threshold > 1 {
+		return nil, fmt.Errorf("%w: %v", ErrBadThreshold, q.Threshold)
+	}
+
+	method := q.Method
+	if method == "" {
+		method = MethodMinHash
+	}
+
+	if method != MethodMinHash && method != MethodSimHash {
+		return nil, fmt.Errorf("%w %q, expected %s or %s", ErrBadMethod, q.Method, MethodMinHash, MethodSimHash)
+	}
+
+	accept, e := q.Filter.compile()
+	if e != nil {
+		return nil, e
+	}
+
+	s.muGlobal.RLock()
+	defer s.muGlobal.RUnlock()
+
+	var files []int
+	for i, f := range s.Files {
+		if f.Signature != nil && accept(f) {
+			files = append(files, i)
+		}
+	}
+
+	similarity := func(a, b *Signature) float64 {
+		if method == MethodSimHash {
+			return 1 - float64(bits.OnesCount64(a.SimHash^b.SimHash))/64
+		}
+
+		equal := 0
+		for i := range a.MinHash {
+			if a.MinHash[i] == b.MinHash[i] {
+				equal++
+			}
+		}
+		return float64(equal) / minHashSize
+	}
+
+	uf := newUnionFind(len(files))
+	compared := make(map[[2]int]struct{})
+
+	for _, bucket := range s.candidateBuckets(files, method, threshold) {
+		for i, a := range bucket {
+			for _, b := range bucket[i+1:] {
+				pair := [2]int{min(a, b), max(a, b)}
+				if _, ok := compared[pair]; ok {
+					continue
+				}
+				compared[pair] = struct{}{}
+
+				if sim := similarity(s.Files[files[a]].Signature, s.Files[files[b]].Signature); sim >= threshold {
+					uf.union(a, b, sim)
+				}
+			}
+		}
+	}
+
+	clusters := make(map[int]*DuplicateCluster)
+	for i, index := range files {
+		root := uf.find(i)
+		if uf.size[root] < 2 {
+			continue
+		}
+
+		c, ok := clusters[root]
+		if !ok {
+			c = &DuplicateCluster{Similarity: uf.similarity[root]}
+			clusters[root] = c
+		}
+		c.Paths = append(c.Paths, s.Files[index].Path)
+	}
+
+	res := make([]DuplicateCluster, 0, len(clusters))
+	for _, c := range clusters {
+		sort.Strings(c.Paths)
+		res = append(res, *c)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if len(res[i].Paths) != len(res[j].Paths) {
+			return len(res[i].Paths) > len(res[j].Paths)
+		}
+		return res[i].Paths[0] < res[j].Paths[0]
+	})
+
+	return &DuplicateResult{Clusters: res, Generation: s.generation}, nil
+}
+
+// candidateBuckets groups the positions of the files that may be similar above the
+// threshold. For MinHash the files sharing a band of the signature, for SimHash the
+// ones sharing a block of bits: the hashes within n bits of each other have one of n+1
+// blocks equal. The caller must hold muGlobal
+func (s *Searcher) candidateBuckets(files []int, method SimilarityMethod, threshold float64) [][]int {
+	buckets := make(map[[2]uint64][]int)
+
+	for i, index := range files {
+		sig := s.Files[index].Signature
+
+		if method == MethodSimHash {
+			blocks := int((1-threshold)*64) + 1
+			for b := 0; b < blocks; b++ {
+				from, to := b*64/blocks, (b+1)*64/blocks
+				value := sig.SimHash >> from & (1<<(to-from) - 1)
+				buckets[[2]uint64{uint64(b), value}] = append(buckets[[2]uint64{uint64(b), value}], i)
+			}
+			continue
+		}
+
+		rows := minHashSize / minHashBands
+		for b := 0; b < minHashBands; b++ {
+			h := fnv.New64a()
+			for _, v := range sig.MinHash[b*rows : (b+1)*rows] {
+				h.Write([]byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)})
+			}
+			key := [2]uint64{uint64(b), h.Sum64()}
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	res := make([][]int, 0, len(buckets))
+	for _, bucket := range buckets {
+		if len(bucket) > 1 {
+			res = append(res, bucket)
+		}
+	}
+
+	return res
+}
+
+// sign computes the signatures of the files without one from the postings of the
+// index, the unchanged files of an incremental scan keep theirs. The caller must hold
+// muGlobal for writing
+func (s *Searcher) sign() {
+	type signer struct {
+		sig     *Signature
+		weights [64]int
+	}
+
+	pending := make(map[int]*signer)
+	for i, f := range s.Files {
+		if f.Signature == nil && f.Tokens > 0 {
+			sg := &signer{sig: &Signature{}}
+			for j := range sg.sig.MinHash {
+				sg.sig.MinHash[j] = ^uint32(0)
+			}
+			pending[i] = sg
+		}
+	}
+
+	if len(pending) == 0 {
+		return
+	}
+
+	var hashes [minHashSize]uint32
+
+	for term, postings := range s.Words {
+		h := termHash(term)
+
+		// The hash functions of MinHash, derived from the two halves of the hash
+		h1, h2 := uint32(h), uint32(h>>32)|1
+		for j := range hashes {
+			hashes[j] = fmix32(h1 + uint32(j)*h2)
+		}
+
+		for index, tf := range postings {
+			sg, ok := pending[index]
+			if !ok {
+				continue
+			}
+
+			for j, v := range hashes {
+				sg.sig.MinHash[j] = min(sg.sig.MinHash[j], v)
+			}
+
+			for b := range sg.weights {
+				if h>>b&1 == 1 {
+					sg.weights[b] += tf
+				} else {
+					sg.weights[b] -= tf
+				}
+			}
+		}
+	}
+
+	for index, sg := range pending {
+		for b, w := range sg.weights {
+			if w > 0 {
+				sg.sig.SimHash |= 1 << b
+			}
+		}
+
+		s.Files[index].Signature = sg.sig
+	}
+}
+
+func termHash(term string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(term))
+
+	// FNV spreads the short strings poorly over the high bits
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
+
+// fmix32 is the finalizer of MurmurHash3
+func fmix32(h uint32) uint32 {
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+
+	return h
+}
+
+// unionFind joins the near-duplicates into clusters, keeping the lowest similarity of
+// the pairs of a cluster
+type unionFind struct {
+	parent     []int
+	size       []int
+	similarity []float64
+}
+
+func newUnionFind(n int) *unionFind {
+	uf := &unionFind{parent: make([]int, n), size: make([]int, n), similarity: make([]float64, n)}
+	for i := range uf.parent {
+		uf.parent[i] = i
+		uf.size[i] = 1
+		uf.similarity[i] = 1
+	}
+
+	return uf
+}
+
+func (uf *unionFind) find(i int) int {
+	for uf.parent[i] != i {
+		uf.parent[i] = uf.parent[uf.parent[i]]
+		i = uf.parent[i]
+	}
+
+	return i
+}
+
+func (uf *unionFind) union(a, b int, similarity float64) {
+	a, b = uf.find(a), uf.find(b)
+
+	if a != b {
+		if uf.size[a] < uf.size[b] {
+			a, b = b, a
+		}
+		uf.parent[b] = a
+		uf.size[a] += uf.size[b]
+		uf.similarity[a] = min(uf.similarity[a], uf.similarity[b])
+	}
+
+	uf.similarity[a] = min(uf.similarity[a], similarity)
+}
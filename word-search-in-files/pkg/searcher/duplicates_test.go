@@ -0,0 +1,115 @@
+package searcher
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+const duplicateText = `The quarterly report describes the revenue of the northern region, the costs of
+the new warehouse, the hiring plan for the support team and the schedule of the product
+launch. Sales grew in every month except August, when two large customers delayed their
+orders. The board approved the budget for marketing and asked for a review of the logistics
+contracts before the end of the year.`
+
+func TestSearcher_Duplicates(t *testing.T) {
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	fsys := fstest.MapFS{
+		"report.txt":        {Data: []byte(duplicateText), ModTime: mtime},
+		"copy/report.md":    {Data: []byte(duplicateText), ModTime: mtime},
+		"report-edited.txt": {Data: []byte(strings.Replace(duplicateText, "August", "September", 1) + " Reviewed."), ModTime: mtime},
+		"other.txt":         {Data: []byte("Minutes of the meeting: the team discussed the office move, the parking and the new coffee machine."), ModTime: mtime},
+		"empty.txt":         {Data: []byte(""), ModTime: mtime},
+	}
+
+	s := &Searcher{fs: fsys}
+	s.Scan()
+
+	all := []string{"copy/report.md", "report-edited.txt", "report.txt"}
+
+	tests := []struct {
+		name    string
+		query   DuplicateQuery
+		want    [][]string
+		wantErr error
+	}{
+		{name: "MinHash", query: DuplicateQuery{}, want: [][]string{all}},
+		{name: "SimHash", query: DuplicateQuery{Method: MethodSimHash, Threshold: 0.9}, want: [][]string{all}},
+		{name: "Identical only", query: DuplicateQuery{Threshold: 1}, want: [][]string{{"copy/report.md", "report.txt"}}},
+		{name: "Filter", query: DuplicateQuery{Filter: Filter{Ext: []string{"txt"}}}, want: [][]string{{"report-edited.txt", "report.txt"}}},
+		{name: "None", query: DuplicateQuery{Filter: Filter{PathPrefix: "copy"}}, want: nil},
+		{name: "E: threshold", query: DuplicateQuery{Threshold: 1.5}, wantErr: ErrBadThreshold},
+		{name: "E: method", query: DuplicateQuery{Method: "md5"}, wantErr: ErrBadMethod},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, e := s.Duplicates(tt.query)
+			if !errors.Is(e, tt.wantErr) {
+				t.Fatalf("Duplicates() error = %v, wantErr %v", e, tt.wantErr)
+			}
+			if e != nil {
+				return
+			}
+
+			var got [][]string
+			for _, c := range res.Clusters {
+				got = append(got, c.Paths)
+
+				if c.Similarity <= 0 || c.Similarity > 1 {
+					t.Errorf("Similarity = %v", c.Similarity)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Duplicates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if s.Files[1].Signature != nil {
+		t.Errorf("signature of the empty file = %+v, want nil", s.Files[1].Signature)
+	}
+
+	// The unchanged files keep their signatures, the changed one gets a new one
+	signatures := make(map[string]*Signature)
+	for _, f := range s.Files {
+		signatures[f.Path] = f.Signature
+	}
+
+	fsys["other.txt"] = &fstest.MapFile{Data: []byte(duplicateText), ModTime: mtime.Add(time.Second)}
+	s.Scan()
+
+	for _, f := range s.Files {
+		if reused := f.Signature == signatures[f.Path]; reused != (f.Path != "other.txt") {
+			t.Errorf("%s: signature reused = %v", f.Path, reused)
+		}
+	}
+
+	res, _ := s.Duplicates(DuplicateQuery{})
+	if len(res.Clusters) != 1 || len(res.Clusters[0].Paths) != 4 {
+		t.Errorf("Duplicates() after the change = %+v, want a cluster of 4", res.Clusters)
+	}
+
+	// The signatures are saved in the snapshots
+	var buf bytes.Buffer
+	if e := s.Export(&buf); e != nil {
+		t.Fatal(e)
+	}
+
+	snap, e := ReadSnapshot(&buf)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	for _, f := range snap.Files {
+		if f.Signature == nil && f.Path != "empty.txt" || f.Signature != nil && *f.Signature != *signatures[f.Path] && f.Path != "other.txt" {
+			t.Errorf("%s: signature of the snapshot = %v", f.Path, f.Signature)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package searcher
 
 import (
 	"regexp"
+	"sort"
 )
 
 func removePunctuation(word string) string {
@@ -9,11 +10,34 @@ func removePunctuation(word string) string {
 	return re.ReplaceAllString(word, "")
 }
 
-func addWordToMap(words map[string]map[int]struct{}, word string, value int) {
+func addWordToMap(words map[string]map[int]int, word string, value int) {
 	// If a map for a given word does not yet exist, create it
 	if words[word] == nil {
-		words[word] = make(map[int]struct{})
+		words[word] = make(map[int]int)
 	}
 
-	words[word][value] = struct{}{}
+	words[word][value]++
+}
+
+func addSegmentToMap(segments map[string]map[int]map[int]struct{}, word string, index, segment int) {
+	if segments[word] == nil {
+		segments[word] = make(map[int]map[int]struct{})
+	}
+
+	if segments[word][index] == nil {
+		segments[word][index] = make(map[int]struct{})
+	}
+
+	segments[word][index][segment] = struct{}{}
+}
+
+func sortedWords(words map[string]map[int]int) []string {
+	res := make([]string, 0, len(words))
+	for word := range words {
+		res = append(res, word)
+	}
+
+	sort.Strings(res)
+
+	return res
 }
@@ -0,0 +1,183 @@
+package searcher
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// The fields of the files besides their content, a query word `name:report` or
+// `path:archive/2023` only matches the field
+const (
+	// The words of the file name, lower case: "Q3-Report.pdf" is q3, report and pdf
+	FieldName = "name"
+	// The directories and the name of the path, lower case. The value of a query is a
+	// sequence of them, matching consecutive ones anywhere in the path
+	FieldPath = "path"
+)
+
+// DefaultNameBoost weighs the name matches when the query does not set a boost
+const DefaultNameBoost = 2.0
+
+var ErrBadBoost = errors.New("name boost must not be negative")
+
+// fieldOf splits a query word of a field, ok is false for a word of the content
+func fieldOf(word string) (field, value string, ok bool) {
+	for _, f := range []string{FieldName, FieldPath} {
+		if value, ok := strings.CutPrefix(word, f+":"); ok {
+			return f, value, true
+		}
+	}
+
+	return "", "", false
+}
+
+// isField reports whether the normalized query word is one of a field
+func isField(word string) bool {
+	_, _, ok := fieldOf(word)
+	return ok
+}
+
+// normalizeField returns the query words of the value of the field: the words of a
+// name but a pattern, which is kept whole, or the cleaned path
+func normalizeField(field, value string) []string {
+	value = strings.ToLower(value)
+
+	if field == FieldPath {
+		var components []string
+		for _, c := range strings.Split(value, "/") {
+			if c != "" && c != "." {
+				components = append(components, c)
+			}
+		}
+
+		if len(components) == 0 {
+			return nil
+		}
+
+		return []string{FieldPath + ":" + strings.Join(components, "/")}
+	}
+
+	if isWildcard(value) {
+		if value = normalizeWildcard(value); strings.Trim(value, "*") == "" {
+			return nil
+		}
+
+		return []string{FieldName + ":" + value}
+	}
+
+	var res []string
+	for _, token := range nameTokens(value) {
+		res = append(res, FieldName+":"+token)
+	}
+
+	return res
+}
+
+// nameTokens splits the file name into its words, the runs of letters and digits,
+// lower case
+func nameTokens(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// pathComponents returns the directories and the name of the path, lower case
+func pathComponents(p string) []string {
+	return strings.Split(strings.ToLower(p), "/")
+}
+
+// indexFields indexes the names and the paths of the files, the caller must hold
+// muGlobal for writing
+func (s *Searcher) indexFields() {
+	s.names = make(map[string]map[int]int)
+	s.components = make(map[string]map[int]int)
+
+	for i, f := range s.Files {
+		for _, token := range nameTokens(path.Base(f.Path)) {
+			addWordToMap(s.names, token, i)
+		}
+
+		for _, c := range pathComponents(f.Path) {
+			addWordToMap(s.components, c, i)
+		}
+	}
+}
+
+// fieldPostings returns the files matching the value of the field with the number of
+// the matches in each, and the number of the names or the files walked to find them.
+// The caller must hold muGlobal
+func (s *Searcher) fieldPostings(field, value string) (postings map[int]int, scanned int) {
+	if field == FieldName {
+		if !isWildcard(value) {
+			return s.names[value], 0
+		}
+
+		postings = make(map[int]int)
+		for token, files := range s.names {
+			if ok, _ := path.Match(value, token); ok {
+				for index, tf := range files {
+					postings[index] += tf
+				}
+			}
+		}
+
+		return postings, len(s.names)
+	}
+
+	want := strings.Split(value, "/")
+
+	// The files containing a literal component, or all of them
+	var candidates map[int]int
+	for _, c := range want {
+		if !isWildcard(c) && (candidates == nil || len(s.components[c]) < len(candidates)) {
+			candidates = s.components[c]
+			if candidates == nil {
+				return nil, 0
+			}
+		}
+	}
+
+	postings = make(map[int]int)
+
+	check := func(index int) {
+		if n := countSequence(pathComponents(s.Files[index].Path), want); n > 0 {
+			postings[index] = n
+		}
+	}
+
+	if candidates == nil {
+		for index := range s.Files {
+			check(index)
+		}
+		return postings, len(s.Files)
+	}
+
+	for index := range candidates {
+		check(index)
+	}
+
+	return postings, len(candidates)
+}
+
+// countSequence returns the number of the places the patterns match consecutive
+// components
+func countSequence(components, patterns []string) int {
+	n := 0
+
+next:
+	for i := 0; i+len(patterns) <= len(components); i++ {
+		for j, p := range patterns {
+			if p == components[i+j] {
+				continue
+			}
+			if ok, _ := path.Match(p, components[i+j]); !ok || !isWildcard(p) {
+				continue next
+			}
+		}
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,97 @@
+package searcher
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSearcher_FindFields(t *testing.T) {
+	fsys := fstest.MapFS{
+		"archive/2023/Q3-Report.txt":    {Data: []byte("budget of the quarter")},
+		"archive/2022/annual_report.md": {Data: []byte("budget budget budget plans")},
+		"notes/budget.txt":              {Data: []byte("budget draft")},
+		"notes/archive.txt":             {Data: []byte("old plans")},
+	}
+
+	tests := []struct {
+		name        string
+		granularity Granularity
+		query       Query
+		want        []string
+		wantErr     error
+	}{
+		{name: "Name", query: Query{Words: []string{"name:report"}}, want: []string{"archive/2022/annual_report.md", "archive/2023/Q3-Report.txt"}},
+		{name: "Name words", query: Query{Words: []string{"name:Q3-REPORT"}}, want: []string{"archive/2023/Q3-Report.txt"}},
+		{name: "Name pattern", query: Query{Words: []string{"name:ann*"}}, want: []string{"archive/2022/annual_report.md"}},
+		{name: "Path", query: Query{Words: []string{"path:archive/2023"}}, want: []string{"archive/2023/Q3-Report.txt"}},
+		{name: "Path with the name", query: Query{Words: []string{"path:notes/archive.txt"}}, want: []string{"notes/archive.txt"}},
+		{name: "Path pattern", query: Query{Words: []string{"path:archive/*/*.md"}}, want: []string{"archive/2022/annual_report.md"}},
+		{name: "Path components", query: Query{Words: []string{"path:2023/archive"}}},
+		{name: "Content and name", query: Query{Words: []string{"budget name:report"}}, want: []string{"archive/2022/annual_report.md", "archive/2023/Q3-Report.txt"}},
+		{name: "Content and path", query: Query{Words: []string{"plans", "path:archive"}}, want: []string{"archive/2022/annual_report.md"}},
+		{name: "Lines", granularity: GranularityLine, query: Query{Words: []string{"name:budget"}}, want: []string{"notes/budget.txt"}},
+		{name: "Lines and content", granularity: GranularityLine, query: Query{Words: []string{"draft name:budget"}}, want: []string{"notes/budget.txt"}},
+		{name: "Not a field", query: Query{Words: []string{"title:report"}}},
+		{name: "E: empty field", query: Query{Words: []string{"name:"}}, wantErr: ErrEmptyQuery},
+		{name: "E: boost", query: Query{Words: []string{"budget"}, NameBoost: -1}, wantErr: ErrBadBoost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Searcher{fs: fsys, granularity: tt.granularity}
+			s.Scan()
+
+			res, e := s.Find(tt.query)
+			if !errors.Is(e, tt.wantErr) {
+				t.Fatalf("Find() error = %v, wantErr %v", e, tt.wantErr)
+			}
+			if e != nil {
+				return
+			}
+
+			var got []string
+			for _, h := range res.Hits {
+				got = append(got, h.Path)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearcher_FindNameBoost(t *testing.T) {
+	fsys := fstest.MapFS{
+		"budget.txt": {Data: []byte("budget")},
+		"plans.txt":  {Data: []byte("budget budget budget budget")},
+	}
+
+	s := &Searcher{fs: fsys}
+	s.Scan()
+
+	tests := []struct {
+		name      string
+		nameBoost float64
+		want      string
+	}{
+		{name: "Default", want: "budget.txt"},
+		{name: "Low", nameBoost: 0.1, want: "plans.txt"},
+		{name: "High", nameBoost: 10, want: "budget.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, e := s.Find(Query{Words: []string{"budget"}, Sort: SortScore, NameBoost: tt.nameBoost})
+			if e != nil {
+				t.Fatal(e)
+			}
+
+			if len(res.Hits) != 2 || res.Hits[0].Path != tt.want {
+				t.Errorf("Find() = %+v, want %s first", res.Hits, tt.want)
+			}
+		})
+	}
+}
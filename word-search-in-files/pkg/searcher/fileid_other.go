@@ -0,0 +1,12 @@
+//go:build !unix
+
+package searcher
+
+import "io/fs"
+
+// fileIDOf is not supported on this platform: the walker cannot recognize hard links
+// and relies on the depth of the paths alone being finite, so following symlinks is
+// only safe without cycles
+func fileIDOf(info fs.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
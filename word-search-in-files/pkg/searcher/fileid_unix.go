@@ -0,0 +1,19 @@
+//go:build unix
+
+package searcher
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileIDOf returns the device and the inode of the file, false when the file system
+// does not tell them, e.g. for an in-memory one
+func fileIDOf(info fs.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
@@ -0,0 +1,131 @@
+package searcher
+
+import (
+	"io/fs"
+	"slices"
+	"strconv"
+)
+
+// versioner is implemented by the Sys() of the file infos of the file systems that know
+// better than the modification time when a file changes, e.g. by an ETag
+type versioner interface {
+	Version() string
+}
+
+// fileVersion identifies the content of the file: the version of a remote object or
+// the modification time and the size of a local file. An empty version is unknown,
+// such a file is always read again
+func fileVersion(info fs.FileInfo) string {
+	if v, ok := info.Sys().(versioner); ok {
+		return v.Version()
+	}
+
+	if info.ModTime().IsZero() {
+		return ""
+	}
+
+	return "mtime:" + strconv.FormatInt(info.ModTime().UnixNano(), 10) + ":" + strconv.FormatInt(info.Size(), 10)
+}
+
+// previousIndex is the generation a scan takes the unchanged files from instead of
+// reading them again. The scan replaces the maps and the slices of the Searcher, so
+// the ones of the previous generation stay intact while the walker reads them
+type previousIndex struct {
+	// Path -> index of the file in files, only the files with a version
+	paths map[string]int
+	files []FileInfo
+	spans [][]span
+	// Terms of the files by index
+	terms [][]reusedTerm
+}
+
+type reusedTerm struct {
+	word  string
+	count int
+	// Segments containing the word, for a granularity finer than a file
+	segments []int
+}
+
+// reusedFile carries the terms of an unchanged file from the walker to the scan loop
+type reusedFile struct {
+	index int
+	terms []reusedTerm
+}
+
+// previous captures the current generation before a scan replaces it, the caller must
+// hold muGlobal
+func (s *Searcher) previous() *previousIndex {
+	prev := &previousIndex{
+		paths: make(map[string]int),
+		files: s.Files,
+		spans: s.spans,
+		terms: make([][]reusedTerm, len(s.Files)),
+	}
+
+	for i, f := range s.Files {
+		if f.Version != "" {
+			prev.paths[f.Path] = i
+		}
+	}
+
+	if len(prev.paths) == 0 {
+		return prev
+	}
+
+	for word, postings := range s.Words {
+		for index, count := range postings {
+			if _, ok := prev.paths[s.Files[index].Path]; !ok {
+				continue
+			}
+
+			t := reusedTerm{word: word, count: count}
+			for sg := range s.segments[word][index] {
+				t.segments = append(t.segments, sg)
+			}
+
+			prev.terms[index] = append(prev.terms[index], t)
+		}
+	}
+
+	return prev
+}
+
+// reuse adds the file of the previous generation to the index when its version did
+// not change and reports whether it did
+func (w *walker) reuse(path string, version string) bool {
+	if version == "" || w.prev == nil {
+		return false
+	}
+
+	i, ok := w.prev.paths[path]
+	if !ok || w.prev.files[i].Version != version {
+		return false
+	}
+
+	s := w.s
+
+	// The memory budget is of this scan, the other limits fired on the same content
+	f := w.prev.files[i]
+	if slices.Contains(f.Limits, LimitMemory) {
+		f.Limits = slices.DeleteFunc(slices.Clone(f.Limits), func(l Limit) bool { return l == LimitMemory })
+	}
+
+	s.Files = append(s.Files, f)
+	index := len(s.Files) - 1
+
+	if s.granularity != GranularityFile {
+		var spans []span
+		if i < len(w.prev.spans) {
+			spans = w.prev.spans[i]
+		}
+		s.spans = append(s.spans, spans)
+	}
+
+	if len(w.prev.terms[i]) > 0 {
+		w.snc.reuseCh <- reusedFile{index: index, terms: w.prev.terms[i]}
+	}
+
+	s.reused++
+
+	return true
+}
@@ -0,0 +1,49 @@
+package searcher
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestSearcher_ScanIncremental(t *testing.T) {
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("apple pear\nplum"), ModTime: mtime},
+		"b.txt": {Data: []byte("pear"), ModTime: mtime},
+		"c.txt": {Data: []byte("plum plum")},
+	}
+
+	s := &Searcher{fs: fsys, granularity: GranularityLine}
+	s.Scan()
+
+	before, _ := s.Find(Query{Words: []string{"plum"}})
+
+	// Same size and content time for a.txt, b.txt changed, c.txt has no version
+	fsys["b.txt"] = &fstest.MapFile{Data: []byte("cherry"), ModTime: mtime.Add(time.Second)}
+	fsys["d.txt"] = &fstest.MapFile{Data: []byte("plum"), ModTime: mtime}
+	s.Scan()
+
+	if st := s.LastScan(); st.Reused != 1 || st.Files != 4 {
+		t.Errorf("LastScan() = %+v, want 1 of 4 files reused", st)
+	}
+
+	if got := s.Words["plum"]; !reflect.DeepEqual(got, map[int]int{0: 1, 2: 2, 3: 1}) {
+		t.Errorf("Words[plum] = %v", got)
+	}
+
+	if _, ok := s.Words["pear"][1]; ok || s.Words["cherry"][1] != 1 {
+		t.Errorf("changed file is not read again: %v", s.Words)
+	}
+
+	after, _ := s.Find(Query{Words: []string{"plum"}, Filter: Filter{PathPrefix: "a.txt"}})
+	if len(after.Hits) != 1 || !reflect.DeepEqual(after.Hits[0].Segments, before.Hits[0].Segments) {
+		t.Errorf("segments of the reused file = %+v, want %+v", after.Hits, before.Hits)
+	}
+
+	if s.Files[0].Tokens != 3 {
+		t.Errorf("Tokens = %d, want 3", s.Files[0].Tokens)
+	}
+}
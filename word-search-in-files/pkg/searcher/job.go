@@ -2,7 +2,7 @@ package searcher
 
 import "sync"
 
-type JobFunc func(line []byte, index int, resCh chan<- JobResult, errCh chan<- error) error
+type JobFunc func(line []byte, index, segment int, resCh chan<- JobResult, errCh chan<- error) error
 
 type Job struct {
 	executeFunc JobFunc
@@ -14,21 +14,26 @@ type Job struct {
 	// the file from the caller's side when the word is found
 	index int
 
+	// The number of the line or sentence in the file
+	segment int
+
 	wg    *sync.WaitGroup
 	resCh chan<- JobResult
 	errCh chan<- error
 }
 
 type JobResult struct {
-	Word  string
-	Index int
+	Word    string
+	Index   int
+	Segment int
 }
 
-func NewJob(executeFunc JobFunc, wg *sync.WaitGroup, resCh chan<- JobResult, errCh chan<- error, line []byte, index int) *Job {
+func NewJob(executeFunc JobFunc, wg *sync.WaitGroup, resCh chan<- JobResult, errCh chan<- error, line []byte, index, segment int) *Job {
 	return &Job{
 		executeFunc: executeFunc,
 		line:        line,
 		index:       index,
+		segment:     segment,
 		wg:          wg,
 		resCh:       resCh,
 		errCh:       errCh,
@@ -41,7 +46,7 @@ func (j *Job) Execute() error {
 	}
 
 	if j.executeFunc != nil {
-		return j.executeFunc(j.line, j.index, j.resCh, j.errCh)
+		return j.executeFunc(j.line, j.index, j.segment, j.resCh, j.errCh)
 	}
 
 	return nil
@@ -0,0 +1,167 @@
+package searcher
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// Most frequent n-grams of a profile
+	profileSize = 300
+	// Bytes of the beginning of a file the language is detected on
+	detectSample = 4096
+	// Fewer letters are not enough to tell the language
+	minDetectLetters = 20
+	// A text farther than this share of the maximum distance from every profile is
+	// in another language
+	maxDetectDistance = 0.7
+)
+
+// LangUnknown selects the files of no detected language in a filter
+const LangUnknown = "und"
+
+// Sample texts the n-gram profiles of the languages are built from
+var languageSamples = map[string]string{
+	"en": `
+		The search service reads every file of the directory and builds an index of the
+		words, so the queries are answered without reading the files again. When a file
+		changes, the next scan picks up the new content and the old one is forgotten.
+		Most of the documents are written in plain English: reports, meeting notes, letters
+		to the customers and the manuals of the products. People usually look for a name,
+		a number or a phrase they remember, and they expect to find the document within a
+		second. The results should show where exactly the words were found, which is why
+		the index keeps the lines and the sentences of every file. It would be nice to have
+		better spelling suggestions and to know which of the files were left out and why.
+		There is nothing special about this text, it only has to look like the ordinary
+		language of the people who work here every day and write about their work.
+	`,
+	"ru": `
+		Сервис поиска читает все файлы каталога и строит индекс слов, чтобы отвечать на
+		запросы, не перечитывая файлы заново. Когда файл меняется, следующее сканирование
+		подхватывает новое содержимое, а старое забывается. Большинство документов написаны
+		обычным русским языком: отчёты, протоколы совещаний, письма клиентам и руководства
+		по продуктам. Люди обычно ищут имя, номер или фразу, которую они помнят, и ожидают
+		найти документ за секунду. В результатах должно быть видно, где именно нашлись
+		слова, поэтому индекс хранит строки и предложения каждого файла. Хорошо было бы
+		получать более точные подсказки при опечатках и знать, какие файлы были пропущены
+		и почему. В этом тексте нет ничего особенного, он только должен быть похож на
+		обычную речь людей, которые работают здесь каждый день и пишут о своей работе.
+	`,
+}
+
+// Profiles of the languages: n-gram -> rank, built once from the samples
+var languageProfiles = func() map[string]map[string]int {
+	res := make(map[string]map[string]int, len(languageSamples))
+	for lang, sample := range languageSamples {
+		res[lang] = ngramProfile(sample)
+	}
+
+	return res
+}()
+
+// Languages returns the codes of the languages DetectLanguage tells apart
+func Languages() []string {
+	res := make([]string, 0, len(languageProfiles))
+	for lang := range languageProfiles {
+		res = append(res, lang)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// DetectLanguage returns the code of the language of the text, "" when there is too
+// little text or it is in none of the Languages. The beginning of the text is enough:
+// its character n-grams are compared to the ones of every language by rank
+func DetectLanguage(text []byte) string {
+	// A letter cut in half is not a letter, it does not count
+	if len(text) > detectSample {
+		text = text[:detectSample]
+	}
+
+	letters := 0
+	for _, r := range string(text) {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+
+	if letters < minDetectLetters {
+		return ""
+	}
+
+	doc := ngramProfile(string(text))
+
+	best, bestDistance := "", -1
+	for lang, profile := range languageProfiles {
+		d := 0
+		for gram, rank := range doc {
+			if r, ok := profile[gram]; ok {
+				d += abs(rank - r)
+			} else {
+				d += profileSize
+			}
+		}
+
+		if bestDistance < 0 || d < bestDistance || (d == bestDistance && lang < best) {
+			best, bestDistance = lang, d
+		}
+	}
+
+	if float64(bestDistance) > maxDetectDistance*float64(len(doc)*profileSize) {
+		return ""
+	}
+
+	return best
+}
+
+// ngramProfile ranks the 1 to 3 letter n-grams of the lower case words of the text,
+// padded with '_' at the word boundaries, by frequency
+func ngramProfile(text string) map[string]int {
+	counts := make(map[string]int)
+
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		runes := []rune("_" + word + "_")
+
+		for n := 1; n <= 3; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				if gram := string(runes[i : i+n]); gram != "_" {
+					counts[gram]++
+				}
+			}
+		}
+	}
+
+	grams := make([]string, 0, len(counts))
+	for gram := range counts {
+		grams = append(grams, gram)
+	}
+
+	sort.Slice(grams, func(i, j int) bool {
+		if counts[grams[i]] != counts[grams[j]] {
+			return counts[grams[i]] > counts[grams[j]]
+		}
+		return grams[i] < grams[j]
+	})
+
+	if len(grams) > profileSize {
+		grams = grams[:profileSize]
+	}
+
+	res := make(map[string]int, len(grams))
+	for rank, gram := range grams {
+		res[gram] = rank
+	}
+
+	return res
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
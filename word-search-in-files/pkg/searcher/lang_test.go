@@ -0,0 +1,132 @@
+package searcher
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		stem func(string) string
+		word string
+		want string
+	}{
+		{stemEnglish, "caresses", "caress"},
+		{stemEnglish, "ponies", "poni"},
+		{stemEnglish, "running", "run"},
+		{stemEnglish, "connections", "connect"},
+		{stemEnglish, "connected", "connect"},
+		{stemEnglish, "generalizations", "gener"},
+		{stemEnglish, "hopeful", "hope"},
+		{stemEnglish, "filing", "file"},
+		{stemEnglish, "sky", "sky"},
+		{stemEnglish, "naïve", "naïve"},
+		{stemRussian, "книгами", "книг"},
+		{stemRussian, "книге", "книг"},
+		{stemRussian, "документов", "документ"},
+		{stemRussian, "работает", "работа"},
+		{stemRussian, "красивейшая", "красив"},
+		{stemRussian, "подписавшись", "подписа"},
+		{stemRussian, "радость", "радост"},
+		{stemRussian, "ёлками", "елк"},
+		{stemRussian, "word", "word"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.stem(tt.word); got != tt.want {
+			t.Errorf("stem(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "English", text: "The meeting notes: we agreed to move the release to Friday and to fix the login bug first.", want: "en"},
+		{name: "Russian", text: "Протокол совещания: договорились перенести выпуск на пятницу и сначала исправить ошибку входа.", want: "ru"},
+		{name: "Mostly Russian", text: "Сервер отвечает на запросы /files/search и /stats, а индекс хранится в памяти процесса.", want: "ru"},
+		{name: "Too short", text: "hello мир", want: ""},
+		{name: "Code", text: "func main() { x := 1; y := x + 2 }", want: ""},
+		{name: "Numbers", text: "12345 67890 3.14159 2.71828 1.41421 1.73205", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectLanguage([]byte(tt.text)); got != tt.want {
+				t.Errorf("DetectLanguage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearcher_FindLang(t *testing.T) {
+	fsys := fstest.MapFS{
+		"en.txt":    {Data: []byte("The new release connects the clients faster and the connections are encrypted.")},
+		"ru.txt":    {Data: []byte("Новые клиенты подключаются быстрее, а все подключения теперь шифруются по умолчанию.")},
+		"mix.txt":   {Data: []byte("Отчёт о подключениях клиентов за неделю: connection errors и время ответа сервера.")},
+		"short.txt": {Data: []byte("connections")},
+	}
+
+	tests := []struct {
+		name      string
+		analyzers bool
+		words     []string
+		lang      []string
+		want      []string
+		wantErr   error
+	}{
+		{name: "Exact", words: []string{"connections"}, want: []string{"en.txt", "short.txt"}},
+		{name: "Lang filter", words: []string{"connections"}, lang: []string{"en"}, want: []string{"en.txt"}},
+		{name: "Undetected", words: []string{"connections"}, lang: []string{LangUnknown}, want: []string{"short.txt"}},
+		{name: "Stemmed", analyzers: true, words: []string{"connected"}, want: []string{"en.txt"}},
+		{name: "English word of a Russian file", analyzers: true, words: []string{"connection"}, want: []string{"en.txt", "mix.txt"}},
+		{name: "Stemmed Russian", analyzers: true, words: []string{"подключение"}, want: []string{"mix.txt", "ru.txt"}},
+		{name: "Case", analyzers: true, words: []string{"Клиентам"}, want: []string{"mix.txt", "ru.txt"}},
+		{name: "Stemmed in a language", analyzers: true, words: []string{"connection"}, lang: []string{"en"}, want: []string{"en.txt"}},
+		{name: "Undetected are not stemmed", analyzers: true, words: []string{"connections"}, lang: []string{LangUnknown}, want: []string{"short.txt"}},
+		{name: "E: stop word of the language", analyzers: true, words: []string{"the"}, lang: []string{"en"}, wantErr: ErrEmptyQuery},
+		{name: "E: unknown language", words: []string{"connections"}, lang: []string{"de"}, wantErr: ErrBadFilter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Searcher{fs: fsys, analyzers: tt.analyzers}
+			s.Scan()
+
+			res, e := s.Find(Query{Words: tt.words, Filter: Filter{Lang: tt.lang}})
+			if !errors.Is(e, tt.wantErr) {
+				t.Fatalf("Find() error = %v, wantErr %v", e, tt.wantErr)
+			}
+			if e != nil {
+				return
+			}
+
+			var got []string
+			for _, hit := range res.Hits {
+				got = append(got, hit.Path)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	s := &Searcher{fs: fsys}
+	s.Scan()
+
+	langs := make(map[string]string)
+	for _, f := range s.Files {
+		langs[f.Path] = f.Lang
+	}
+
+	want := map[string]string{"en.txt": "en", "ru.txt": "ru", "mix.txt": "ru", "short.txt": ""}
+	if !reflect.DeepEqual(langs, want) {
+		t.Errorf("languages = %v, want %v", langs, want)
+	}
+}
@@ -0,0 +1,188 @@
+package searcher
+
+import (
+	"io"
+	"io/fs"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limit names a resource limit that fired while scanning a file
+type Limit string
+
+const (
+	// The file is larger than MaxFileSize or MemoryBudget, it is not indexed
+	LimitFileSize Limit = "file_size"
+	// A line or a sentence is longer than MaxLineLength, only its beginning is indexed
+	LimitLineLength Limit = "line_length"
+	// The file has more words than MaxTokens, only the first ones are indexed
+	LimitTokens Limit = "tokens"
+	// The walker waited for the memory budget before reading the file
+	LimitMemory Limit = "memory_budget"
+)
+
+// Limits bound the resources of a scan, a zero field means no limit
+type Limits struct {
+	// Larger files are not indexed, in bytes
+	MaxFileSize int64
+	// Longer lines or sentences are cut, in bytes
+	MaxLineLength int
+	// Words of a file past this number are not indexed, stop words included
+	MaxTokens int
+	// Bytes of the files read but not indexed yet, the walker waits when the budget
+	// is used up. A file is read whole, the larger ones are not indexed
+	MemoryBudget int64
+}
+
+// DefaultLimits apply unless WithLimits is given. The memory of a scan is bounded, so
+// are the files read whole, the words of the files read are all indexed
+var DefaultLimits = Limits{
+	MaxFileSize:  256 << 20,
+	MemoryBudget: 256 << 20,
+}
+
+// WithLimits sets the resource limits of the scans
+func WithLimits(l Limits) Option {
+	return func(s *Searcher) {
+		s.limits = l
+	}
+}
+
+// maxFileSize returns the size of the largest file to read, 0 for any: the smaller of
+// MaxFileSize and MemoryBudget
+func (l Limits) maxFileSize() int64 {
+	if l.MemoryBudget > 0 && (l.MaxFileSize <= 0 || l.MemoryBudget < l.MaxFileSize) {
+		return l.MemoryBudget
+	}
+
+	return max(l.MaxFileSize, 0)
+}
+
+// readFile reads the file whole, ok is false when it has more than max bytes, 0 reads
+// any. A file growing since it was walked is never read past the limit
+func readFile(fsys fs.FS, name string, max int64) (content []byte, ok bool, e error) {
+	if max <= 0 {
+		content, e = fs.ReadFile(fsys, name)
+		return content, e == nil, e
+	}
+
+	f, e := fsys.Open(name)
+	if e != nil {
+		return nil, false, e
+	}
+	defer f.Close()
+
+	if content, e = io.ReadAll(io.LimitReader(f, max+1)); e != nil {
+		return nil, false, e
+	}
+
+	if int64(len(content)) > max {
+		return nil, false, nil
+	}
+
+	return content, true, nil
+}
+
+// memoryBudget is a counting semaphore of bytes, a nil budget is unlimited
+type memoryBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+func newMemoryBudget(limit int64) *memoryBudget {
+	if limit <= 0 {
+		return nil
+	}
+
+	b := &memoryBudget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+
+	return b
+}
+
+// acquire waits until n bytes fit in the budget and reports whether it had to wait.
+// The scan never requests more than the whole budget, see Limits.maxFileSize
+func (b *memoryBudget) acquire(n int64) bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	waited := false
+	for b.used > 0 && b.used+n > b.limit {
+		waited = true
+		b.cond.Wait()
+	}
+
+	b.used += n
+
+	return waited
+}
+
+func (b *memoryBudget) release(n int64) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+
+	b.cond.Broadcast()
+}
+
+// cutLine returns the length of the beginning of the line that fits in max bytes
+// without splitting a character or, when possible, a word
+func cutLine(line []byte, max int) int {
+	if max <= 0 || len(line) <= max {
+		return len(line)
+	}
+
+	end := max
+	for end > 0 && !utf8.RuneStart(line[end]) {
+		end--
+	}
+
+	// A word cut in the middle would be indexed as another word
+	if r, _ := utf8.DecodeRune(line[end:]); !unicode.IsSpace(r) {
+		for i := end; i > 0; {
+			r, size := utf8.DecodeLastRune(line[:i])
+			if unicode.IsSpace(r) {
+				return i
+			}
+			i -= size
+		}
+	}
+
+	return end
+}
+
+// cutWords returns the length of the beginning of the text holding at most n words,
+// split the way strings.Fields splits them, and the number of the words in it
+func cutWords(text []byte, n int) (int, int) {
+	words := 0
+	inWord := false
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+
+		if unicode.IsSpace(r) {
+			inWord = false
+		} else if !inWord {
+			if words == n {
+				return i, words
+			}
+			inWord = true
+			words++
+		}
+
+		i += size
+	}
+
+	return len(text), words
+}
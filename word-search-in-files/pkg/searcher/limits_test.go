@@ -0,0 +1,178 @@
+package searcher
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestCutLine(t *testing.T) {
+	tests := []struct {
+		line string
+		max  int
+		want string
+	}{
+		{line: "short", max: 10, want: "short"},
+		{line: "no limit", max: 0, want: "no limit"},
+		{line: "apple pear plum", max: 12, want: "apple pear "},
+		{line: "apple pear plum", max: 11, want: "apple pear "},
+		{line: "кот пёс", max: 8, want: "кот "},
+		{line: "длинноеслово", max: 5, want: "дл"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.line[:cutLine([]byte(tt.line), tt.max)]; got != tt.want {
+			t.Errorf("cutLine(%q, %d) = %q, want %q", tt.line, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestCutWords(t *testing.T) {
+	tests := []struct {
+		text      string
+		n         int
+		want      string
+		wantWords int
+	}{
+		{text: "a b c", n: 5, want: "a b c", wantWords: 3},
+		{text: "a b c", n: 2, want: "a b ", wantWords: 2},
+		{text: "  a  b", n: 0, want: "  ", wantWords: 0},
+		{text: "   ", n: 0, want: "   ", wantWords: 0},
+	}
+
+	for _, tt := range tests {
+		n, words := cutWords([]byte(tt.text), tt.n)
+		if tt.text[:n] != tt.want || words != tt.wantWords {
+			t.Errorf("cutWords(%q, %d) = %q, %d, want %q, %d", tt.text, tt.n, tt.text[:n], words, tt.want, tt.wantWords)
+		}
+	}
+}
+
+func TestSearcher_ScanLimits(t *testing.T) {
+	s := &Searcher{
+		fs: fstest.MapFS{
+			"big.txt":    {Data: []byte("apple pear plum cherry fig")},
+			"line.txt":   {Data: []byte("apple\npear plum cherry")},
+			"tokens.txt": {Data: []byte("apple pear\nplum cherry")},
+			"ok.txt":     {Data: []byte("apple")},
+		},
+		limits: Limits{MaxFileSize: 22, MaxLineLength: 10, MaxTokens: 2},
+	}
+	s.Scan()
+
+	limits := make(map[string][]Limit)
+	for _, f := range s.Files {
+		limits[f.Path] = f.Limits
+	}
+
+	want := map[string][]Limit{
+		"big.txt":    {LimitFileSize},
+		"line.txt":   {LimitLineLength, LimitTokens},
+		"tokens.txt": {LimitTokens},
+		"ok.txt":     nil,
+	}
+
+	if !reflect.DeepEqual(limits, want) {
+		t.Errorf("Limits = %v, want %v", limits, want)
+	}
+
+	var pears []string
+	for index := range s.Words["pear"] {
+		pears = append(pears, s.Files[index].Path)
+	}
+	sort.Strings(pears)
+
+	if !reflect.DeepEqual(pears, []string{"line.txt", "tokens.txt"}) {
+		t.Errorf("files with pear = %v", pears)
+	}
+
+	if _, ok := s.Words["plum"]; ok {
+		t.Errorf("words past the limits are indexed")
+	}
+
+	if got := s.LastScan().Limited; !reflect.DeepEqual(got, map[Limit]int{LimitFileSize: 1, LimitLineLength: 1, LimitTokens: 2}) {
+		t.Errorf("LastScan().Limited = %v", got)
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	b := newMemoryBudget(10)
+
+	if b.acquire(6) {
+		t.Errorf("acquire() waited on an empty budget")
+	}
+
+	acquired := make(chan bool)
+	go func() { acquired <- b.acquire(6) }()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquire() did not wait for the budget")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	b.release(6)
+
+	if waited := <-acquired; !waited {
+		t.Errorf("acquire() = false, want true")
+	}
+
+	b.release(6)
+}
+
+func TestSearcher_ScanMemoryBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		want   map[string][]Limit
+	}{
+		{name: "Larger than the budget", limits: Limits{MemoryBudget: 10}, want: map[string][]Limit{"big.txt": {LimitFileSize}, "ok.txt": nil}},
+		{name: "Larger than the file size", limits: Limits{MaxFileSize: 10, MemoryBudget: 100}, want: map[string][]Limit{"big.txt": {LimitFileSize}, "ok.txt": nil}},
+		{name: "No limits", want: map[string][]Limit{"big.txt": nil, "ok.txt": nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Searcher{
+				fs: fstest.MapFS{
+					"big.txt": {Data: []byte("apple pear plum cherry")},
+					"ok.txt":  {Data: []byte("apple")},
+				},
+				limits: tt.limits,
+			}
+			s.Scan()
+
+			limits := make(map[string][]Limit)
+			for _, f := range s.Files {
+				limits[f.Path] = f.Limits
+			}
+
+			if !reflect.DeepEqual(limits, tt.want) {
+				t.Errorf("Limits = %v, want %v", limits, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	fsys := fstest.MapFS{"a.txt": {Data: []byte("apple pear")}}
+
+	tests := []struct {
+		max    int64
+		want   string
+		wantOk bool
+	}{
+		{max: 0, want: "apple pear", wantOk: true},
+		{max: 10, want: "apple pear", wantOk: true},
+		{max: 9, wantOk: false},
+	}
+
+	for _, tt := range tests {
+		content, ok, e := readFile(fsys, "a.txt", tt.max)
+		if e != nil || ok != tt.wantOk || string(content) != tt.want {
+			t.Errorf("readFile(%d) = %q, %v, %v, want %q, %v", tt.max, content, ok, e, tt.want, tt.wantOk)
+		}
+	}
+}
@@ -0,0 +1,844 @@
+package searcher
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Number of hits returned when the query does not set a limit
+	DefaultLimit = 100
+	// Upper bound of a single page
+	MaxLimit = 1000
+)
+
+type SortOrder string
+
+const (
+	// By path, ascending
+	SortPath SortOrder = "path"
+	// By modification time, newest first
+	SortModified SortOrder = "mtime"
+	// By relevance (tf-idf), highest first
+	SortScore SortOrder = "score"
+)
+
+var (
+	ErrEmptyQuery  = errors.New("query has no words")
+	ErrBadSort     = errors.New("unknown sort order")
+	ErrBadLimit    = errors.New("limit and offset must not be negative")
+	ErrBadFilter   = errors.New("invalid filter")
+	ErrBadCursor   = errors.New("malformed cursor")
+	ErrStaleCursor = errors.New("cursor refers to an outdated index, start over")
+)
+
+// Query is a search for the files containing all of the words
+type Query struct {
+	// Words of the content, or of a field with its prefix: `name:report` or
+	// `path:archive/2023`
+	Words  []string
+	Filter Filter
+	Sort   SortOrder
+	// Weight of the matches in the file names, the ones of the `name:` words and of the
+	// words of the content, against 1 for the content. 0 means DefaultNameBoost
+	NameBoost float64
+
+	// Limit of hits per page, 0 means DefaultLimit, capped by MaxLimit
+	Limit int
+	// Offset of the first hit, ignored when Cursor is set
+	Offset int
+	// Cursor returned as Result.NextCursor by the previous page
+	Cursor string
+}
+
+// Filter restricts the matched files, zero fields do not restrict anything
+type Filter struct {
+	// Only files under this path, "docs/" or "docs" both match "docs/a.txt"
+	PathPrefix string
+	// Only files under one of the directories, "." is the whole tree. nil does not
+	// restrict, an empty list matches no file. The access lists of the callers end up here
+	Subtrees []string
+	// Only files with one of the extensions, with or without the leading dot, case insensitive
+	Ext []string
+	// Only files in one of the detected languages, LangUnknown for the undetected ones.
+	// With analyzers the query words are only analyzed for these languages
+	Lang []string
+
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+
+	MinSize int64
+	// 0 means no upper bound
+	MaxSize int64
+}
+
+type Hit struct {
+	Path     string
+	Score    float64
+	Modified time.Time
+	Size     int64
+	// Detected language of the file, "" when unknown
+	Lang string
+	// Lines or sentences containing all the query words, only for a granularity
+	// finer than a file
+	Segments []Segment
+}
+
+// Segment is a line or a sentence of a file
+type Segment struct {
+	// Number of the segment in the file, from 0
+	Index int
+	// Byte offsets of the segment in the file
+	Start, End int
+	// Empty when the file cannot be read, e.g. for an index loaded from a snapshot
+	Text string
+}
+
+type Result struct {
+	Hits []Hit
+	// Number of matching files across all pages
+	Total int
+	// Effective page size
+	Limit int
+	// Token for the next page, empty on the last one
+	NextCursor string
+	// Snapshot of the index the result was computed against
+	Generation uint64
+	// Errors of the scan that produced the snapshot, about the subtrees of the filter
+	Errors []error
+	// Known terms close to the words missing from the index, only when nothing is found
+	Corrections []Correction
+	// Stop words of the query, they were ignored
+	StopWords []string
+	// Synonyms the query words were expanded to
+	Expansions []Expansion
+	// Version of the synonyms the query was expanded with, it changes with SetSynonyms
+	Synonyms uint64
+}
+
+// Find returns a page of the files containing all of the query words. The order is
+// deterministic for the same query against the same snapshot of the index, so the
+// pages can be walked with the returned cursor
+func (s *Searcher) Find(q Query) (*Result, error) {
+	m, e := s.execute(q, false)
+	if e != nil {
+		return nil, e
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	res := m.result(limit)
+
+	if m.offset < len(m.hits) {
+		end := min(m.offset+limit, len(m.hits))
+		res.Hits = make([]Hit, 0, end-m.offset)
+
+		for _, h := range m.hits[m.offset:end] {
+			res.Hits = append(res.Hits, m.hit(h))
+		}
+	}
+
+	return res, nil
+}
+
+// Stream calls fn for every hit of the query in order, starting at the offset or
+// cursor, and returns the result without the hits. A zero Limit streams all the hits.
+// Sorted by path or modification time, the candidate files are put in order first and
+// fn gets every hit as soon as its file is matched, the ones sorted by score once all
+// are. The snapshot of the index is taken up front, so a scan running meanwhile does
+// not affect the stream. It stops at the first error of fn or when ctx is done
+func (s *Searcher) Stream(ctx context.Context, q Query, fn func(Hit) error) (*Result, error) {
+	m, e := s.execute(q, true)
+	if e != nil {
+		return nil, e
+	}
+
+	if m.pending != nil {
+		return m.stream(ctx, q.Limit, fn)
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = len(m.hits)
+	}
+
+	res := m.result(limit)
+
+	if m.offset < len(m.hits) {
+		end := min(m.offset+limit, len(m.hits))
+
+		for _, h := range m.hits[m.offset:end] {
+			if e := ctx.Err(); e != nil {
+				return res, e
+			}
+
+			if e := fn(m.hit(h)); e != nil {
+				return res, e
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// matches are the sorted hits of a query against a snapshot of the index
+type matches struct {
+	hits []scoredHit
+	// The snapshot the indices of the hits refer to, a scan replaces the slice but
+	// never modifies it, so it can be read without holding the lock
+	files []FileInfo
+	spans [][]span
+	// To read the text of the segments
+	fs          fs.FS
+	offset      int
+	sort        SortOrder
+	generation  uint64
+	errors      []error
+	corrections []Correction
+	stopped     []string
+	expansions  []Expansion
+	synonyms    uint64
+
+	// Of a stream, the files left to match in the order of the hits, hits is empty
+	// until they are all checked
+	pending    *matcher
+	candidates []scoredHit
+	checked    int
+	// Where the hits of a stream go once they are all known
+	cache *resultCache
+	key   string
+}
+
+// execute validates the query and returns its matches, paging is left to the caller.
+// For a stream the files are left to match without holding the lock when the order
+// does not depend on the scores
+func (s *Searcher) execute(q Query, stream bool) (*matches, error) {
+	words, stopped := s.normalizeWords(q.Words, q.Filter.Lang)
+	if len(words) == 0 {
+		if len(stopped) > 0 {
+			return nil, fmt.Errorf("%w: only stop words %q", ErrEmptyQuery, stopped)
+		}
+		return nil, ErrEmptyQuery
+	}
+
+	sortOrder := q.Sort
+	if sortOrder == "" {
+		sortOrder = SortPath
+	}
+
+	if sortOrder != SortPath && sortOrder != SortModified && sortOrder != SortScore {
+		return nil, fmt.Errorf("%w: %q", ErrBadSort, q.Sort)
+	}
+
+	if q.Limit < 0 || q.Offset < 0 {
+		return nil, ErrBadLimit
+	}
+
+	nameBoost := q.NameBoost
+	if nameBoost == 0 {
+		nameBoost = DefaultNameBoost
+	}
+
+	if nameBoost < 0 {
+		return nil, fmt.Errorf("%w: %v", ErrBadBoost, q.NameBoost)
+	}
+
+	match, e := q.Filter.compile()
+	if e != nil {
+		return nil, e
+	}
+
+	s.muGlobal.RLock()
+	defer s.muGlobal.RUnlock()
+
+	key := cacheKey(words, q.Filter, sortOrder, nameBoost, s.synonymsVersion)
+
+	offset := q.Offset
+	if q.Cursor != "" {
+		c, e := decodeCursor(q.Cursor)
+		if e != nil {
+			return nil, e
+		}
+
+		if c.generation != s.generation {
+			return nil, ErrStaleCursor
+		}
+
+		if c.sort != sortOrder {
+			return nil, fmt.Errorf("%w: sort order differs from the previous page", ErrBadCursor)
+		}
+
+		if c.query != queryHash(key) {
+			return nil, fmt.Errorf("%w: query differs from the previous page", ErrBadCursor)
+		}
+
+		offset = c.offset
+	}
+
+	var pending *matcher
+	var candidates []scoredHit
+
+	// A cached query costs nothing, the limit only applies to the ones to compute
+	hits, ok := s.cache.get(key, s.generation)
+	if !ok {
+		terms, e := s.resolve(words, q.Filter.Lang, s.maxQueryCost)
+		if e != nil {
+			return nil, e
+		}
+
+		mt := s.newMatcher(terms, match, nameBoost)
+
+		if stream && mt != nil && sortOrder != SortScore {
+			// The order of the candidates is the one of their hits, it does not need
+			// the scores
+			pending = mt
+			candidates = make([]scoredHit, 0, len(mt.terms[0].postings))
+			for index := range mt.terms[0].postings {
+				candidates = append(candidates, scoredHit{index: index})
+			}
+			s.sortHits(candidates, sortOrder)
+		} else {
+			hits = mt.all()
+			s.sortHits(hits, sortOrder)
+			s.cache.put(key, s.generation, hits)
+		}
+	}
+
+	m := &matches{
+		hits:       hits,
+		fs:         s.fs,
+		files:      s.Files,
+		spans:      s.spans,
+		offset:     offset,
+		sort:       sortOrder,
+		generation: s.generation,
+		errors:     s.errorsIn(q.Filter.Subtrees),
+		stopped:    stopped,
+		expansions: s.expansions(words),
+		synonyms:   s.synonymsVersion,
+		pending:    pending,
+		candidates: candidates,
+		cache:      s.cache,
+		key:        key,
+	}
+
+	// A word missing from the visible files leaves no hits, so the corrections of a
+	// stream do not wait for its matches
+	if len(hits) == 0 {
+		m.corrections = s.corrections(words, s.visible(q.Filter.Subtrees))
+	}
+
+	return m, nil
+}
+
+// stream matches the pending files one by one and calls fn for the hits of the page of
+// the limit, 0 for all of them. The hits are cached once all the files are checked
+func (m *matches) stream(ctx context.Context, limit int, fn func(Hit) error) (*Result, error) {
+	var hits []scoredHit
+
+	walk := func() error {
+		for _, c := range m.candidates {
+			if e := ctx.Err(); e != nil {
+				return e
+			}
+
+			hit, ok := m.pending.match(c.index)
+			m.checked++
+			if !ok {
+				continue
+			}
+
+			hits = append(hits, hit)
+
+			if n := len(hits) - 1; n >= m.offset && (limit == 0 || n < m.offset+limit) {
+				if e := fn(m.hit(hit)); e != nil {
+					return e
+				}
+			}
+		}
+
+		return nil
+	}
+
+	e := walk()
+
+	m.hits = hits
+	if e == nil {
+		m.cache.put(m.key, m.generation, hits)
+	}
+
+	if limit == 0 {
+		limit = len(hits)
+	}
+
+	return m.result(limit), e
+}
+
+// result describes the page of the limit without the hits
+func (m *matches) result(limit int) *Result {
+	res := &Result{
+		Total:       len(m.hits),
+		Limit:       limit,
+		Generation:  m.generation,
+		Errors:      m.errors,
+		Corrections: m.corrections,
+		StopWords:   m.stopped,
+		Expansions:  m.expansions,
+		Synonyms:    m.synonyms,
+	}
+
+	if end := m.offset + limit; end < len(m.hits) {
+		res.NextCursor = cursor{generation: m.generation, offset: end, sort: m.sort, query: queryHash(m.key)}.encode()
+	}
+
+	return res
+}
+
+func (m *matches) hit(h scoredHit) Hit {
+	f := m.files[h.index]
+	hit := Hit{Path: f.Path, Score: h.score, Modified: f.Modified, Size: f.Size, Lang: f.Lang}
+
+	if len(h.segments) > 0 && h.index < len(m.spans) {
+		hit.Segments = m.segmentsOf(f.Path, m.spans[h.index], h.segments)
+	}
+
+	return hit
+}
+
+// segmentsOf reads the text of the segments from the file, the ones that do not fit
+// in a file modified since the scan are left without the text
+func (m *matches) segmentsOf(path string, spans []span, segments []int) []Segment {
+	// A Searcher loaded from a snapshot may have no files to read, the segments
+	// keep their positions without the text then
+	var content []byte
+	if m.fs != nil {
+		content, _ = fs.ReadFile(m.fs, path)
+	}
+
+	res := make([]Segment, 0, len(segments))
+
+	for _, i := range segments {
+		if i >= len(spans) {
+			continue
+		}
+
+		sp := spans[i]
+		sg := Segment{Index: i, Start: sp.start, End: sp.end}
+		if sp.end <= len(content) {
+			sg.Text = string(content[sp.start:sp.end])
+		}
+		res = append(res, sg)
+	}
+
+	return res
+}
+
+type scoredHit struct {
+	index int
+	score float64
+	// Sorted segments containing all the words
+	segments []int
+}
+
+// match returns the files containing all the terms and accepted by the filter with
+// their tf-idf scores, the ones of the names weighed by nameBoost. The caller must
+// hold muGlobal
+func (s *Searcher) match(terms []queryTerm, accept func(FileInfo) bool, nameBoost float64) []scoredHit {
+	return s.newMatcher(terms, accept, nameBoost).all()
+}
+
+// matcher checks the files against the terms of a query. It holds the snapshot of the
+// index it reads, a scan replaces the maps and the slices but never modifies them, so
+// it runs without holding the lock
+type matcher struct {
+	// The rarest first, its files are the candidates
+	terms []queryTerm
+	// The terms of the content, the segments of a file must contain them all
+	content     []queryTerm
+	files       []FileInfo
+	segments    map[string]map[int]map[int]struct{}
+	granularity Granularity
+	accept      func(FileInfo) bool
+	nameBoost   float64
+}
+
+// newMatcher returns the matcher of the terms, nil when one of them matches nothing.
+// The caller must hold muGlobal
+func (s *Searcher) newMatcher(terms []queryTerm, accept func(FileInfo) bool, nameBoost float64) *matcher {
+	mt := &matcher{
+		files:       s.Files,
+		segments:    s.segments,
+		granularity: s.granularity,
+		accept:      accept,
+		nameBoost:   nameBoost,
+	}
+
+	for _, t := range terms {
+		if len(t.postings) == 0 {
+			return nil
+		}
+
+		if t.field == "" {
+			mt.content = append(mt.content, t)
+		}
+	}
+
+	// Walk the rarest word and check the others
+	mt.terms = slices.Clone(terms)
+	sort.Slice(mt.terms, func(i, j int) bool { return len(mt.terms[i].postings) < len(mt.terms[j].postings) })
+
+	return mt
+}
+
+// all returns the hits of all the candidates, nil for a nil matcher
+func (mt *matcher) all() []scoredHit {
+	if mt == nil {
+		return nil
+	}
+
+	hits := make([]scoredHit, 0, len(mt.terms[0].postings))
+	for index := range mt.terms[0].postings {
+		if hit, ok := mt.match(index); ok {
+			hits = append(hits, hit)
+		}
+	}
+
+	return hits
+}
+
+// match checks a candidate file and returns its hit with the score
+func (mt *matcher) match(index int) (scoredHit, bool) {
+	if !mt.accept(mt.files[index]) {
+		return scoredHit{}, false
+	}
+
+	n := len(mt.files)
+	score := 0.0
+
+	for _, t := range mt.terms {
+		tf, ok := t.postings[index]
+		if !ok {
+			return scoredHit{}, false
+		}
+
+		switch t.field {
+		case FieldName:
+			score += mt.nameBoost * tfidf(tf, len(t.postings), n)
+		case FieldPath:
+			score += tfidf(tf, len(t.postings), n)
+		default:
+			score += tfidf(tf, len(t.postings), n)
+
+			if tf := t.names[index]; tf > 0 {
+				score += mt.nameBoost * tfidf(tf, len(t.names), n)
+			}
+		}
+	}
+
+	hit := scoredHit{index: index, score: score}
+
+	// The fields match the whole file
+	if mt.granularity != GranularityFile && len(mt.content) > 0 {
+		if hit.segments = mt.matchSegments(index); len(hit.segments) == 0 {
+			return scoredHit{}, false
+		}
+	}
+
+	return hit, true
+}
+
+// matchSegments returns the sorted segments of the file containing all the terms of
+// the content
+func (mt *matcher) matchSegments(index int) []int {
+	sets := make([]map[int]struct{}, len(mt.content))
+	for i, t := range mt.content {
+		if len(t.terms) == 1 {
+			sets[i] = mt.segments[t.terms[0]][index]
+			continue
+		}
+
+		// The segments of any of the terms of a pattern
+		sets[i] = make(map[int]struct{})
+		for _, term := range t.terms {
+			for segment := range mt.segments[term][index] {
+				sets[i][segment] = struct{}{}
+			}
+		}
+	}
+
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+
+	var res []int
+
+next:
+	for segment := range sets[0] {
+		for _, set := range sets[1:] {
+			if _, ok := set[segment]; !ok {
+				continue next
+			}
+		}
+
+		res = append(res, segment)
+	}
+
+	sort.Ints(res)
+
+	return res
+}
+
+// sortHits orders the hits, ties are broken by path so the order is total
+func (s *Searcher) sortHits(hits []scoredHit, order SortOrder) {
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := s.Files[hits[i].index], s.Files[hits[j].index]
+
+		switch order {
+		case SortModified:
+			if !a.Modified.Equal(b.Modified) {
+				return a.Modified.After(b.Modified)
+			}
+		case SortScore:
+			if hits[i].score != hits[j].score {
+				return hits[i].score > hits[j].score
+			}
+		}
+
+		return a.Path < b.Path
+	})
+}
+
+// compile validates the filter and returns the predicate applying it
+func (f Filter) compile() (func(FileInfo) bool, error) {
+	if f.MinSize < 0 || f.MaxSize < 0 || (f.MaxSize > 0 && f.MinSize > f.MaxSize) {
+		return nil, fmt.Errorf("%w: size range [%d, %d]", ErrBadFilter, f.MinSize, f.MaxSize)
+	}
+
+	if !f.ModifiedAfter.IsZero() && !f.ModifiedBefore.IsZero() && !f.ModifiedAfter.Before(f.ModifiedBefore) {
+		return nil, fmt.Errorf("%w: modified_after must be before modified_before", ErrBadFilter)
+	}
+
+	prefix := strings.TrimPrefix(f.PathPrefix, "./")
+	dirPrefix := prefix != "" && !strings.HasSuffix(prefix, "/")
+
+	exts := make(map[string]struct{}, len(f.Ext))
+	for _, ext := range f.Ext {
+		if ext = strings.TrimPrefix(strings.ToLower(ext), "."); ext != "" {
+			exts["."+ext] = struct{}{}
+		}
+	}
+
+	subtrees := f.subtrees()
+
+	langs := make(map[string]struct{}, len(f.Lang))
+	for _, lang := range f.Lang {
+		if lang != LangUnknown && !slices.Contains(Languages(), lang) {
+			return nil, fmt.Errorf("%w: unknown language %q, expected one of %s or %s", ErrBadFilter, lang, strings.Join(Languages(), ", "), LangUnknown)
+		}
+
+		if lang == LangUnknown {
+			lang = ""
+		}
+		langs[lang] = struct{}{}
+	}
+
+	return func(fi FileInfo) bool {
+		if prefix != "" && !strings.HasPrefix(fi.Path, prefix) {
+			return false
+		}
+
+		// "docs" must not match "docs2/a.txt"
+		if dirPrefix && len(fi.Path) > len(prefix) && fi.Path[len(prefix)] != '/' {
+			return false
+		}
+
+		if subtrees != nil && !InSubtrees(fi.Path, subtrees) {
+			return false
+		}
+
+		if len(exts) > 0 {
+			if _, ok := exts[strings.ToLower(path.Ext(fi.Path))]; !ok {
+				return false
+			}
+		}
+
+		if len(langs) > 0 {
+			if _, ok := langs[fi.Lang]; !ok {
+				return false
+			}
+		}
+
+		if !f.ModifiedAfter.IsZero() && !fi.Modified.After(f.ModifiedAfter) {
+			return false
+		}
+
+		if !f.ModifiedBefore.IsZero() && !fi.Modified.Before(f.ModifiedBefore) {
+			return false
+		}
+
+		if fi.Size < f.MinSize || (f.MaxSize > 0 && fi.Size > f.MaxSize) {
+			return false
+		}
+
+		return true
+	}, nil
+}
+
+// subtrees returns the cleaned subtrees, sorted, nil when they do not restrict
+func (f Filter) subtrees() []string {
+	if f.Subtrees == nil {
+		return nil
+	}
+
+	res := make([]string, 0, len(f.Subtrees))
+	for _, dir := range f.Subtrees {
+		dir = path.Clean("/" + strings.TrimSpace(dir))[1:]
+		if dir == "" {
+			return nil
+		}
+		res = append(res, dir)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// InSubtrees reports whether the file is in one of the directories, or is one of
+// them. "" or "." is the whole tree
+func InSubtrees(file string, dirs []string) bool {
+	file = strings.TrimPrefix(file, "./")
+
+	for _, dir := range dirs {
+		dir = strings.Trim(strings.TrimPrefix(dir, "./"), "/")
+
+		if dir == "" || dir == "." || file == dir || strings.HasPrefix(file, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// errorsIn returns the errors of the scan about the paths in the subtrees, the ones
+// without a path only when nil does not restrict. The caller must hold muGlobal
+func (s *Searcher) errorsIn(subtrees []string) []error {
+	dirs := Filter{Subtrees: subtrees}.subtrees()
+	if dirs == nil {
+		return s.Errors
+	}
+
+	var res []error
+	for _, e := range s.Errors {
+		var pe *fs.PathError
+		if errors.As(e, &pe) && InSubtrees(pe.Path, dirs) {
+			res = append(res, e)
+		}
+	}
+
+	return res
+}
+
+// normalizeWords applies the same normalization as the scanner and drops duplicates,
+// the stop words are returned separately. With analyzers a word is a stop word when it
+// is one in every language of langs
+func (s *Searcher) normalizeWords(words []string, langs []string) (res []string, stopped []string) {
+	seen := make(map[string]struct{}, len(words))
+	res = make([]string, 0, len(words))
+
+	for _, field := range words {
+		for _, word := range strings.Fields(field) {
+			if f, value, ok := fieldOf(word); ok {
+				for _, w := range normalizeField(f, value) {
+					if _, ok := seen[w]; !ok {
+						seen[w] = struct{}{}
+						res = append(res, w)
+					}
+				}
+				continue
+			}
+
+			if isWildcard(word) {
+				word = normalizeWildcard(word)
+			} else {
+				word = removePunctuation(word)
+			}
+
+			if word == "" {
+				continue
+			}
+
+			if _, ok := seen[word]; ok {
+				continue
+			}
+			seen[word] = struct{}{}
+
+			if !isWildcard(word) && (s.isStopWord(word) || len(s.variants(word, langs)) == 0) {
+				stopped = append(stopped, word)
+				continue
+			}
+
+			res = append(res, word)
+		}
+	}
+
+	return res, stopped
+}
+
+// cursor is the position of the next page of a query, bound to the query by the hash
+// of its cache key: the words, the filter with the subtrees, the order and the boost
+type cursor struct {
+	generation uint64
+	offset     int
+	sort       SortOrder
+	query      string
+}
+
+func (c cursor) encode() string {
+	raw := fmt.Sprintf("%d:%d:%s:%s", c.generation, c.offset, c.sort, c.query)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (cursor, error) {
+	raw, e := base64.RawURLEncoding.DecodeString(s)
+	if e != nil {
+		return cursor{}, ErrBadCursor
+	}
+
+	parts := strings.SplitN(string(raw), ":", 4)
+	if len(parts) != 4 {
+		return cursor{}, ErrBadCursor
+	}
+
+	generation, e := strconv.ParseUint(parts[0], 10, 64)
+	if e != nil {
+		return cursor{}, ErrBadCursor
+	}
+
+	offset, e := strconv.Atoi(parts[1])
+	if e != nil || offset < 0 {
+		return cursor{}, ErrBadCursor
+	}
+
+	return cursor{generation: generation, offset: offset, sort: SortOrder(parts[2]), query: parts[3]}, nil
+}
+
+// queryHash identifies the query of the cache key in a cursor
+func queryHash(key string) string {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	return strconv.FormatUint(h.Sum64(), 36)
+}
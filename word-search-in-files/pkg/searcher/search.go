@@ -1,15 +1,15 @@
 package searcher
 
 import (
-	"bufio"
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"io/fs"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"word-search-in-files/pkg/pool"
 )
@@ -17,36 +17,186 @@ import (
 const (
 	workersNum            = 100
 	workerTaskChannelSize = 100
-	// Maximum line length
-	scanBufferSize = 5 * 1024 * 1024
 )
 
+var ErrNoFS = errors.New("no files to scan")
+
 type Searcher struct {
 	fs     fs.FS
 	absDir string
 
-	// Mutex for the struct while scaning in process
-	muGlobal sync.Mutex
+	// Held for writing by a scan or a load, for reading by the queries, which run
+	// concurrently
+	muGlobal sync.RWMutex
 
 	Files  []FileInfo
 	Errors []error
-	Words  map[string]map[int]struct{}
+	// Entries left out by the walker
+	skipped []SkippedEntry
+	// Number of the files the last scan took from the previous generation
+	reused int
+	// What the walker does with symbolic links
+	symlinks SymlinkPolicy
+	// Resource limits of the scans
+	limits Limits
+	// Word -> index of the file in Files -> number of occurrences
+	Words map[string]map[int]int
+	// Sorted words of the index, for prefix lookups
+	dict []string
+	// The sorted words by their number of runes, for the corrections
+	byLength map[int][]string
+	// Lengths of the tf-idf vectors of the files and their sorted terms, for the
+	// similar files
+	norms     []float64
+	fileTerms [][]string
+	// The fields of the files: word of the names or component of the paths -> index
+	// of the file -> number of occurrences
+	names      map[string]map[int]int
+	components map[string]map[int]int
+
+	// Queries walking more dictionary terms and postings are rejected, 0 does not limit
+	maxQueryCost int
+
+	// Words left out of the index and the queries, lower case
+	stopWords map[string]struct{}
+	// The words of the files go through the analyzer of their language
+	analyzers bool
+	// Expansions of the query words, nil for none
+	synonyms *Synonyms
+	// Incremented by every SetSynonyms, identifies the rules the queries are expanded with
+	synonymsVersion uint64
+
+	// The unit of the index within a file
+	granularity Granularity
+	// Word -> index of the file -> segments (lines or sentences) containing the word,
+	// only for a granularity finer than a file
+	segments map[string]map[int]map[int]struct{}
+	// Byte ranges of the segments of every file, same granularity restriction
+	spans [][]span
+
+	// Incremented by every scan, identifies the snapshot of the index
+	generation uint64
+
+	// Matches of recent queries against the current generation, nil disables caching
+	cache *resultCache
+
+	// Saved queries re-evaluated after every scan
+	watch watcher
+
+	// The sync of the scan in progress, nil when idle
+	current atomic.Pointer[SearcherSync]
+
+	muStats  sync.Mutex
+	lastScan ScanStats
+	scans    uint64
+}
+
+// ScanStats describes the most recently completed scan
+type ScanStats struct {
+	Started  time.Time
+	Duration time.Duration
+	Files    int
+	Terms    int
+	Errors   int
+	// Number of the entries left out by the walker by reason
+	Skipped map[SkipReason]int
+	// Number of the files limited by limit
+	Limited map[Limit]int
+	// Number of the unchanged files taken from the previous generation
+	Reused int
+}
+
+// Granularity is the unit of the index: a query matches a file when one of its
+// units contains all the query words
+type Granularity int
+
+const (
+	GranularityFile Granularity = iota
+	GranularityLine
+	GranularitySentence
+)
+
+// ParseGranularity parses "file", "line" or "sentence"
+func ParseGranularity(v string) (Granularity, error) {
+	switch v {
+	case "", "file":
+		return GranularityFile, nil
+	case "line":
+		return GranularityLine, nil
+	case "sentence":
+		return GranularitySentence, nil
+	}
+
+	return GranularityFile, fmt.Errorf("unknown granularity %q, expected file, line or sentence", v)
+}
+
+func (g Granularity) String() string {
+	switch g {
+	case GranularityLine:
+		return "line"
+	case GranularitySentence:
+		return "sentence"
+	}
+
+	return "file"
 }
 
 type FileInfo struct {
 	Path     string
 	Modified time.Time
+	Size     int64
+	// Number of indexed words, stop words excluded
+	Tokens int
+	// Identifies the content: the ETag or the last modification of a remote object,
+	// the modification time and the size of a local file. Empty when unknown
+	Version string
+	// The limits of the scan that fired on the file
+	Limits []Limit
+	// Detected language of the content, "" when unknown
+	Lang string
+	// Of the terms for the near-duplicate detection, nil for a file without words
+	Signature *Signature
 }
 
 type SearcherSync struct {
-	pool   pool.Pool
-	wg     *sync.WaitGroup
-	resCh  chan JobResult
-	errCh  chan error
-	doneCh chan struct{}
+	pool  pool.Pool
+	wg    *sync.WaitGroup
+	resCh chan JobResult
+	// Terms of the unchanged files taken from the previous generation
+	reuseCh chan reusedFile
+	errCh   chan error
+	doneCh  chan struct{}
+	// Memory of the files read but not indexed yet
+	budget *memoryBudget
 }
 
-func NewSearcher(dir string) (*Searcher, error) {
+// Option configures the Searcher created by NewSearcher
+type Option func(*Searcher)
+
+// WithGranularity sets the unit of the index within a file
+func WithGranularity(g Granularity) Option {
+	return func(s *Searcher) {
+		s.granularity = g
+	}
+}
+
+// WithCacheSize sets the memory budget of the query result cache in bytes, 0 disables the cache
+func WithCacheSize(bytes int64) Option {
+	return func(s *Searcher) {
+		s.cache = newResultCache(bytes)
+	}
+}
+
+// WithFS reads the files from fsys instead of the directory. A nil fsys makes a
+// Searcher that is only loaded from snapshots: it cannot scan and returns the
+// segments of the hits without the text
+func WithFS(fsys fs.FS) Option {
+	return func(s *Searcher) {
+		s.fs = fsys
+	}
+}
+
+func NewSearcher(dir string, opts ...Option) (*Searcher, error) {
 	if dir == "" {
 		dir = "."
 	}
@@ -58,15 +208,25 @@ func NewSearcher(dir string) (*Searcher, error) {
 
 	absDir := dir
 
-	return &Searcher{
+	s := &Searcher{
 		fs:     os.DirFS(dir),
 		absDir: absDir,
 
-		Words: make(map[string]map[int]struct{}),
-	}, nil
+		Words:  make(map[string]map[int]int),
+		cache:  newResultCache(DefaultCacheSize),
+		limits: DefaultLimits,
+
+		maxQueryCost: DefaultMaxQueryCost,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
 }
 
-func newSearcherSync() (*SearcherSync, error) {
+func newSearcherSync(limits Limits) (*SearcherSync, error) {
 
 	pool, err := pool.NewPool(workersNum, workerTaskChannelSize)
 	if err != nil {
@@ -74,44 +234,89 @@ func newSearcherSync() (*SearcherSync, error) {
 	}
 
 	return &SearcherSync{
-		pool:   pool,
-		wg:     &sync.WaitGroup{},
-		resCh:  make(chan JobResult),
-		errCh:  make(chan error),
-		doneCh: make(chan struct{}),
+		pool:    pool,
+		wg:      &sync.WaitGroup{},
+		resCh:   make(chan JobResult),
+		reuseCh: make(chan reusedFile),
+		errCh:   make(chan error),
+		doneCh:  make(chan struct{}),
+		budget:  newMemoryBudget(limits.MemoryBudget),
 	}, nil
 }
 
 func (s *Searcher) Scan() error {
+	if s.fs == nil {
+		return ErrNoFS
+	}
+
 	s.muGlobal.Lock()
 	defer s.muGlobal.Unlock()
 
+	// The unchanged files are taken from the current generation
+	prev := s.previous()
+
 	s.cleanBeforeScan()
 
-	snc, e := newSearcherSync()
+	started := time.Now()
+
+	snc, e := newSearcherSync(s.limits)
 	if e != nil {
 		return e
 	}
 
+	s.current.Store(snc)
+	defer s.current.Store(nil)
+
 	snc.pool.Start()
 
 	snc.wg.Add(1)
-	go s.predictWalkDir(snc)
+	go s.predictWalkDir(snc, prev)
 
 	go func() {
 		snc.wg.Wait()
 		close(snc.resCh)
+		close(snc.reuseCh)
 		close(snc.errCh)
 		snc.pool.Stop()
 		snc.doneCh <- struct{}{}
 		snc.doneCh <- struct{}{}
 	}()
 
+	// Number of indexed words of every file
+	var tokens []int
+
 	for i := 2; i > 0; {
 		select {
 		case w, ok := <-snc.resCh:
 			if ok {
 				addWordToMap(s.Words, w.Word, w.Index)
+
+				for len(tokens) <= w.Index {
+					tokens = append(tokens, 0)
+				}
+				tokens[w.Index]++
+
+				if s.granularity != GranularityFile {
+					addSegmentToMap(s.segments, w.Word, w.Index, w.Segment)
+				}
+			}
+		case f, ok := <-snc.reuseCh:
+			if ok {
+				for len(tokens) <= f.index {
+					tokens = append(tokens, 0)
+				}
+
+				for _, t := range f.terms {
+					if s.Words[t.word] == nil {
+						s.Words[t.word] = make(map[int]int)
+					}
+					s.Words[t.word][f.index] = t.count
+					tokens[f.index] += t.count
+
+					for _, sg := range t.segments {
+						addSegmentToMap(s.segments, t.word, f.index, sg)
+					}
+				}
 			}
 		case e, ok := <-snc.errCh:
 			if ok {
@@ -122,9 +327,85 @@ func (s *Searcher) Scan() error {
 		}
 	}
 
+	// The walker is done, the files can be updated
+	for i := range s.Files {
+		if i < len(tokens) {
+			s.Files[i].Tokens = tokens[i]
+		}
+	}
+
+	s.sign()
+
+	s.publish(started)
+
 	return nil
 }
 
+// publish makes the index built by a scan or loaded from a snapshot the current
+// generation, the caller must hold muGlobal
+func (s *Searcher) publish(started time.Time) {
+	s.dict = sortedWords(s.Words)
+	s.byLength = termsByLength(s.dict)
+	s.norms, s.fileTerms = s.tfidfVectors()
+	s.indexFields()
+	s.generation++
+	s.cache.invalidate(s.generation)
+	s.notifyWatchers()
+
+	s.muStats.Lock()
+	s.lastScan = ScanStats{
+		Started:  started,
+		Duration: time.Since(started),
+		Files:    len(s.Files),
+		Terms:    len(s.Words),
+		Errors:   len(s.Errors),
+		Skipped:  make(map[SkipReason]int),
+		Reused:   s.reused,
+	}
+	for _, sk := range s.skipped {
+		s.lastScan.Skipped[sk.Reason]++
+	}
+	s.lastScan.Limited = make(map[Limit]int)
+	for _, f := range s.Files {
+		for _, l := range f.Limits {
+			s.lastScan.Limited[l]++
+		}
+	}
+	s.scans++
+	s.muStats.Unlock()
+}
+
+// LastScan returns the stats of the most recently completed scan
+func (s *Searcher) LastScan() ScanStats {
+	s.muStats.Lock()
+	defer s.muStats.Unlock()
+
+	return s.lastScan
+}
+
+// Scans returns the number of completed scans
+func (s *Searcher) Scans() uint64 {
+	s.muStats.Lock()
+	defer s.muStats.Unlock()
+
+	return s.scans
+}
+
+// Ready reports whether the first scan has completed and the index can be searched
+func (s *Searcher) Ready() bool {
+	return s.Scans() > 0
+}
+
+// QueueDepth returns the number of lines waiting in the worker pool of the scan
+// in progress, 0 when no scan is running
+func (s *Searcher) QueueDepth() int {
+	if snc := s.current.Load(); snc != nil {
+		return snc.pool.QueueLen()
+	}
+
+	return 0
+}
+
 func (s *Searcher) ScanPeriodically(ctx context.Context, wg *sync.WaitGroup, interval time.Duration) {
 	s.Scan()
 
@@ -145,8 +426,8 @@ func (s *Searcher) ScanPeriodically(ctx context.Context, wg *sync.WaitGroup, int
 }
 
 func (s *Searcher) Search(word string) (files []string, errors []error) {
-	s.muGlobal.Lock()
-	defer s.muGlobal.Unlock()
+	s.muGlobal.RLock()
+	defer s.muGlobal.RUnlock()
 
 	if s.Errors != nil {
 		return nil, s.Errors
@@ -166,121 +447,161 @@ func (s *Searcher) Search(word string) (files []string, errors []error) {
 }
 
 func (s *Searcher) cleanBeforeScan() {
-	s.Words = make(map[string]map[int]struct{})
+	s.Words = make(map[string]map[int]int)
+	s.dict = nil
+	s.byLength = nil
+	s.norms = nil
+	s.fileTerms = nil
+	s.names = nil
+	s.components = nil
+	s.segments = make(map[string]map[int]map[int]struct{})
+	s.spans = nil
 	s.Files = nil
 	s.Errors = nil
+	s.skipped = nil
+	s.reused = 0
 }
 
-func (s *Searcher) predictWalkDir(snc *SearcherSync) {
-	defer snc.wg.Done()
+// readByLineSimple reads the file, splits it into lines or sentences and queues a job
+// per segment, applying the limits of the scan
+func (s *Searcher) readByLineSimple(path string, snc *SearcherSync, index int) error {
+	f := &s.Files[index]
+
+	tooLarge := func() error {
+		f.Limits = append(f.Limits, LimitFileSize)
+
+		// Keep the spans aligned with the files
+		if s.granularity != GranularityFile {
+			s.spans = append(s.spans, nil)
+		}
+		return nil
+	}
+
+	maxSize := s.limits.maxFileSize()
+	if maxSize > 0 && f.Size > maxSize {
+		return tooLarge()
+	}
+
+	// The memory of the content is held until the last job of the file is done
+	size := f.Size
+	if snc.budget.acquire(size) {
+		f.Limits = append(f.Limits, LimitMemory)
+	}
 
-	e := fs.WalkDir(s.fs, ".", func(path string, di fs.DirEntry, e error) error {
+	content, ok, e := readFile(s.fs, path, maxSize)
+	if !ok {
+		snc.budget.release(size)
 		if e != nil {
 			return e
 		}
+		return tooLarge()
+	}
 
-		// For every file
-		if !di.IsDir() {
+	f.Lang = DetectLanguage(content)
+	a := s.analyzerOf(f.Lang)
 
-			// Create fullpath to the file
-			// fullpath := filepath.Join(s.absDir, path)
-			fullpath := path
+	// The jobs get the slices of the content, it is never modified
+	var spans []span
+	if s.granularity == GranularitySentence {
+		spans = splitSentences(content)
+	} else {
+		spans = splitLines(content)
+	}
 
-			// Get file info
-			fileInfo, e := di.Info()
-			if e != nil {
-				return e
-			}
+	if s.granularity != GranularityFile {
+		s.spans = append(s.spans, spans)
+	}
 
-			// Add the file to the slice of files
-			s.Files = append(s.Files, FileInfo{Path: fullpath, Modified: fileInfo.ModTime()})
+	type segmentJob struct {
+		segment int
+		text    []byte
+	}
 
-			// The index of the added file will be used later to identify the words in the map
-			index := len(s.Files) - 1
+	var jobs []segmentJob
+	tokens := 0
 
-			e = s.readByLineSimple(fullpath, snc, index)
-			if e != nil {
-				return e
-			}
+	for segment, sp := range spans {
+		text := content[sp.start:sp.end]
+
+		if n := cutLine(text, s.limits.MaxLineLength); n < len(text) {
+			text = text[:n]
+			f.Limits = appendLimit(f.Limits, LimitLineLength)
 		}
 
-		return nil
-	})
+		if s.limits.MaxTokens > 0 {
+			n, words := cutWords(text, s.limits.MaxTokens-tokens)
+			tokens += words
 
-	if e != nil {
-		snc.errCh <- e
-	}
-}
+			if n < len(text) {
+				text = text[:n]
+				f.Limits = appendLimit(f.Limits, LimitTokens)
+			}
+		}
 
-func (s *Searcher) readByLineSimple(path string, snc *SearcherSync, index int) error {
+		jobs = append(jobs, segmentJob{segment: segment, text: text})
 
-	content, e := fs.ReadFile(s.fs, path)
-	if e != nil {
-		return e
+		if s.limits.MaxTokens > 0 && tokens >= s.limits.MaxTokens {
+			// The words of the following segments are dropped
+			if n, _ := cutWords(content[sp.end:], 0); n < len(content)-sp.end {
+				f.Limits = appendLimit(f.Limits, LimitTokens)
+			}
+			break
+		}
 	}
 
-	scanner := bufio.NewScanner(bytes.NewReader(content))
-
-	for scanner.Scan() {
-		snc.wg.Add(1)
-		job := NewJob(readByWord, snc.wg, snc.resCh, snc.errCh, scanner.Bytes(), len(s.Files)-1)
-		snc.pool.AddWork(job)
+	if len(jobs) == 0 {
+		snc.budget.release(size)
+		return nil
 	}
 
-	if e := scanner.Err(); e != nil {
-		return e
-	}
+	pending := &atomic.Int64{}
+	pending.Store(int64(len(jobs)))
 
-	return nil
-}
+	read := func(line []byte, index, segment int, resCh chan<- JobResult, errCh chan<- error) error {
+		defer func() {
+			if pending.Add(-1) == 0 {
+				snc.budget.release(size)
+			}
+		}()
 
-//lint:ignore U1000 Ignore unused function
-func (s *Searcher) readByLine(path string, snc *SearcherSync, index int) error {
-	f, e := os.Open(path)
-	if e != nil {
-		return e
+		return s.readByWord(a, line, index, segment, resCh, errCh)
 	}
-	defer f.Close()
-
-	r := bufio.NewReaderSize(f, scanBufferSize)
-
-	line, isPrefix, e := r.ReadLine()
-
-	for e == nil && !isPrefix {
 
+	for _, j := range jobs {
 		snc.wg.Add(1)
-		job := NewJob(readByWord, snc.wg, snc.resCh, snc.errCh, line, len(s.Files)-1)
+		job := NewJob(read, snc.wg, snc.resCh, snc.errCh, j.text, index, j.segment)
 		snc.pool.AddWork(job)
-
-		line, isPrefix, e = r.ReadLine()
 	}
 
-	if isPrefix {
-		return fmt.Errorf("[%s]: exceeds buffer size", path)
-	}
+	return nil
+}
 
-	if e != io.EOF {
-		return e
+func appendLimit(limits []Limit, l Limit) []Limit {
+	if slices.Contains(limits, l) {
+		return limits
 	}
 
-	return nil
+	return append(limits, l)
 }
 
-func readByWord(line []byte, index int, resCh chan<- JobResult, errCh chan<- error) error {
-
-	words := bufio.NewScanner(strings.NewReader(string(line)))
+// readByWord sends the words of the line, through the analyzer of the language of the
+// file when it is not nil
+func (s *Searcher) readByWord(a *analyzer, line []byte, index, segment int, resCh chan<- JobResult, errCh chan<- error) error {
 
-	words.Split(bufio.ScanWords)
+	for _, field := range strings.Fields(string(line)) {
+		word := removePunctuation(field)
+		if word == "" || s.isStopWord(word) {
+			continue
+		}
 
-	for words.Scan() {
-		word := removePunctuation(words.Text())
-		if word != "" {
-			resCh <- JobResult{Word: word, Index: index}
+		if a != nil {
+			var ok bool
+			if word, ok = a.term(word); !ok {
+				continue
+			}
 		}
-	}
 
-	if e := words.Err(); e != nil {
-		errCh <- e
+		resCh <- JobResult{Word: word, Index: index, Segment: segment}
 	}
 
 	return nil
@@ -1,12 +1,14 @@
 package searcher
 
 import (
+	"context"
 	"errors"
 	"io/fs"
 	"reflect"
 	"sort"
 	"testing"
 	"testing/fstest"
+	"time"
 )
 
 func TestSearcher_Search(t *testing.T) {
@@ -104,3 +106,301 @@ func errorsEqualSlice(a, b []error) bool {
 	}
 	return true
 }
+
+func TestSearcher_Find(t *testing.T) {
+	now := time.Now()
+
+	fsys := fstest.MapFS{
+		"b.txt":     {Data: []byte("apple pear"), ModTime: now.Add(-time.Hour)},
+		"a.txt":     {Data: []byte("apple apple apple"), ModTime: now.Add(-2 * time.Hour)},
+		"d/c.txt":   {Data: []byte("apple, pear; apple!"), ModTime: now},
+		"other.txt": {Data: []byte("plum"), ModTime: now},
+	}
+
+	tests := []struct {
+		name      string
+		query     Query
+		want      []string
+		wantTotal int
+		wantErr   error
+	}{
+		{
+			name:  "By path",
+			query: Query{Words: []string{"apple"}},
+			want:  []string{"a.txt", "b.txt", "d/c.txt"},
+		},
+		{
+			name:  "By mtime",
+			query: Query{Words: []string{"apple"}, Sort: SortModified},
+			want:  []string{"d/c.txt", "b.txt", "a.txt"},
+		},
+		{
+			name:  "By score",
+			query: Query{Words: []string{"apple"}, Sort: SortScore},
+			want:  []string{"a.txt", "d/c.txt", "b.txt"},
+		},
+		{
+			name:  "All words",
+			query: Query{Words: []string{"apple pear"}},
+			want:  []string{"b.txt", "d/c.txt"},
+		},
+		{
+			name:  "Paged",
+			query: Query{Words: []string{"apple"}, Limit: 1},
+			want:  []string{"a.txt", "b.txt", "d/c.txt"},
+		},
+		{
+			name:      "Offset",
+			query:     Query{Words: []string{"apple"}, Offset: 2},
+			want:      []string{"d/c.txt"},
+			wantTotal: 3,
+		},
+		{
+			name:  "Not found",
+			query: Query{Words: []string{"cherry"}},
+			want:  nil,
+		},
+		{
+			name:  "Path prefix",
+			query: Query{Words: []string{"apple"}, Filter: Filter{PathPrefix: "d"}},
+			want:  []string{"d/c.txt"},
+		},
+		{
+			name:  "Subtrees",
+			query: Query{Words: []string{"apple"}, Filter: Filter{Subtrees: []string{"d/", "b.txt"}}},
+			want:  []string{"b.txt", "d/c.txt"},
+		},
+		{
+			name:  "Subtrees: root",
+			query: Query{Words: []string{"apple"}, Filter: Filter{Subtrees: []string{"."}}},
+			want:  []string{"a.txt", "b.txt", "d/c.txt"},
+		},
+		{
+			name:  "Subtrees: none",
+			query: Query{Words: []string{"apple"}, Filter: Filter{Subtrees: []string{}}},
+			want:  nil,
+		},
+		{
+			name:  "Ext",
+			query: Query{Words: []string{"apple"}, Filter: Filter{Ext: []string{"md", ".TXT"}}},
+			want:  []string{"a.txt", "b.txt", "d/c.txt"},
+		},
+		{
+			name:  "Modified",
+			query: Query{Words: []string{"apple"}, Filter: Filter{ModifiedAfter: now.Add(-90 * time.Minute), ModifiedBefore: now}},
+			want:  []string{"b.txt"},
+		},
+		{
+			name:  "Size",
+			query: Query{Words: []string{"apple"}, Filter: Filter{MinSize: 11, MaxSize: 17}},
+			want:  []string{"a.txt"},
+		},
+		{
+			name:    "E: filter",
+			query:   Query{Words: []string{"apple"}, Filter: Filter{MinSize: 10, MaxSize: 1}},
+			wantErr: ErrBadFilter,
+		},
+		{
+			name:    "E: sort",
+			query:   Query{Words: []string{"apple"}, Sort: "size"},
+			wantErr: ErrBadSort,
+		},
+		{
+			name:    "E: empty",
+			query:   Query{Words: []string{" ,"}},
+			wantErr: ErrEmptyQuery,
+		},
+	}
+
+	s := &Searcher{fs: fsys}
+	s.Scan()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			query := tt.query
+
+			for {
+				res, e := s.Find(query)
+				if !errors.Is(e, tt.wantErr) {
+					t.Fatalf("Find() error = %v, wantErr %v", e, tt.wantErr)
+				}
+				if e != nil {
+					return
+				}
+
+				wantTotal := tt.wantTotal
+				if wantTotal == 0 {
+					wantTotal = len(tt.want)
+				}
+
+				if res.Total != wantTotal {
+					t.Errorf("Find() total = %d, want %d", res.Total, wantTotal)
+				}
+
+				for _, hit := range res.Hits {
+					got = append(got, hit.Path)
+				}
+
+				if res.NextCursor == "" {
+					break
+				}
+				query.Cursor = res.NextCursor
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("E: cursor of another query", func(t *testing.T) {
+		first := Query{Words: []string{"apple"}, Limit: 1}
+
+		res, e := s.Find(first)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		for _, q := range []Query{
+			{Words: []string{"pear"}},
+			{Words: []string{"apple"}, Filter: Filter{PathPrefix: "d"}},
+			{Words: []string{"apple"}, Filter: Filter{Subtrees: []string{"d/"}}},
+			{Words: []string{"apple"}, NameBoost: 3},
+		} {
+			q.Limit, q.Cursor = 1, res.NextCursor
+
+			if _, e := s.Find(q); !errors.Is(e, ErrBadCursor) {
+				t.Errorf("Find(%+v) error = %v, want %v", q, e, ErrBadCursor)
+			}
+		}
+
+		// The words are normalized, the same query goes on
+		if _, e := s.Find(Query{Words: []string{"apple!"}, Limit: 1, Cursor: res.NextCursor}); e != nil {
+			t.Errorf("Find() error = %v", e)
+		}
+	})
+
+	t.Run("E: stale cursor", func(t *testing.T) {
+		res, _ := s.Find(Query{Words: []string{"apple"}, Limit: 1})
+
+		s.Scan()
+
+		_, e := s.Find(Query{Words: []string{"apple"}, Limit: 1, Cursor: res.NextCursor})
+		if !errors.Is(e, ErrStaleCursor) {
+			t.Errorf("Find() error = %v, want %v", e, ErrStaleCursor)
+		}
+	})
+}
+
+func TestSearcher_Stream(t *testing.T) {
+	now := time.Now()
+
+	fsys := fstest.MapFS{
+		"b.txt":   {Data: []byte("apple pear"), ModTime: now.Add(-time.Hour)},
+		"a.txt":   {Data: []byte("apple apple apple"), ModTime: now.Add(-2 * time.Hour)},
+		"d/c.txt": {Data: []byte("apple, pear; apple!"), ModTime: now},
+		"e.txt":   {Data: []byte("apple pear plum"), ModTime: now.Add(-3 * time.Hour)},
+	}
+
+	s := &Searcher{fs: fsys, cache: newResultCache(DefaultCacheSize)}
+	s.Scan()
+
+	tests := []struct {
+		name  string
+		query Query
+	}{
+		{name: "By path", query: Query{Words: []string{"apple"}}},
+		{name: "By mtime", query: Query{Words: []string{"apple"}, Sort: SortModified}},
+		{name: "By score", query: Query{Words: []string{"apple"}, Sort: SortScore}},
+		{name: "Page", query: Query{Words: []string{"apple"}, Offset: 1, Limit: 2}},
+		{name: "Words", query: Query{Words: []string{"pear apple"}, Filter: Filter{Ext: []string{"txt"}}}},
+		{name: "None", query: Query{Words: []string{"apple plum kiwi"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.cache = newResultCache(DefaultCacheSize)
+			s.cache.invalidate(s.generation)
+
+			var got []string
+			res, e := s.Stream(context.Background(), tt.query, func(hit Hit) error {
+				got = append(got, hit.Path)
+				return nil
+			})
+			if e != nil {
+				t.Fatal(e)
+			}
+
+			q := tt.query
+			if q.Limit == 0 {
+				q.Limit = MaxLimit
+			}
+
+			want, e := s.Find(q)
+			if e != nil {
+				t.Fatal(e)
+			}
+
+			var wantPaths []string
+			for _, hit := range want.Hits {
+				wantPaths = append(wantPaths, hit.Path)
+			}
+
+			if !reflect.DeepEqual(got, wantPaths) || res.Total != want.Total {
+				t.Errorf("Stream() = %v of %d, want %v of %d", got, res.Total, wantPaths, want.Total)
+			}
+
+			// The hits of the stream are cached
+			if st := s.CacheStats(); st.Hits != 1 {
+				t.Errorf("cache hits = %d, want 1", st.Hits)
+			}
+		})
+	}
+
+	t.Run("First hit before the matching ends", func(t *testing.T) {
+		s.cache = nil
+
+		m, e := s.execute(Query{Words: []string{"apple"}}, true)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		if m.pending == nil {
+			t.Fatal("execute() matched the files of the stream up front")
+		}
+
+		var first []string
+		res, e := m.stream(context.Background(), 0, func(hit Hit) error {
+			if first == nil {
+				first = []string{hit.Path}
+				if m.checked >= len(m.candidates) {
+					t.Errorf("first hit after %d of %d files", m.checked, len(m.candidates))
+				}
+			}
+			return nil
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		if !reflect.DeepEqual(first, []string{"a.txt"}) || res.Total != 4 {
+			t.Errorf("stream() first = %v, total %d", first, res.Total)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		n := 0
+		_, e := s.Stream(ctx, Query{Words: []string{"apple"}}, func(hit Hit) error {
+			n++
+			cancel()
+			return nil
+		})
+
+		if !errors.Is(e, context.Canceled) || n != 1 {
+			t.Errorf("Stream() = %d hits, %v, want 1 and %v", n, e, context.Canceled)
+		}
+	})
+}
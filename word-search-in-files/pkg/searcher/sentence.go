@@ -0,0 +1,207 @@
+package searcher
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Abbreviations (lower case, without the final dot) that do not end a sentence
+var abbreviations = map[string]struct{}{
+	// Russian
+	"т": {}, "д": {}, "п": {}, "г": {}, "гг": {}, "др": {}, "пр": {}, "см": {}, "ср": {},
+	"им": {}, "ул": {}, "стр": {}, "рис": {}, "тыс": {}, "млн": {}, "млрд": {}, "руб": {},
+	"коп": {}, "обл": {}, "р": {}, "н": {}, "э": {}, "напр": {}, "проф": {}, "акад": {},
+	"т.е": {}, "т.д": {}, "т.п": {}, "т.к": {}, "т.н": {}, "н.э": {},
+	// English
+	"mr": {}, "mrs": {}, "ms": {}, "dr": {}, "prof": {}, "sr": {}, "jr": {}, "st": {},
+	"vs": {}, "e.g": {}, "i.e": {}, "cf": {}, "no": {}, "fig": {}, "approx": {}, "inc": {},
+	"ltd": {}, "co": {}, "jan": {}, "feb": {}, "mar": {}, "apr": {}, "jun": {}, "jul": {},
+	"aug": {}, "sep": {}, "sept": {}, "oct": {}, "nov": {}, "dec": {},
+}
+
+// span is the byte range [start, end) of a segment of a file
+type span struct {
+	start, end int
+}
+
+// splitLines returns the lines of the text without the line breaks
+func splitLines(text []byte) []span {
+	var res []span
+
+	for start := 0; start < len(text); {
+		end := bytes.IndexByte(text[start:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += start
+		}
+
+		line := span{start: start, end: end}
+		if line.end > line.start && text[line.end-1] == '\r' {
+			line.end--
+		}
+
+		res = append(res, line)
+		start = end + 1
+	}
+
+	return res
+}
+
+// splitSentences returns the sentences of Russian or English text. A sentence ends
+// with '.', '!', '?' or '…' (and the closing quotes and brackets after it) followed
+// by a capital letter, a digit, an opening quote or a dash, or at a blank line.
+// Abbreviations and initials do not end a sentence. Line breaks inside a sentence
+// are kept, the leading and trailing spaces are not
+func splitSentences(text []byte) []span {
+	var res []span
+
+	start := -1
+
+	add := func(end int) {
+		if start < 0 {
+			return
+		}
+
+		for end > start {
+			r, size := utf8.DecodeLastRune(text[start:end])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			end -= size
+		}
+
+		if end > start {
+			res = append(res, span{start: start, end: end})
+		}
+		start = -1
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+
+		if start < 0 {
+			if !unicode.IsSpace(r) {
+				start = i
+			}
+			i += size
+			continue
+		}
+
+		// A blank line ends the paragraph and the sentence
+		if r == '\n' && blankLineAt(text, i+size) {
+			add(i)
+			i += size
+			continue
+		}
+
+		if !isTerminator(r) {
+			i += size
+			continue
+		}
+
+		end := i + size
+		for end < len(text) {
+			r, size := utf8.DecodeRune(text[end:])
+			if !isTerminator(r) && !isClosing(r) {
+				break
+			}
+			end += size
+		}
+
+		if end == len(text) {
+			break
+		}
+
+		if r == '.' && end == i+size && isAbbreviation(text[start:i]) {
+			i = end
+			continue
+		}
+
+		next, ok := nextAfterSpace(text[end:])
+		if ok && startsSentence(next) {
+			add(end)
+		}
+
+		i = end
+	}
+
+	add(len(text))
+
+	return res
+}
+
+func isTerminator(r rune) bool {
+	return r == '.' || r == '!' || r == '?' || r == '…'
+}
+
+func isClosing(r rune) bool {
+	return r == '"' || r == '\'' || r == '»' || r == '”' || r == '’' || r == ')' || r == ']'
+}
+
+func startsSentence(r rune) bool {
+	return unicode.IsUpper(r) || unicode.IsDigit(r) ||
+		r == '«' || r == '"' || r == '“' || r == '„' || r == '(' || r == '-' || r == '—' || r == '–'
+}
+
+// nextAfterSpace returns the first rune after the leading spaces, the text must
+// start with a space for the rune to be a start of the next sentence
+func nextAfterSpace(text []byte) (rune, bool) {
+	r, size := utf8.DecodeRune(text)
+	if !unicode.IsSpace(r) {
+		return 0, false
+	}
+
+	for i := size; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+		if !unicode.IsSpace(r) {
+			return r, true
+		}
+		i += size
+	}
+
+	return 0, false
+}
+
+func blankLineAt(text []byte, i int) bool {
+	for i < len(text) {
+		r, size := utf8.DecodeRune(text[i:])
+		if r == '\n' {
+			return true
+		}
+		if !unicode.IsSpace(r) {
+			return false
+		}
+		i += size
+	}
+
+	return false
+}
+
+// isAbbreviation reports whether the last word of the text before a dot is an
+// abbreviation or an initial
+func isAbbreviation(text []byte) bool {
+	i := len(text)
+	for i > 0 {
+		r, size := utf8.DecodeLastRune(text[:i])
+		if !unicode.IsLetter(r) && r != '.' {
+			break
+		}
+		i -= size
+	}
+
+	word := string(text[i:])
+	if word == "" {
+		return false
+	}
+
+	// An initial: "А. С. Пушкин", "J. R. R. Tolkien"
+	if r, size := utf8.DecodeRuneInString(word); size == len(word) && unicode.IsUpper(r) {
+		return true
+	}
+
+	_, ok := abbreviations[strings.ToLower(word)]
+	return ok
+}
@@ -0,0 +1,113 @@
+package searcher
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "Russian",
+			text: "Хороши летние туманные дни. В такие дни нельзя стрелять! Кругом тихо…  Всё молчит",
+			want: []string{"Хороши летние туманные дни.", "В такие дни нельзя стрелять!", "Кругом тихо…", "Всё молчит"},
+		},
+		{
+			name: "Line breaks",
+			text: "Ждите меня десять дней, а коли\nна десятый день не вернусь. Но ежели\nя вернусь.",
+			want: []string{"Ждите меня десять дней, а коли\nна десятый день не вернусь.", "Но ежели\nя вернусь."},
+		},
+		{
+			name: "Abbreviations",
+			text: "Писал А. С. Пушкин и т.д. и т. п. в 1830 г. в Болдине. Потом Mr. Smith met Dr. Watson, e.g. at noon. The end.",
+			want: []string{"Писал А. С. Пушкин и т.д. и т. п. в 1830 г. в Болдине.", "Потом Mr. Smith met Dr. Watson, e.g. at noon.", "The end."},
+		},
+		{
+			name: "Quotes and dialogue",
+			text: "«Дети!» - молвил он. - Я иду в горы. \"Really?\" He left.",
+			want: []string{"«Дети!»", "- молвил он.", "- Я иду в горы.", "\"Really?\"", "He left."},
+		},
+		{
+			name: "Lower case",
+			text: "version 1.2 is out. and then",
+			want: []string{"version 1.2 is out. and then"},
+		},
+		{
+			name: "Paragraph",
+			text: "Title\n\nFirst line",
+			want: []string{"Title", "First line"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, sp := range splitSentences([]byte(tt.text)) {
+				got = append(got, tt.text[sp.start:sp.end])
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitSentences() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearcher_FindGranularity(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("Hello there.\nWorld is big. Hello World!")},
+		"b.txt": {Data: []byte("Hello.\nWorld.")},
+	}
+
+	tests := []struct {
+		name        string
+		granularity Granularity
+		want        map[string][]string
+	}{
+		{
+			name:        "File",
+			granularity: GranularityFile,
+			want:        map[string][]string{"a.txt": nil, "b.txt": nil},
+		},
+		{
+			name:        "Line",
+			granularity: GranularityLine,
+			want:        map[string][]string{"a.txt": {"World is big. Hello World!"}},
+		},
+		{
+			name:        "Sentence",
+			granularity: GranularitySentence,
+			want:        map[string][]string{"a.txt": {"Hello World!"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Searcher{fs: fsys, granularity: tt.granularity}
+			s.Scan()
+
+			res, e := s.Find(Query{Words: []string{"Hello World"}})
+			if e != nil {
+				t.Fatal(e)
+			}
+
+			got := make(map[string][]string)
+			for _, hit := range res.Hits {
+				var texts []string
+				for _, sg := range hit.Segments {
+					texts = append(texts, sg.Text)
+				}
+				got[hit.Path] = texts
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
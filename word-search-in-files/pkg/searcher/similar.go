@@ -0,0 +1,144 @@
+package searcher
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DefaultSimilarK is the number of the similar files when the query does not set one
+const DefaultSimilarK = 10
+
+var ErrUnknownFile = errors.New("no such file in the index")
+
+// SimilarQuery selects the files most similar to one of the index
+type SimilarQuery struct {
+	// The file to compare the others to, relative to the root of the index
+	Path string
+	// Number of the files, 0 means DefaultSimilarK, capped by MaxLimit
+	K int
+	// Only the files accepted by the filter are returned, the file of Path need not be
+	Filter Filter
+}
+
+type SimilarHit struct {
+	Path string
+	// Cosine similarity of the tf-idf vectors of the files, above 0 and at most 1
+	Score float64
+}
+
+type SimilarResult struct {
+	// The most similar first, ties by path
+	Hits []SimilarHit
+	// Snapshot of the index the scores were computed against
+	Generation uint64
+}
+
+// Similar returns the files closest to the one of the path by the cosine similarity
+// of their tf-idf vectors. The vectors are made of the terms of the index, so the
+// words go through the same analyzers as at indexing. Only the postings of the terms
+// of the file are walked, the files sharing no term are left out
+func (s *Searcher) Similar(q SimilarQuery) (*SimilarResult, error) {
+	if q.K < 0 {
+		return nil, fmt.Errorf("%w: k %d", ErrBadLimit, q.K)
+	}
+
+	k := q.K
+	if k == 0 {
+		k = DefaultSimilarK
+	}
+	k = min(k, MaxLimit)
+
+	accept, e := q.Filter.compile()
+	if e != nil {
+		return nil, e
+	}
+
+	s.muGlobal.RLock()
+	defer s.muGlobal.RUnlock()
+
+	target := strings.TrimPrefix(q.Path, "./")
+
+	// The files out of the subtrees of the caller do not exist for it
+	index := -1
+	for i, f := range s.Files {
+		if f.Path == target && (q.Filter.Subtrees == nil || InSubtrees(f.Path, q.Filter.Subtrees)) {
+			index = i
+			break
+		}
+	}
+
+	if index < 0 {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFile, q.Path)
+	}
+
+	n := len(s.Files)
+	dots := make(map[int]float64)
+
+	for _, term := range s.fileTerms[index] {
+		postings := s.Words[term]
+
+		w := tfidf(postings[index], len(postings), n)
+		for other, otherTF := range postings {
+			if other != index {
+				dots[other] += w * tfidf(otherTF, len(postings), n)
+			}
+		}
+	}
+
+	hits := make([]SimilarHit, 0, len(dots))
+	for other, dot := range dots {
+		if dot <= 0 || !accept(s.Files[other]) {
+			continue
+		}
+
+		hits = append(hits, SimilarHit{
+			Path:  s.Files[other].Path,
+			Score: min(dot/(s.norms[index]*s.norms[other]), 1),
+		})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Path < hits[j].Path
+	})
+
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+
+	return &SimilarResult{Hits: hits, Generation: s.generation}, nil
+}
+
+// tfidf weighs the occurrences of a term in a file by the rarity of the term among
+// the n files, df of them containing it
+func tfidf(tf, df, n int) float64 {
+	return float64(tf) * math.Log(1+float64(n)/float64(df))
+}
+
+// tfidfVectors returns the lengths of the tf-idf vectors of the files and the terms
+// of every file, sorted as the dictionary. The caller must hold muGlobal
+func (s *Searcher) tfidfVectors() ([]float64, [][]string) {
+	norms := make([]float64, len(s.Files))
+	terms := make([][]string, len(s.Files))
+
+	for _, term := range s.dict {
+		postings := s.Words[term]
+
+		for index, tf := range postings {
+			w := tfidf(tf, len(postings), len(s.Files))
+			norms[index] += w * w
+			terms[index] = append(terms[index], term)
+		}
+	}
+
+	for i := range norms {
+		norms[i] = math.Sqrt(norms[i])
+	}
+
+	return norms, terms
+}
@@ -0,0 +1,117 @@
+package searcher
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSearcher_Similar(t *testing.T) {
+	fsys := fstest.MapFS{
+		"go.txt":      {Data: []byte("goroutines and channels make concurrency in go simple")},
+		"go2.txt":     {Data: []byte("channels connect goroutines, concurrency in go")},
+		"rust.md":     {Data: []byte("ownership makes concurrency in rust safe")},
+		"cooking.txt": {Data: []byte("bake the bread for forty minutes")},
+		"empty.txt":   {Data: []byte("")},
+	}
+
+	s := &Searcher{fs: fsys}
+	s.Scan()
+
+	tests := []struct {
+		name    string
+		query   SimilarQuery
+		want    []string
+		wantErr error
+	}{
+		{name: "Ranked", query: SimilarQuery{Path: "go.txt"}, want: []string{"go2.txt", "rust.md"}},
+		{name: "K", query: SimilarQuery{Path: "./go.txt", K: 1}, want: []string{"go2.txt"}},
+		{name: "Filter", query: SimilarQuery{Path: "go.txt", Filter: Filter{Ext: []string{"md"}}}, want: []string{"rust.md"}},
+		{name: "Nothing shared", query: SimilarQuery{Path: "cooking.txt"}, want: nil},
+		{name: "Empty file", query: SimilarQuery{Path: "empty.txt"}, want: nil},
+		{name: "E: unknown", query: SimilarQuery{Path: "nope.txt"}, wantErr: ErrUnknownFile},
+		{name: "E: out of the subtrees", query: SimilarQuery{Path: "go.txt", Filter: Filter{Subtrees: []string{"docs"}}}, wantErr: ErrUnknownFile},
+		{name: "E: k", query: SimilarQuery{Path: "go.txt", K: -1}, wantErr: ErrBadLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, e := s.Similar(tt.query)
+			if !errors.Is(e, tt.wantErr) {
+				t.Fatalf("Similar() error = %v, wantErr %v", e, tt.wantErr)
+			}
+			if e != nil {
+				return
+			}
+
+			var got []string
+			for i, h := range res.Hits {
+				got = append(got, h.Path)
+
+				if h.Score <= 0 || h.Score > 1 || i > 0 && h.Score > res.Hits[i-1].Score {
+					t.Errorf("hits = %+v", res.Hits)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Similar() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A copy is as similar as can be
+	fsys["copy.txt"] = fsys["go.txt"]
+	s.Scan()
+
+	res, _ := s.Similar(SimilarQuery{Path: "go.txt", K: 1})
+	if len(res.Hits) != 1 || res.Hits[0].Path != "copy.txt" || res.Hits[0].Score < 0.999 {
+		t.Errorf("Similar() = %+v, want copy.txt with 1", res.Hits)
+	}
+}
+
+func TestSearcher_SimilarAnalyzers(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("The runners were running through the parks of the city")},
+		"b.txt": {Data: []byte("A runner runs in a park")},
+		"c.txt": {Data: []byte("Quarterly revenue grew again")},
+	}
+
+	for _, analyzers := range []bool{false, true} {
+		s := &Searcher{fs: fsys, analyzers: analyzers}
+		s.Scan()
+
+		res, e := s.Similar(SimilarQuery{Path: "a.txt"})
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		// Only the stems are shared
+		if found := len(res.Hits) == 1 && res.Hits[0].Path == "b.txt"; found != analyzers {
+			t.Errorf("analyzers %v: Similar() = %+v", analyzers, res.Hits)
+		}
+	}
+}
+
+func TestSearcher_tfidfVectors(t *testing.T) {
+	s := &Searcher{fs: fstest.MapFS{
+		"a.txt": {Data: []byte("pear apple pear")},
+		"b.txt": {Data: []byte("plum apple")},
+		"c.txt": {Data: []byte("")},
+	}}
+	s.Scan()
+
+	got := make(map[string][]string)
+	for i, f := range s.Files {
+		got[f.Path] = s.fileTerms[i]
+	}
+
+	want := map[string][]string{
+		"a.txt": {"apple", "pear"},
+		"b.txt": {"apple", "plum"},
+		"c.txt": nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fileTerms = %v, want %v", got, want)
+	}
+}
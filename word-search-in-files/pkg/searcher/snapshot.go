@@ -0,0 +1,482 @@
+package searcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// The snapshot is a JSON Lines stream: one JSON object per line, the "type" field
+// tells what the line holds. The lines come in this order:
+//
+//	{"type":"header","format":"word-search-snapshot","version":1,"generation":3,
+//	 "created":"2024-01-02T15:04:05Z","granularity":"file","stop_words":["и"],
+//	 "analyzers":true,"files":2,"terms":3,"errors":0}
+//	{"type":"file","id":0,"path":"a.txt","modified":"2024-01-02T15:04:05.999Z","size":10,"tokens":2,"lang":"en"}
+//	{"type":"term","term":"apple","postings":[[0,2],[1,1]]}
+//	{"type":"error","op":"open","path":"b.txt","message":"permission denied"}
+//
+// header: the first line, the options of the index and the numbers of the lines that
+// follow. With "analyzers" the terms are the ones of the analyzers of the languages
+// of the files.
+//
+// file: a file of the index, sorted by path. The id is its position, from 0. Besides
+// the ones above it may have "spans", the [start, end) byte offsets of its lines or
+// sentences with a "line" or "sentence" granularity, the "limits" of the scan that
+// fired on it, the "content_version" a later scan compares to read only the changed
+// files, and the "minhash" (base64 of the little-endian values) and "simhash" (hex)
+// signatures.
+//
+// term: a term of the index, sorted. A posting is [file id, occurrences], with
+// [segments] of the spans as a third element with a "line" or "sentence" granularity.
+//
+// error: an error of the scan.
+//
+// Readers must ignore unknown fields and line types of the same version
+const (
+	SnapshotFormat  = "word-search-snapshot"
+	SnapshotVersion = 1
+)
+
+var ErrBadSnapshot = errors.New("malformed snapshot")
+
+// Snapshot is a decoded index
+type Snapshot struct {
+	Generation  uint64
+	Created     time.Time
+	Granularity Granularity
+	StopWords   []string
+	// The terms are made by the analyzers of the languages
+	Analyzers bool
+
+	Files  []FileInfo
+	Errors []error
+	// Term -> file id -> occurrences
+	Words map[string]map[int]int
+
+	segments map[string]map[int]map[int]struct{}
+	spans    [][]span
+}
+
+type snapshotLine struct {
+	Type string `json:"type"`
+
+	// header
+	Format      string     `json:"format,omitempty"`
+	Version     int        `json:"version,omitempty"`
+	Generation  uint64     `json:"generation,omitempty"`
+	Created     *time.Time `json:"created,omitempty"`
+	Granularity string     `json:"granularity,omitempty"`
+	StopWords   []string   `json:"stop_words,omitempty"`
+	Analyzers   bool       `json:"analyzers,omitempty"`
+	FilesN      int        `json:"files,omitempty"`
+	TermsN      int        `json:"terms,omitempty"`
+	ErrorsN     int        `json:"errors,omitempty"`
+
+	// file
+	ID             *int       `json:"id,omitempty"`
+	Path           string     `json:"path,omitempty"`
+	Modified       *time.Time `json:"modified,omitempty"`
+	Size           int64      `json:"size,omitempty"`
+	Tokens         int        `json:"tokens,omitempty"`
+	Spans          [][2]int   `json:"spans,omitempty"`
+	ContentVersion string     `json:"content_version,omitempty"`
+	Limits         []string   `json:"limits,omitempty"`
+	Lang           string     `json:"lang,omitempty"`
+	MinHash        string     `json:"minhash,omitempty"`
+	SimHash        string     `json:"simhash,omitempty"`
+
+	// term
+	Term     string            `json:"term,omitempty"`
+	Postings []json.RawMessage `json:"postings,omitempty"`
+
+	// error
+	Op      string `json:"op,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Export writes the current generation of the index as a snapshot
+func (s *Searcher) Export(w io.Writer) error {
+	s.muGlobal.RLock()
+	defer s.muGlobal.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	stopWords := make([]string, 0, len(s.stopWords))
+	for word := range s.stopWords {
+		stopWords = append(stopWords, word)
+	}
+	sort.Strings(stopWords)
+
+	created := time.Now().UTC()
+
+	e := enc.Encode(snapshotLine{
+		Type:        "header",
+		Format:      SnapshotFormat,
+		Version:     SnapshotVersion,
+		Generation:  s.generation,
+		Created:     &created,
+		Granularity: s.granularity.String(),
+		StopWords:   stopWords,
+		Analyzers:   s.analyzers,
+		FilesN:      len(s.Files),
+		TermsN:      len(s.Words),
+		ErrorsN:     len(s.Errors),
+	})
+	if e != nil {
+		return e
+	}
+
+	// Files by path, so the snapshots of the same tree are comparable line by line
+	order := make([]int, len(s.Files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return s.Files[order[i]].Path < s.Files[order[j]].Path })
+
+	ids := make([]int, len(s.Files))
+	for id, index := range order {
+		ids[index] = id
+	}
+
+	for id, index := range order {
+		f := s.Files[index]
+		modified := f.Modified.UTC()
+		line := snapshotLine{Type: "file", ID: &id, Path: f.Path, Modified: &modified, Size: f.Size, Tokens: f.Tokens, ContentVersion: f.Version, Lang: f.Lang}
+
+		for _, l := range f.Limits {
+			line.Limits = append(line.Limits, string(l))
+		}
+
+		if f.Signature != nil {
+			line.MinHash, line.SimHash = f.Signature.encode()
+		}
+
+		if index < len(s.spans) {
+			line.Spans = make([][2]int, len(s.spans[index]))
+			for i, sp := range s.spans[index] {
+				line.Spans[i] = [2]int{sp.start, sp.end}
+			}
+		}
+
+		if e := enc.Encode(line); e != nil {
+			return e
+		}
+	}
+
+	terms := s.dict
+	if len(terms) != len(s.Words) {
+		terms = sortedWords(s.Words)
+	}
+
+	for _, term := range terms {
+		line := snapshotLine{Type: "term", Term: term}
+
+		postings := s.Words[term]
+		fileIDs := make([]int, 0, len(postings))
+		for index := range postings {
+			fileIDs = append(fileIDs, ids[index])
+		}
+		sort.Ints(fileIDs)
+
+		for _, id := range fileIDs {
+			index := order[id]
+			posting := []any{id, postings[index]}
+
+			if s.granularity != GranularityFile {
+				segments := make([]int, 0, len(s.segments[term][index]))
+				for sg := range s.segments[term][index] {
+					segments = append(segments, sg)
+				}
+				sort.Ints(segments)
+				posting = append(posting, segments)
+			}
+
+			raw, e := json.Marshal(posting)
+			if e != nil {
+				return e
+			}
+			line.Postings = append(line.Postings, raw)
+		}
+
+		if e := enc.Encode(line); e != nil {
+			return e
+		}
+	}
+
+	for _, err := range s.Errors {
+		line := snapshotLine{Type: "error", Message: err.Error()}
+
+		var pe *fs.PathError
+		if errors.As(err, &pe) {
+			line.Op, line.Path, line.Message = pe.Op, pe.Path, pe.Err.Error()
+		}
+
+		if e := enc.Encode(line); e != nil {
+			return e
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadSnapshot decodes a snapshot written by Export
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	var header snapshotLine
+	if e := dec.Decode(&header); e != nil {
+		return nil, fmt.Errorf("%w: reading header: %v", ErrBadSnapshot, e)
+	}
+
+	if header.Type != "header" || header.Format != SnapshotFormat {
+		return nil, fmt.Errorf("%w: not a %s", ErrBadSnapshot, SnapshotFormat)
+	}
+
+	if header.Version != SnapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrBadSnapshot, header.Version)
+	}
+
+	granularity, e := ParseGranularity(header.Granularity)
+	if e != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadSnapshot, e)
+	}
+
+	snap := &Snapshot{
+		Generation:  header.Generation,
+		Granularity: granularity,
+		StopWords:   header.StopWords,
+		Analyzers:   header.Analyzers,
+		Files:       make([]FileInfo, 0, header.FilesN),
+		Words:       make(map[string]map[int]int, header.TermsN),
+		segments:    make(map[string]map[int]map[int]struct{}),
+	}
+
+	if header.Created != nil {
+		snap.Created = *header.Created
+	}
+
+	for n := 2; ; n++ {
+		var line snapshotLine
+
+		e := dec.Decode(&line)
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrBadSnapshot, n, e)
+		}
+
+		switch line.Type {
+		case "file":
+			if line.ID == nil || *line.ID != len(snap.Files) {
+				return nil, fmt.Errorf("%w: line %d: file id out of order", ErrBadSnapshot, n)
+			}
+
+			f := FileInfo{Path: line.Path, Size: line.Size, Tokens: line.Tokens, Version: line.ContentVersion, Lang: line.Lang}
+			for _, l := range line.Limits {
+				f.Limits = append(f.Limits, Limit(l))
+			}
+			if line.Modified != nil {
+				f.Modified = *line.Modified
+			}
+			if line.MinHash != "" || line.SimHash != "" {
+				if f.Signature, e = decodeSignature(line.MinHash, line.SimHash); e != nil {
+					return nil, fmt.Errorf("%w: line %d: %v", ErrBadSnapshot, n, e)
+				}
+			}
+			snap.Files = append(snap.Files, f)
+
+			if granularity != GranularityFile {
+				spans := make([]span, len(line.Spans))
+				for i, sp := range line.Spans {
+					spans[i] = span{start: sp[0], end: sp[1]}
+				}
+				snap.spans = append(snap.spans, spans)
+			}
+
+		case "term":
+			if e := snap.addPostings(line); e != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrBadSnapshot, n, e)
+			}
+
+		case "error":
+			if line.Path != "" || line.Op != "" {
+				snap.Errors = append(snap.Errors, &fs.PathError{Op: line.Op, Path: line.Path, Err: errors.New(line.Message)})
+			} else {
+				snap.Errors = append(snap.Errors, errors.New(line.Message))
+			}
+		}
+	}
+
+	if len(snap.Files) != header.FilesN || len(snap.Words) != header.TermsN {
+		return nil, fmt.Errorf("%w: truncated, %d of %d files and %d of %d terms", ErrBadSnapshot,
+			len(snap.Files), header.FilesN, len(snap.Words), header.TermsN)
+	}
+
+	return snap, nil
+}
+
+func (snap *Snapshot) addPostings(line snapshotLine) error {
+	if line.Term == "" {
+		return errors.New("empty term")
+	}
+
+	postings := make(map[int]int, len(line.Postings))
+
+	for _, raw := range line.Postings {
+		var posting []json.RawMessage
+		if e := json.Unmarshal(raw, &posting); e != nil || len(posting) < 2 {
+			return fmt.Errorf("posting of %q", line.Term)
+		}
+
+		var id, count int
+		if json.Unmarshal(posting[0], &id) != nil || json.Unmarshal(posting[1], &count) != nil {
+			return fmt.Errorf("posting of %q", line.Term)
+		}
+
+		if id < 0 || id >= len(snap.Files) {
+			return fmt.Errorf("posting of %q refers to unknown file %d", line.Term, id)
+		}
+
+		postings[id] = count
+
+		if snap.Granularity != GranularityFile && len(posting) > 2 {
+			var segments []int
+			if e := json.Unmarshal(posting[2], &segments); e != nil {
+				return fmt.Errorf("segments of %q", line.Term)
+			}
+
+			for _, sg := range segments {
+				addSegmentToMap(snap.segments, line.Term, id, sg)
+			}
+		}
+	}
+
+	snap.Words[line.Term] = postings
+
+	return nil
+}
+
+// Import replaces the index with the snapshot and publishes it as a new generation.
+// The granularity, the stop words and the analyzers of the snapshot replace the ones of
+// the Searcher, so the queries are normalized the way the snapshot was indexed
+func (s *Searcher) Import(r io.Reader) error {
+	snap, e := ReadSnapshot(r)
+	if e != nil {
+		return e
+	}
+
+	s.Load(snap)
+
+	return nil
+}
+
+// Load replaces the index with the decoded snapshot, see Import
+func (s *Searcher) Load(snap *Snapshot) {
+	s.muGlobal.Lock()
+	defer s.muGlobal.Unlock()
+
+	s.granularity = snap.Granularity
+
+	s.stopWords = nil
+	WithStopWords(snap.StopWords)(s)
+	s.analyzers = snap.Analyzers
+
+	s.Files = snap.Files
+	s.Errors = snap.Errors
+	s.skipped = nil
+	s.Words = snap.Words
+	s.segments = snap.segments
+	s.spans = snap.spans
+
+	// The snapshots of the older versions have no signatures
+	s.sign()
+
+	s.publish(time.Now())
+}
+
+// SnapshotDiff lists the differences between two snapshots, all sorted
+type SnapshotDiff struct {
+	AddedFiles   []string
+	RemovedFiles []string
+	// The files with another size, modification time or number of words
+	ChangedFiles []string
+
+	AddedTerms   []string
+	RemovedTerms []string
+	// The terms contained in another number of files
+	ChangedTerms []TermChange
+}
+
+type TermChange struct {
+	Term     string
+	DocsFrom int
+	DocsTo   int
+}
+
+// Empty reports whether the snapshots have the same files and terms
+func (d *SnapshotDiff) Empty() bool {
+	return len(d.AddedFiles) == 0 && len(d.RemovedFiles) == 0 && len(d.ChangedFiles) == 0 &&
+		len(d.AddedTerms) == 0 && len(d.RemovedTerms) == 0 && len(d.ChangedTerms) == 0
+}
+
+// DiffSnapshots compares the files and the terms of the snapshots
+func DiffSnapshots(from, to *Snapshot) *SnapshotDiff {
+	d := &SnapshotDiff{}
+
+	files := make(map[string]FileInfo, len(from.Files))
+	for _, f := range from.Files {
+		files[f.Path] = f
+	}
+
+	seen := make(map[string]struct{}, len(to.Files))
+	for _, f := range to.Files {
+		seen[f.Path] = struct{}{}
+
+		old, ok := files[f.Path]
+		switch {
+		case !ok:
+			d.AddedFiles = append(d.AddedFiles, f.Path)
+		case old.Size != f.Size || !old.Modified.Equal(f.Modified) || old.Tokens != f.Tokens:
+			d.ChangedFiles = append(d.ChangedFiles, f.Path)
+		}
+	}
+
+	for _, f := range from.Files {
+		if _, ok := seen[f.Path]; !ok {
+			d.RemovedFiles = append(d.RemovedFiles, f.Path)
+		}
+	}
+
+	for term, postings := range to.Words {
+		old, ok := from.Words[term]
+		switch {
+		case !ok:
+			d.AddedTerms = append(d.AddedTerms, term)
+		case len(old) != len(postings):
+			d.ChangedTerms = append(d.ChangedTerms, TermChange{Term: term, DocsFrom: len(old), DocsTo: len(postings)})
+		}
+	}
+
+	for term := range from.Words {
+		if _, ok := to.Words[term]; !ok {
+			d.RemovedTerms = append(d.RemovedTerms, term)
+		}
+	}
+
+	sort.Strings(d.AddedFiles)
+	sort.Strings(d.RemovedFiles)
+	sort.Strings(d.ChangedFiles)
+	sort.Strings(d.AddedTerms)
+	sort.Strings(d.RemovedTerms)
+	sort.Slice(d.ChangedTerms, func(i, j int) bool { return d.ChangedTerms[i].Term < d.ChangedTerms[j].Term })
+
+	return d
+}
@@ -0,0 +1,128 @@
+package searcher
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestSearcher_ExportImport(t *testing.T) {
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	s := &Searcher{
+		fs: fstest.MapFS{
+			"a.txt":     {Data: []byte("Кот спит. Пёс лает на кота."), ModTime: mtime},
+			"dir/b.txt": {Data: []byte("кот и пёс\nмышь"), ModTime: mtime},
+		},
+	}
+	WithGranularity(GranularitySentence)(s)
+	WithStopWords([]string{"и", "на"})(s)
+	WithAnalyzers()(s)
+	s.Scan()
+
+	var buf bytes.Buffer
+	if e := s.Export(&buf); e != nil {
+		t.Fatal(e)
+	}
+
+	imported, e := NewSearcher("", WithCacheSize(0))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if e := imported.Import(bytes.NewReader(buf.Bytes())); e != nil {
+		t.Fatal(e)
+	}
+
+	if !reflect.DeepEqual(imported.Words, s.Words) {
+		t.Errorf("Words = %v, want %v", imported.Words, s.Words)
+	}
+
+	if imported.granularity != GranularitySentence || !imported.isStopWord("и") || !imported.analyzers {
+		t.Errorf("granularity = %v, stop words = %v, analyzers = %v", imported.granularity, imported.stopWords, imported.analyzers)
+	}
+
+	want, _ := s.Find(Query{Words: []string{"пёс"}})
+	got, e := imported.Find(Query{Words: []string{"пёс"}})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if got.Total != want.Total || len(got.Hits) != len(want.Hits) {
+		t.Fatalf("Find() = %+v, want %+v", got, want)
+	}
+
+	for i := range got.Hits {
+		if got.Hits[i].Path != want.Hits[i].Path || !got.Hits[i].Modified.Equal(want.Hits[i].Modified) || got.Hits[i].Lang != want.Hits[i].Lang ||
+			len(got.Hits[i].Segments) != len(want.Hits[i].Segments) {
+			t.Errorf("hit %d = %+v, want %+v", i, got.Hits[i], want.Hits[i])
+		}
+	}
+
+	// The export of the import is the same snapshot
+	var again bytes.Buffer
+	if e := imported.Export(&again); e != nil {
+		t.Fatal(e)
+	}
+
+	a, _ := ReadSnapshot(bytes.NewReader(buf.Bytes()))
+	b, _ := ReadSnapshot(bytes.NewReader(again.Bytes()))
+	if d := DiffSnapshots(a, b); !d.Empty() {
+		t.Errorf("re-export differs: %+v", d)
+	}
+}
+
+func TestReadSnapshot_Malformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"no header", `{"type":"term","term":"a","postings":[[0,1]]}` + "\n"},
+		{"other format", `{"type":"header","format":"other","version":1}` + "\n"},
+		{"newer version", `{"type":"header","format":"word-search-snapshot","version":99}` + "\n"},
+		{"unknown file", `{"type":"header","format":"word-search-snapshot","version":1}` + "\n" +
+			`{"type":"term","term":"a","postings":[[3,1]]}` + "\n"},
+		{"not json", "header\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, e := ReadSnapshot(bytes.NewReader([]byte(tt.data))); e == nil {
+				t.Errorf("ReadSnapshot() error = nil")
+			}
+		})
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	from := &Snapshot{
+		Files: []FileInfo{{Path: "a.txt", Modified: mtime, Size: 3}, {Path: "b.txt", Modified: mtime, Size: 5}},
+		Words: map[string]map[int]int{"кот": {0: 1}, "пёс": {0: 1, 1: 2}, "мышь": {1: 1}},
+	}
+	to := &Snapshot{
+		Files: []FileInfo{{Path: "b.txt", Modified: mtime.Add(time.Hour), Size: 5}, {Path: "c.txt", Modified: mtime, Size: 1}},
+		Words: map[string]map[int]int{"кот": {0: 1, 1: 1}, "пёс": {0: 2, 1: 1}, "сыр": {1: 1}},
+	}
+
+	want := &SnapshotDiff{
+		AddedFiles:   []string{"c.txt"},
+		RemovedFiles: []string{"a.txt"},
+		ChangedFiles: []string{"b.txt"},
+		AddedTerms:   []string{"сыр"},
+		RemovedTerms: []string{"мышь"},
+		ChangedTerms: []TermChange{{Term: "кот", DocsFrom: 1, DocsTo: 2}},
+	}
+
+	if d := DiffSnapshots(from, to); !reflect.DeepEqual(d, want) {
+		t.Errorf("DiffSnapshots() = %+v, want %+v", d, want)
+	}
+
+	if d := DiffSnapshots(from, from); !d.Empty() {
+		t.Errorf("DiffSnapshots() of the same snapshot = %+v", d)
+	}
+}
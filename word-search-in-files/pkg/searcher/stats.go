@@ -0,0 +1,142 @@
+package searcher
+
+import (
+	"container/heap"
+	"path"
+	"sort"
+	"strings"
+)
+
+// NoExt is the key of the files without an extension in CorpusStats.Extensions
+const NoExt = "(none)"
+
+const (
+	DefaultTopTerms = 20
+	MaxTopTerms     = 1000
+)
+
+// CorpusStats describes the current generation of the index
+type CorpusStats struct {
+	Generation uint64
+	Files      int
+	// Number of distinct terms
+	Vocabulary int
+	// Number of indexed words in all the files
+	Tokens int
+	// Average number of indexed words per file
+	AvgDocLength float64
+	// The terms contained in the most files, the most frequent first
+	TopTerms []TermFreq
+	// Number of files by lower case extension without the dot
+	Extensions map[string]int
+	// Number of files by detected language, LangUnknown for the undetected ones
+	Languages map[string]int
+	StopWords int
+}
+
+// Stats returns the statistics of the index with the top terms by document frequency,
+// top 0 means DefaultTopTerms. Only the files of the subtrees are described, nil does
+// not restrict, see Filter.Subtrees
+func (s *Searcher) Stats(top int, subtrees []string) (CorpusStats, error) {
+	if top < 0 {
+		return CorpusStats{}, ErrBadLimit
+	}
+
+	if top == 0 {
+		top = DefaultTopTerms
+	}
+	top = min(top, MaxTopTerms)
+
+	s.muGlobal.RLock()
+	defer s.muGlobal.RUnlock()
+
+	visible := s.visible(subtrees)
+
+	st := CorpusStats{
+		Generation: s.generation,
+		Extensions: make(map[string]int),
+		Languages:  make(map[string]int),
+		StopWords:  len(s.stopWords),
+	}
+
+	for i, f := range s.Files {
+		if visible != nil && !visible[i] {
+			continue
+		}
+
+		st.Files++
+		st.Tokens += f.Tokens
+
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(f.Path), "."))
+		if ext == "" {
+			ext = NoExt
+		}
+		st.Extensions[ext]++
+
+		lang := f.Lang
+		if lang == "" {
+			lang = LangUnknown
+		}
+		st.Languages[lang]++
+	}
+
+	if st.Files > 0 {
+		st.AvgDocLength = float64(st.Tokens) / float64(st.Files)
+	}
+
+	st.TopTerms, st.Vocabulary = s.topTerms(top, visible)
+
+	return st, nil
+}
+
+// topTerms returns the n terms contained in the most visible files, ties in
+// alphabetical order, and the number of the terms of the visible files. The caller
+// must hold muGlobal
+func (s *Searcher) topTerms(n int, visible []bool) ([]TermFreq, int) {
+	h := &termHeap{}
+	vocabulary := 0
+
+	for term, files := range s.Words {
+		tf := TermFreq{Term: term, Docs: docsOf(files, visible)}
+		if tf.Docs == 0 {
+			continue
+		}
+		vocabulary++
+
+		if h.Len() < n {
+			heap.Push(h, tf)
+		} else if h.Len() > 0 && h.less(h.terms[0], tf) {
+			h.terms[0] = tf
+			heap.Fix(h, 0)
+		}
+	}
+
+	res := h.terms
+	sort.Slice(res, func(i, j int) bool { return h.less(res[j], res[i]) })
+
+	return res, vocabulary
+}
+
+// termHeap is a min-heap of the terms, the least frequent on top
+type termHeap struct {
+	terms []TermFreq
+}
+
+// less orders by the number of files, a later term is less on ties
+func (h *termHeap) less(a, b TermFreq) bool {
+	if a.Docs != b.Docs {
+		return a.Docs < b.Docs
+	}
+	return a.Term > b.Term
+}
+
+func (h *termHeap) Len() int           { return len(h.terms) }
+func (h *termHeap) Less(i, j int) bool { return h.less(h.terms[i], h.terms[j]) }
+func (h *termHeap) Swap(i, j int)      { h.terms[i], h.terms[j] = h.terms[j], h.terms[i] }
+func (h *termHeap) Push(x any)         { h.terms = append(h.terms, x.(TermFreq)) }
+
+func (h *termHeap) Pop() any {
+	last := h.terms[len(h.terms)-1]
+	h.terms = h.terms[:len(h.terms)-1]
+	return last
+}
@@ -0,0 +1,81 @@
+package searcher
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSearcher_Stats(t *testing.T) {
+	ru, _ := StopWords("ru")
+	en, _ := StopWords("en")
+
+	s := &Searcher{
+		fs: fstest.MapFS{
+			"a.txt":  {Data: []byte("Кот и пёс в доме")},
+			"b.md":   {Data: []byte("The cat and the dog")},
+			"c.txt":  {Data: []byte("кот пёс кот")},
+			"README": {Data: []byte("И всё")},
+		},
+	}
+	WithStopWords(ru)(s)
+	WithStopWords(en)(s)
+	s.Scan()
+
+	st, e := s.Stats(3, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	want := CorpusStats{
+		Generation:   1,
+		Files:        4,
+		Vocabulary:   6,
+		Tokens:       8,
+		AvgDocLength: 2,
+		TopTerms:     []TermFreq{{"пёс", 2}, {"cat", 1}, {"dog", 1}},
+		Extensions:   map[string]int{"txt": 2, "md": 1, NoExt: 1},
+		Languages:    map[string]int{LangUnknown: 4},
+		StopWords:    st.StopWords,
+	}
+
+	if !reflect.DeepEqual(st, want) {
+		t.Errorf("Stats() = %+v, want %+v", st, want)
+	}
+
+	// The files out of the subtrees are not described
+	st, e = s.Stats(3, []string{"a.txt", "c.txt"})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	want = CorpusStats{
+		Generation:   1,
+		Files:        2,
+		Vocabulary:   4,
+		Tokens:       6,
+		AvgDocLength: 3,
+		TopTerms:     []TermFreq{{"пёс", 2}, {"Кот", 1}, {"доме", 1}},
+		Extensions:   map[string]int{"txt": 2},
+		Languages:    map[string]int{LangUnknown: 2},
+		StopWords:    st.StopWords,
+	}
+
+	if !reflect.DeepEqual(st, want) {
+		t.Errorf("Stats() = %+v, want %+v", st, want)
+	}
+
+	if _, ok := s.Words["и"]; ok {
+		t.Errorf("stop word is indexed")
+	}
+
+	res, e := s.Find(Query{Words: []string{"the cat"}})
+	if e != nil || res.Total != 1 || !reflect.DeepEqual(res.StopWords, []string{"the"}) {
+		t.Errorf("Find() = %+v, %v", res, e)
+	}
+
+	if _, e := s.Find(Query{Words: []string{"и в"}}); !errors.Is(e, ErrEmptyQuery) {
+		t.Errorf("Find() error = %v, want %v", e, ErrEmptyQuery)
+	}
+}
@@ -0,0 +1,414 @@
+package searcher
+
+import "strings"
+
+// stemEnglish is the Porter stemmer: "connections", "connected" and "connecting" all
+// become "connect". The words with other than ASCII letters are left as they are
+func stemEnglish(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	p := &porter{b: []byte(word), k: len(word) - 1}
+
+	p.step1ab()
+	if p.k > 0 {
+		p.step1c()
+		p.step2()
+		p.step3()
+		p.step4()
+		p.step5()
+	}
+
+	return string(p.b[:p.k+1])
+}
+
+type porter struct {
+	b []byte
+	// The end of the word, inclusive
+	k int
+	// The end of the stem before the suffix matched by ends
+	j int
+}
+
+func (p *porter) cons(i int) bool {
+	switch p.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !p.cons(i-1)
+	}
+
+	return true
+}
+
+// m measures the number of consonant sequences of b[0..j]: [C](VC){m}[V]
+func (p *porter) m() int {
+	n, i := 0, 0
+
+	for ; ; i++ {
+		if i > p.j {
+			return n
+		}
+		if !p.cons(i) {
+			break
+		}
+	}
+	i++
+
+	for {
+		for ; ; i++ {
+			if i > p.j {
+				return n
+			}
+			if p.cons(i) {
+				break
+			}
+		}
+		i++
+		n++
+
+		for ; ; i++ {
+			if i > p.j {
+				return n
+			}
+			if !p.cons(i) {
+				break
+			}
+		}
+		i++
+	}
+}
+
+func (p *porter) vowelInStem() bool {
+	for i := 0; i <= p.j; i++ {
+		if !p.cons(i) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// doublec reports whether b[j-1..j] is a double consonant
+func (p *porter) doublec(j int) bool {
+	return j >= 1 && p.b[j] == p.b[j-1] && p.cons(j)
+}
+
+// cvc reports whether b[i-2..i] is consonant-vowel-consonant and the last one is not
+// w, x or y: "hop" but not "snow"
+func (p *porter) cvc(i int) bool {
+	if i < 2 || !p.cons(i) || p.cons(i-1) || !p.cons(i-2) {
+		return false
+	}
+
+	switch p.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+
+	return true
+}
+
+func (p *porter) ends(s string) bool {
+	if len(s) > p.k+1 || string(p.b[p.k+1-len(s):p.k+1]) != s {
+		return false
+	}
+
+	p.j = p.k - len(s)
+
+	return true
+}
+
+// setto replaces the suffix matched by ends
+func (p *porter) setto(s string) {
+	p.b = append(p.b[:p.j+1], s...)
+	p.k = p.j + len(s)
+}
+
+func (p *porter) replace(s string) {
+	if p.m() > 0 {
+		p.setto(s)
+	}
+}
+
+// step1ab removes the plurals and -ed or -ing
+func (p *porter) step1ab() {
+	if p.b[p.k] == 's' {
+		switch {
+		case p.ends("sses"):
+			p.k -= 2
+		case p.ends("ies"):
+			p.setto("i")
+		case p.b[p.k-1] != 's':
+			p.k--
+		}
+	}
+
+	if p.ends("eed") {
+		if p.m() > 0 {
+			p.k--
+		}
+		return
+	}
+
+	if !(p.ends("ed") || p.ends("ing")) || !p.vowelInStem() {
+		return
+	}
+
+	p.k = p.j
+
+	switch {
+	case p.ends("at"):
+		p.setto("ate")
+	case p.ends("bl"):
+		p.setto("ble")
+	case p.ends("iz"):
+		p.setto("ize")
+	case p.doublec(p.k):
+		switch p.b[p.k] {
+		case 'l', 's', 'z':
+		default:
+			p.k--
+		}
+	case p.m() == 1 && p.cvc(p.k):
+		p.setto("e")
+	}
+}
+
+// step1c turns a terminal y into i when there is another vowel in the stem
+func (p *porter) step1c() {
+	if p.ends("y") && p.vowelInStem() {
+		p.b[p.k] = 'i'
+	}
+}
+
+// Suffixes of the steps 2 and 3 and their replacements, by the letter the suffix is
+// told by
+var (
+	porterStep2 = map[byte][][2]string{
+		'a': {{"ational", "ate"}, {"tional", "tion"}},
+		'c': {{"enci", "ence"}, {"anci", "ance"}},
+		'e': {{"izer", "ize"}},
+		'l': {{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"}},
+		'o': {{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}},
+		's': {{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"}},
+		't': {{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}},
+		'g': {{"logi", "log"}},
+	}
+	porterStep3 = map[byte][][2]string{
+		'e': {{"icate", "ic"}, {"ative", ""}, {"alize", "al"}},
+		'i': {{"iciti", "ic"}},
+		'l': {{"ical", "ic"}, {"ful", ""}},
+		's': {{"ness", ""}},
+	}
+	porterStep4 = map[byte][]string{
+		'a': {"al"},
+		'c': {"ance", "ence"},
+		'e': {"er"},
+		'i': {"ic"},
+		'l': {"able", "ible"},
+		'n': {"ant", "ement", "ment", "ent"},
+		'o': {"ion", "ou"},
+		's': {"ism"},
+		't': {"ate", "iti"},
+		'u': {"ous"},
+		'v': {"ive"},
+		'z': {"ize"},
+	}
+)
+
+// step2 maps the double suffixes to single ones: -ization to -ize
+func (p *porter) step2() {
+	for _, r := range porterStep2[p.b[p.k-1]] {
+		if p.ends(r[0]) {
+			p.replace(r[1])
+			return
+		}
+	}
+}
+
+// step3 handles -ic-, -full, -ness
+func (p *porter) step3() {
+	for _, r := range porterStep3[p.b[p.k]] {
+		if p.ends(r[0]) {
+			p.replace(r[1])
+			return
+		}
+	}
+}
+
+// step4 removes -ant, -ence and the like from a long enough stem
+func (p *porter) step4() {
+	found := false
+
+	for _, suffix := range porterStep4[p.b[p.k-1]] {
+		if !p.ends(suffix) {
+			continue
+		}
+
+		// -ion only after s or t
+		if suffix == "ion" && (p.j < 0 || (p.b[p.j] != 's' && p.b[p.j] != 't')) {
+			continue
+		}
+
+		found = true
+		break
+	}
+
+	if found && p.m() > 1 {
+		p.k = p.j
+	}
+}
+
+// step5 removes a final -e and the double l of a long enough stem
+func (p *porter) step5() {
+	p.j = p.k
+
+	if p.b[p.k] == 'e' {
+		if a := p.m(); a > 1 || (a == 1 && !p.cvc(p.k-1)) {
+			p.k--
+		}
+	}
+
+	if p.b[p.k] == 'l' && p.doublec(p.k) && p.m() > 1 {
+		p.k--
+	}
+}
+
+// Endings of the Russian stemmer. The ones of the first groups only follow а or я,
+// which stays
+var (
+	ruPerfectiveGerund1 = []string{"в", "вши", "вшись"}
+	ruPerfectiveGerund2 = []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}
+	ruAdjective         = []string{"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом", "его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею"}
+	ruParticiple1       = []string{"ем", "нн", "вш", "ющ", "щ"}
+	ruParticiple2       = []string{"ивш", "ывш", "ующ"}
+	ruReflexive         = []string{"ся", "сь"}
+	ruVerb1             = []string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно"}
+	ruVerb2             = []string{"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен", "ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю"}
+	ruNoun              = []string{"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й", "иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я"}
+	ruSuperlative       = []string{"ейш", "ейше"}
+	ruDerivational      = []string{"ост", "ость"}
+)
+
+// stemRussian is the Snowball stemmer of Russian: "книгами", "книге" and "книга" all
+// become "книг". The words without Cyrillic vowels are left as they are
+func stemRussian(word string) string {
+	w := []rune(strings.ReplaceAll(word, "ё", "е"))
+
+	rv := len(w)
+	for i, r := range w {
+		if isRussianVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+
+	if rv == len(w) {
+		return word
+	}
+
+	r1 := russianRegion(w, 0)
+	r2 := russianRegion(w, r1)
+
+	// Step 1: a perfective gerund, or else a reflexive ending followed by an
+	// adjectival, a verb or a noun ending
+	if n := ruEnding(w, rv, ruPerfectiveGerund1, ruPerfectiveGerund2); n > 0 {
+		w = w[:len(w)-n]
+	} else {
+		if n := ruEnding(w, rv, nil, ruReflexive); n > 0 {
+			w = w[:len(w)-n]
+		}
+
+		if n := ruEnding(w, rv, nil, ruAdjective); n > 0 {
+			w = w[:len(w)-n]
+			if n := ruEnding(w, rv, ruParticiple1, ruParticiple2); n > 0 {
+				w = w[:len(w)-n]
+			}
+		} else if n := ruEnding(w, rv, ruVerb1, ruVerb2); n > 0 {
+			w = w[:len(w)-n]
+		} else if n := ruEnding(w, rv, nil, ruNoun); n > 0 {
+			w = w[:len(w)-n]
+		}
+	}
+
+	// Step 2
+	if len(w) > rv && w[len(w)-1] == 'и' {
+		w = w[:len(w)-1]
+	}
+
+	// Step 3: a derivational ending in R2
+	if n := ruEnding(w, r2, nil, ruDerivational); n > 0 {
+		w = w[:len(w)-n]
+	}
+
+	// Step 4: нн to н, a superlative ending or a soft sign
+	switch {
+	case ruEnding(w, rv, nil, []string{"нн"}) > 0:
+		w = w[:len(w)-1]
+	case ruEnding(w, rv, nil, ruSuperlative) > 0:
+		w = w[:len(w)-ruEnding(w, rv, nil, ruSuperlative)]
+
+		if ruEnding(w, rv, nil, []string{"нн"}) > 0 {
+			w = w[:len(w)-1]
+		}
+	case len(w) > rv && w[len(w)-1] == 'ь':
+		w = w[:len(w)-1]
+	}
+
+	return string(w)
+}
+
+func isRussianVowel(r rune) bool {
+	switch r {
+	case 'а', 'е', 'и', 'о', 'у', 'ы', 'э', 'ю', 'я':
+		return true
+	}
+
+	return false
+}
+
+// russianRegion returns the start of the region after the first non-vowel following a
+// vowel from the position
+func russianRegion(w []rune, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isRussianVowel(w[i]) && isRussianVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+
+	return len(w)
+}
+
+// ruEnding returns the length of the longest of the endings the word ends with within
+// the region starting at the position, 0 when there is none. The endings of the first
+// list only count after а or я
+func ruEnding(w []rune, region int, afterA, endings []string) int {
+	best := 0
+
+	for group, list := range [][]string{afterA, endings} {
+		for _, ending := range list {
+			e := []rune(ending)
+			start := len(w) - len(e)
+
+			if len(e) <= best || start < region || string(w[start:]) != ending {
+				continue
+			}
+
+			if group == 0 && (start-1 < region || (w[start-1] != 'а' && w[start-1] != 'я')) {
+				continue
+			}
+
+			best = len(e)
+		}
+	}
+
+	return best
+}
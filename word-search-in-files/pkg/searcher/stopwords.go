@@ -0,0 +1,109 @@
+package searcher
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Built-in stop-word lists by language code
+var stopWordLists = map[string]string{
+	"ru": `
+		а без более бы был была были было быть в вам вас весь во вот все всё всего всех вы где да
+		даже для до его ее её ей ему если есть еще ещё же за здесь и из или им их к как ко
+		когда кто ли либо мне может мы на над надо наш не него нее неё нет ни них но ну о
+		об однако он она они оно от очень по под при с со так также такой там те тем то того
+		тоже той только том ты у уже хотя чего чей чем что чтобы чье чьё эта эти это я
+		этот этого этой этом этому этим меня мой моя мое моё мои тебя тебе твой себя себе
+		свой сам сама само сами ним ней нем нём нему нами вами ими раз будет будут
+		вдруг ведь впрочем всегда вообще где-то зачем иногда кроме куда лучше между много
+		можно наконец нельзя никогда ничего нибудь опять перед потом потому почти после
+		разве сейчас сюда теперь тогда тут уж хоть чуть эх ах ох
+	`,
+	"en": `
+		a about above after again against all am an and any are aren't as at be because been
+		before being below between both but by can can't cannot could couldn't did didn't do
+		does doesn't doing don't down during each few for from further had hadn't has hasn't
+		have haven't having he he'd he'll he's her here here's hers herself him himself his how
+		how's i i'd i'll i'm i've if in into is isn't it it's its itself let's me more most
+		mustn't my myself no nor not of off on once only or other ought our ours ourselves out
+		over own same shan't she she'd she'll she's should shouldn't so some such than that
+		that's the their theirs them themselves then there there's these they they'd they'll
+		they're they've this those through to too under until up very was wasn't we we'd we'll
+		we're we've were weren't what what's when when's where where's which while who who's
+		whom why why's with won't would wouldn't you you'd you'll you're you've your yours
+		yourself yourselves
+	`,
+}
+
+// StopWordLanguages returns the codes of the languages with a built-in stop-word list
+func StopWordLanguages() []string {
+	res := make([]string, 0, len(stopWordLists))
+	for lang := range stopWordLists {
+		res = append(res, lang)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// StopWords returns the built-in stop-word list of the language
+func StopWords(lang string) ([]string, error) {
+	list, ok := stopWordLists[lang]
+	if !ok {
+		return nil, fmt.Errorf("no built-in stop words for %q, available: %s", lang, strings.Join(StopWordLanguages(), ", "))
+	}
+
+	return strings.Fields(list), nil
+}
+
+// ReadStopWords reads a stop-word list: words separated by spaces or new lines,
+// the lines starting with '#' are comments
+func ReadStopWords(r io.Reader) ([]string, error) {
+	var res []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		res = append(res, strings.Fields(line)...)
+	}
+
+	if e := scanner.Err(); e != nil {
+		return nil, e
+	}
+
+	return res, nil
+}
+
+// WithStopWords excludes the words from the index and the queries, case insensitive.
+// The option can be given several times, the lists are merged
+func WithStopWords(words []string) Option {
+	return func(s *Searcher) {
+		if s.stopWords == nil {
+			s.stopWords = make(map[string]struct{}, len(words))
+		}
+
+		for _, word := range words {
+			// Stored the way the scanner normalizes the words, so "don't" is "dont"
+			if word = strings.ToLower(removePunctuation(word)); word != "" {
+				s.stopWords[word] = struct{}{}
+			}
+		}
+	}
+}
+
+func (s *Searcher) isStopWord(word string) bool {
+	if len(s.stopWords) == 0 {
+		return false
+	}
+
+	_, ok := s.stopWords[strings.ToLower(word)]
+	return ok
+}
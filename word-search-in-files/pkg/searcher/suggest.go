@@ -0,0 +1,262 @@
+package searcher
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DefaultSuggestLimit = 10
+	MaxSuggestLimit     = 100
+
+	// Number of candidates offered for a misspelled word
+	maxCorrections = 3
+	// Words corrected by a query, the longer words are not and the dictionary terms
+	// compared to a word are bounded
+	maxCorrectedWords  = 8
+	maxCorrectedLength = 32
+	maxCorrectionScan  = 20000
+)
+
+var ErrEmptyPrefix = errors.New("prefix has no letters or digits")
+
+// TermFreq is a term of the dictionary with the number of files containing it
+type TermFreq struct {
+	Term string
+	Docs int
+}
+
+// Correction offers the known terms close to a word missing from the index
+type Correction struct {
+	Word       string
+	Candidates []string
+}
+
+// Suggest returns the terms starting with the prefix, the most frequent (by the
+// number of files) first, and the number of them before the limit. Only the files
+// of the subtrees are counted, the terms of the other ones are unknown, nil does not
+// restrict, see Filter.Subtrees
+func (s *Searcher) Suggest(prefix string, limit int, subtrees []string) ([]TermFreq, int, error) {
+	prefix = removePunctuation(prefix)
+	if prefix == "" {
+		return nil, 0, ErrEmptyPrefix
+	}
+
+	if limit < 0 {
+		return nil, 0, ErrBadLimit
+	}
+
+	if limit == 0 {
+		limit = DefaultSuggestLimit
+	}
+	limit = min(limit, MaxSuggestLimit)
+
+	s.muGlobal.RLock()
+	defer s.muGlobal.RUnlock()
+
+	visible := s.visible(subtrees)
+
+	var terms []TermFreq
+	for _, term := range s.withPrefix(prefix) {
+		if docs := docsOf(s.Words[term], visible); docs > 0 {
+			terms = append(terms, TermFreq{Term: term, Docs: docs})
+		}
+	}
+
+	sort.SliceStable(terms, func(i, j int) bool {
+		return terms[i].Docs > terms[j].Docs
+	})
+
+	total := len(terms)
+	if total > limit {
+		terms = terms[:limit]
+	}
+
+	return terms, total, nil
+}
+
+// withPrefix returns the sorted terms of the dictionary starting with the prefix,
+// the caller must hold muGlobal
+func (s *Searcher) withPrefix(prefix string) []string {
+	from := sort.SearchStrings(s.dict, prefix)
+
+	to := from
+	for to < len(s.dict) && strings.HasPrefix(s.dict[to], prefix) {
+		to++
+	}
+
+	return s.dict[from:to]
+}
+
+// visible returns which files are in the subtrees, nil for all of them. The caller
+// must hold muGlobal
+func (s *Searcher) visible(subtrees []string) []bool {
+	dirs := Filter{Subtrees: subtrees}.subtrees()
+	if dirs == nil {
+		return nil
+	}
+
+	res := make([]bool, len(s.Files))
+	for i, f := range s.Files {
+		res[i] = InSubtrees(f.Path, dirs)
+	}
+
+	return res
+}
+
+// docsOf returns the number of the visible files of the postings
+func docsOf(files map[int]int, visible []bool) int {
+	if visible == nil {
+		return len(files)
+	}
+
+	n := 0
+	for index := range files {
+		if visible[index] {
+			n++
+		}
+	}
+
+	return n
+}
+
+// corrections offers the closest terms of the visible files for every word missing
+// from them, the caller must hold muGlobal
+func (s *Searcher) corrections(words []string, visible []bool) []Correction {
+	var res []Correction
+
+	for i, word := range words {
+		if i == maxCorrectedWords {
+			break
+		}
+
+		if docsOf(s.Words[word], visible) > 0 || isWildcard(word) || isField(word) {
+			continue
+		}
+
+		if candidates := s.closest(word, visible); len(candidates) > 0 {
+			res = append(res, Correction{Word: word, Candidates: candidates})
+		}
+	}
+
+	return res
+}
+
+// closest returns the terms of the visible files within the edit distance allowed
+// for the length of the word, the closest and then the most frequent first. Only the
+// terms of the lengths within the distance are compared, at most maxCorrectionScan
+func (s *Searcher) closest(word string, visible []bool) []string {
+	runes := []rune(word)
+	if len(runes) > maxCorrectedLength {
+		return nil
+	}
+
+	maxDist := 1
+	if len(runes) > 4 {
+		maxDist = 2
+	}
+
+	type candidate struct {
+		term string
+		dist int
+		docs int
+	}
+
+	better := func(a, b candidate) bool {
+		if a.dist != b.dist {
+			return a.dist < b.dist
+		}
+		if a.docs != b.docs {
+			return a.docs > b.docs
+		}
+		return a.term < b.term
+	}
+
+	// The best candidates so far, in order
+	found := make([]candidate, 0, maxCorrections+1)
+	scanned := 0
+
+	for n := max(len(runes)-maxDist, 1); n <= len(runes)+maxDist; n++ {
+		for _, term := range s.byLength[n] {
+			if scanned == maxCorrectionScan {
+				break
+			}
+			scanned++
+
+			d := levenshtein(runes, []rune(term), maxDist)
+			if d > maxDist {
+				continue
+			}
+
+			docs := docsOf(s.Words[term], visible)
+			if docs == 0 {
+				continue
+			}
+
+			c := candidate{term: term, dist: d, docs: docs}
+			i := sort.Search(len(found), func(i int) bool { return better(c, found[i]) })
+			if i == maxCorrections {
+				continue
+			}
+
+			found = append(found[:i], append([]candidate{c}, found[i:]...)...)
+			if len(found) > maxCorrections {
+				found = found[:maxCorrections]
+			}
+		}
+	}
+
+	res := make([]string, len(found))
+	for i, c := range found {
+		res[i] = c.term
+	}
+
+	return res
+}
+
+// termsByLength groups the sorted terms by their number of runes, for the corrections
+func termsByLength(dict []string) map[int][]string {
+	res := make(map[int][]string)
+	for _, term := range dict {
+		n := utf8.RuneCountInString(term)
+		res[n] = append(res[n], term)
+	}
+
+	return res
+}
+
+// levenshtein returns the edit distance of a and b, or limit+1 as soon as it is
+// known to exceed limit
+func levenshtein(a, b []rune, limit int) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+
+		if rowMin > limit {
+			return limit + 1
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
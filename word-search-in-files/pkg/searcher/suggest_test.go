@@ -0,0 +1,160 @@
+package searcher
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSearcher_Suggest(t *testing.T) {
+	s := &Searcher{
+		fs: fstest.MapFS{
+			"file1.txt": {Data: []byte("туман туманный тучи")},
+			"file2.txt": {Data: []byte("туманный день")},
+			"file3.txt": {Data: []byte("туманный туман")},
+		},
+	}
+	s.Scan()
+
+	tests := []struct {
+		name      string
+		prefix    string
+		limit     int
+		want      []TermFreq
+		wantTotal int
+		wantErr   error
+	}{
+		{
+			name:      "Ok",
+			prefix:    "ту",
+			want:      []TermFreq{{"туманный", 3}, {"туман", 2}, {"тучи", 1}},
+			wantTotal: 3,
+		},
+		{
+			name:      "Limit",
+			prefix:    "туман",
+			limit:     1,
+			want:      []TermFreq{{"туманный", 3}},
+			wantTotal: 2,
+		},
+		{
+			name:   "None",
+			prefix: "я",
+			want:   nil,
+		},
+		{
+			name:    "E: empty",
+			prefix:  "!",
+			wantErr: ErrEmptyPrefix,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, e := s.Suggest(tt.prefix, tt.limit, nil)
+			if e != tt.wantErr {
+				t.Fatalf("Suggest() error = %v, wantErr %v", e, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) || total != tt.wantTotal {
+				t.Errorf("Suggest() = %v, %d, want %v, %d", got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+
+	t.Run("Did you mean", func(t *testing.T) {
+		res, e := s.Find(Query{Words: []string{"туманый день"}})
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		want := []Correction{{Word: "туманый", Candidates: []string{"туманный", "туман"}}}
+		if !reflect.DeepEqual(res.Corrections, want) {
+			t.Errorf("Find() corrections = %v, want %v", res.Corrections, want)
+		}
+	})
+}
+
+func TestSearcher_Suggest_Subtrees(t *testing.T) {
+	s := &Searcher{
+		fs: fstest.MapFS{
+			"docs/a.txt": {Data: []byte("public plan")},
+			"src/b.txt":  {Data: []byte("secret plan planet")},
+		},
+	}
+	s.Scan()
+
+	got, _, e := s.Suggest("p", 0, []string{"docs"})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	want := []TermFreq{{"plan", 1}, {"public", 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Suggest() = %v, want %v", got, want)
+	}
+
+	// A term of the hidden files is missing and never offered
+	res, e := s.Find(Query{Words: []string{"secret"}, Filter: Filter{Subtrees: []string{"docs"}}})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if res.Total != 0 || res.Corrections != nil {
+		t.Errorf("Find() = %d hits, corrections %v, want none", res.Total, res.Corrections)
+	}
+
+	res, e = s.Find(Query{Words: []string{"planer"}, Filter: Filter{Subtrees: []string{"docs"}}})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	wantCorrections := []Correction{{Word: "planer", Candidates: []string{"plan"}}}
+	if !reflect.DeepEqual(res.Corrections, wantCorrections) {
+		t.Errorf("Find() corrections = %v, want %v", res.Corrections, wantCorrections)
+	}
+}
+
+func TestSearcher_closest(t *testing.T) {
+	s := &Searcher{
+		fs: fstest.MapFS{
+			"a.txt": {Data: []byte("plan plans plant planet plane plank planer")},
+			"b.txt": {Data: []byte("plant plane")},
+		},
+	}
+	s.Scan()
+
+	tests := []struct {
+		name string
+		word string
+		want []string
+	}{
+		{
+			name: "Closest and most frequent first",
+			word: "plani",
+			want: []string{"plane", "plant", "plan"},
+		},
+		{
+			name: "Length out of the distance",
+			word: "pl",
+			want: []string{},
+		},
+		{
+			name: "Too long",
+			word: strings.Repeat("plan", 10),
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.muGlobal.RLock()
+			got := s.closest(tt.word, nil)
+			s.muGlobal.RUnlock()
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("closest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
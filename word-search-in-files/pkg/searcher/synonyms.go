@@ -0,0 +1,190 @@
+package searcher
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+var ErrBadSynonyms = errors.New("malformed synonyms")
+
+// Synonyms expands the words of the queries. A rule is a line: `пёс, собака, пса` is a
+// group of words standing for each other, `авто => автомобиль, машина` a one-way
+// expansion of the words on the left. Lines starting with '#' are comments
+type Synonyms struct {
+	// Word -> the words it also stands for, in the order of the rules
+	expand map[string][]string
+	// The same in lower case
+	folded map[string][]string
+	rules  int
+}
+
+// Expansion is a query word with the synonyms it was expanded to
+type Expansion struct {
+	Word     string
+	Synonyms []string
+}
+
+// ReadSynonyms parses the rules, the words are normalized the same way as the ones of
+// the queries, their case is kept. A phrase of several words can not be a synonym
+func ReadSynonyms(r io.Reader) (*Synonyms, error) {
+	syn := &Synonyms{expand: make(map[string][]string), folded: make(map[string][]string)}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		left, right, oneWay := strings.Cut(line, "=>")
+
+		from, e := synonymWords(left)
+		if e != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrBadSynonyms, n, e)
+		}
+
+		if !oneWay {
+			if len(from) < 2 {
+				return nil, fmt.Errorf("%w: line %d: a group needs two words or more", ErrBadSynonyms, n)
+			}
+
+			for _, word := range from {
+				syn.add(word, from)
+			}
+			syn.rules++
+			continue
+		}
+
+		to, e := synonymWords(right)
+		if e != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrBadSynonyms, n, e)
+		}
+
+		if len(from) == 0 || len(to) == 0 {
+			return nil, fmt.Errorf("%w: line %d: an expansion needs words on both sides of =>", ErrBadSynonyms, n)
+		}
+
+		for _, word := range from {
+			syn.add(word, to)
+		}
+		syn.rules++
+	}
+
+	if e := scanner.Err(); e != nil {
+		return nil, e
+	}
+
+	return syn, nil
+}
+
+// LoadSynonyms reads the rules of the file, see ReadSynonyms
+func LoadSynonyms(file string) (*Synonyms, error) {
+	f, e := os.Open(file)
+	if e != nil {
+		return nil, e
+	}
+	defer f.Close()
+
+	return ReadSynonyms(f)
+}
+
+// synonymWords splits the comma separated words of a rule
+func synonymWords(list string) ([]string, error) {
+	var res []string
+
+	for _, field := range strings.Split(list, ",") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+
+		if len(strings.Fields(field)) > 1 {
+			return nil, fmt.Errorf("%q is not a single word", strings.TrimSpace(field))
+		}
+
+		if word := removePunctuation(field); word != "" {
+			res = append(res, word)
+		}
+	}
+
+	return res, nil
+}
+
+func (syn *Synonyms) add(word string, to []string) {
+	lower := strings.ToLower(word)
+
+	for _, w := range to {
+		if w != word && !slices.Contains(syn.expand[word], w) {
+			syn.expand[word] = append(syn.expand[word], w)
+		}
+
+		if w = strings.ToLower(w); w != lower && !slices.Contains(syn.folded[lower], w) {
+			syn.folded[lower] = append(syn.folded[lower], w)
+		}
+	}
+}
+
+// Expand returns the words the query word also stands for, nil when it has no synonyms.
+// The words of the rules match in the same case, or in any with fold, the way the
+// index compares them without and with the analyzers
+func (syn *Synonyms) Expand(word string, fold bool) []string {
+	if syn == nil {
+		return nil
+	}
+
+	if fold {
+		return syn.folded[strings.ToLower(word)]
+	}
+
+	return syn.expand[word]
+}
+
+// Rules returns the number of the groups and the expansions
+func (syn *Synonyms) Rules() int {
+	if syn == nil {
+		return 0
+	}
+
+	return syn.rules
+}
+
+// WithSynonyms expands the query words with the synonyms, see SetSynonyms
+func WithSynonyms(syn *Synonyms) Option {
+	return func(s *Searcher) {
+		s.synonyms = syn
+	}
+}
+
+// SetSynonyms replaces the synonyms of the queries, nil disables them. The queries in
+// progress complete with the previous ones, the cached results are dropped and
+// Result.Synonyms changes
+func (s *Searcher) SetSynonyms(syn *Synonyms) {
+	s.muGlobal.Lock()
+	defer s.muGlobal.Unlock()
+
+	s.synonyms = syn
+	s.synonymsVersion++
+	s.cache.invalidate(s.generation)
+}
+
+// expansions returns the synonyms of the words of the query, the caller must hold
+// muGlobal
+func (s *Searcher) expansions(words []string) []Expansion {
+	var res []Expansion
+
+	for _, word := range words {
+		if isWildcard(word) || isField(word) {
+			continue
+		}
+
+		if synonyms := s.synonyms.Expand(word, s.analyzers); len(synonyms) > 0 {
+			res = append(res, Expansion{Word: word, Synonyms: synonyms})
+		}
+	}
+
+	return res
+}
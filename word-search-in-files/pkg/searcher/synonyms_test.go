@@ -0,0 +1,178 @@
+package searcher
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadSynonyms(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name:  "Group",
+			rules: "# dogs\nпёс, Собака, пса\n",
+			want:  map[string][]string{"пёс": {"Собака", "пса"}, "Собака": {"пёс", "пса"}, "пса": {"пёс", "Собака"}},
+		},
+		{
+			name:  "Expansion",
+			rules: "авто, машина => автомобиль",
+			want:  map[string][]string{"авто": {"автомобиль"}, "машина": {"автомобиль"}},
+		},
+		{
+			name:  "Merged",
+			rules: "car, auto\ncar => vehicle\n\n",
+			want:  map[string][]string{"car": {"auto", "vehicle"}, "auto": {"car"}},
+		},
+		{name: "E: single word group", rules: "dog", wantErr: true},
+		{name: "E: empty side", rules: "dog =>", wantErr: true},
+		{name: "E: phrase", rules: "car, motor vehicle", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			syn, e := ReadSynonyms(strings.NewReader(tt.rules))
+			if (e != nil) != tt.wantErr {
+				t.Fatalf("ReadSynonyms() error = %v, wantErr %v", e, tt.wantErr)
+			}
+			if e != nil {
+				if !errors.Is(e, ErrBadSynonyms) {
+					t.Errorf("error %v is not ErrBadSynonyms", e)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(syn.expand, tt.want) {
+				t.Errorf("ReadSynonyms() = %v, want %v", syn.expand, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearcher_FindSynonyms(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("пёс лает")},
+		"b.txt": {Data: []byte("собака спит")},
+		"c.txt": {Data: []byte("у пса будка")},
+		"d.txt": {Data: []byte("автомобиль едет")},
+		"e.txt": {Data: []byte("авто стоит")},
+	}
+
+	syn, e := ReadSynonyms(strings.NewReader("пёс, собака, пса\nавто => автомобиль"))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	tests := []struct {
+		name     string
+		words    []string
+		want     []string
+		wantExps []Expansion
+	}{
+		{name: "Group", words: []string{"пёс"}, want: []string{"a.txt", "b.txt", "c.txt"}, wantExps: []Expansion{{Word: "пёс", Synonyms: []string{"собака", "пса"}}}},
+		{name: "Another word of the group", words: []string{"собака"}, want: []string{"a.txt", "b.txt", "c.txt"}, wantExps: []Expansion{{Word: "собака", Synonyms: []string{"пёс", "пса"}}}},
+		{name: "With a word", words: []string{"пёс", "спит"}, want: []string{"b.txt"}, wantExps: []Expansion{{Word: "пёс", Synonyms: []string{"собака", "пса"}}}},
+		{name: "Expansion", words: []string{"авто"}, want: []string{"d.txt", "e.txt"}, wantExps: []Expansion{{Word: "авто", Synonyms: []string{"автомобиль"}}}},
+		{name: "One way", words: []string{"автомобиль"}, want: []string{"d.txt"}},
+	}
+
+	s := &Searcher{fs: fsys, cache: newResultCache(DefaultCacheSize)}
+	WithSynonyms(syn)(s)
+	s.Scan()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, e := s.Find(Query{Words: tt.words})
+			if e != nil {
+				t.Fatal(e)
+			}
+
+			var got []string
+			for _, hit := range res.Hits {
+				got = append(got, hit.Path)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(res.Expansions, tt.wantExps) {
+				t.Errorf("Expansions = %v, want %v", res.Expansions, tt.wantExps)
+			}
+		})
+	}
+
+	before, e := s.Find(Query{Words: []string{"пёс"}})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// The cached result of the previous rules is not used
+	s.SetSynonyms(nil)
+
+	res, e := s.Find(Query{Words: []string{"пёс"}})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if res.Total != 1 || res.Expansions != nil {
+		t.Errorf("Find() without synonyms = %d hits, expansions %v", res.Total, res.Expansions)
+	}
+
+	if res.Generation != before.Generation || res.Synonyms == before.Synonyms {
+		t.Errorf("Find() synonyms version = %d, the one of the previous rules", res.Synonyms)
+	}
+}
+
+func TestSearcher_FindSynonyms_Case(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("Car parked")},
+		"b.txt": {Data: []byte("car moving")},
+		"c.txt": {Data: []byte("Automobile")},
+	}
+
+	syn, e := ReadSynonyms(strings.NewReader("Automobile, Car"))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	tests := []struct {
+		name      string
+		analyzers bool
+		words     []string
+		want      []string
+	}{
+		{name: "Same case", words: []string{"Car"}, want: []string{"a.txt", "c.txt"}},
+		{name: "Other case", words: []string{"car"}, want: []string{"b.txt"}},
+		{name: "Analyzers", analyzers: true, words: []string{"car"}, want: []string{"a.txt", "b.txt", "c.txt"}},
+		{name: "Analyzers, upper case", analyzers: true, words: []string{"AUTOMOBILE"}, want: []string{"a.txt", "b.txt", "c.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Searcher{fs: fsys, analyzers: tt.analyzers}
+			WithSynonyms(syn)(s)
+			s.Scan()
+
+			res, e := s.Find(Query{Words: tt.words, Sort: SortPath})
+			if e != nil {
+				t.Fatal(e)
+			}
+
+			var got []string
+			for _, hit := range res.Hits {
+				got = append(got, hit.Path)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package searcher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// Bytes read beyond the bounds of a range to match the words crossing them in whole
+	maxWordLookaround = 256
+	// Bytes of the file tokenized at once, a longer word is cut
+	viewChunkSize = 64 * 1024
+)
+
+var ErrBadRange = errors.New("range is not satisfiable")
+
+// ViewQuery opens a file of the index with the words of a query to mark
+type ViewQuery struct {
+	// Relative to the root of the index, only the files of the index can be opened
+	Path string
+	// Marked the way Query.Words match, with the synonyms and the patterns. None marks
+	// nothing
+	Words []string
+	// The files out of the directories are unknown, nil does not restrict, see
+	// Filter.Subtrees
+	Subtrees []string
+}
+
+// View is a file of the index open for reading, it must be closed
+type View struct {
+	Path string
+	Lang string
+	// Of the file as opened, it may have changed since the scan
+	Size     int64
+	Modified time.Time
+	// Snapshot of the index the terms to mark were taken from
+	Generation uint64
+
+	file fs.File
+	// Reports whether a word of the file is one of the terms of the query
+	marked func(word string) bool
+}
+
+// View opens the file of the index for reading its words with the ones of the query
+// marked. The words go through the stop words and the analyzer the file was indexed
+// with, so the marks are the words the query matched. Paths out of the index, such as
+// the ones escaping the root, are ErrUnknownFile
+func (s *Searcher) View(q ViewQuery) (*View, error) {
+	target := strings.TrimPrefix(q.Path, "./")
+	if !fs.ValidPath(target) || target == "." {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFile, q.Path)
+	}
+
+	v, fsys, e := s.viewOf(target, q)
+	if e != nil {
+		return nil, e
+	}
+
+	if fsys == nil {
+		return nil, ErrNoFS
+	}
+
+	if v.file, e = fsys.Open(target); e != nil {
+		return nil, e
+	}
+
+	info, e := v.file.Stat()
+	if e != nil {
+		v.file.Close()
+		return nil, e
+	}
+
+	if !info.Mode().IsRegular() {
+		v.file.Close()
+		return nil, fmt.Errorf("%w: %q is not a regular file", ErrUnknownFile, q.Path)
+	}
+
+	v.Size, v.Modified = info.Size(), info.ModTime()
+
+	return v, nil
+}
+
+// viewOf looks the file and the terms of the query up in the current generation
+func (s *Searcher) viewOf(target string, q ViewQuery) (*View, fs.FS, error) {
+	s.muGlobal.RLock()
+	defer s.muGlobal.RUnlock()
+
+	var f *FileInfo
+	for i := range s.Files {
+		if s.Files[i].Path == target {
+			f = &s.Files[i]
+			break
+		}
+	}
+
+	// The files out of the subtrees of the caller do not exist for it
+	if f == nil || q.Subtrees != nil && !InSubtrees(f.Path, q.Subtrees) {
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnknownFile, q.Path)
+	}
+
+	// The terms of the file are the ones of the analyzer of its language
+	var langs []string
+	if s.analyzers {
+		langs = []string{LangUnknown}
+		if f.Lang != "" {
+			langs[0] = f.Lang
+		}
+	}
+
+	words, _ := s.normalizeWords(q.Words, langs)
+
+	if s.maxQueryCost > 0 {
+		if cost := s.estimate(words, langs); cost > s.maxQueryCost {
+			return nil, nil, fmt.Errorf("%w: it walks %d terms and postings, the limit is %d", ErrQueryTooBroad, cost, s.maxQueryCost)
+		}
+	}
+
+	terms := make(map[string]struct{})
+	for _, word := range words {
+		// The fields are not in the content
+		if isField(word) {
+			continue
+		}
+
+		found, _ := s.termsOf(word, langs)
+		for _, term := range found {
+			terms[term] = struct{}{}
+		}
+	}
+
+	v := &View{Path: f.Path, Lang: f.Lang, Generation: s.generation}
+
+	if len(terms) > 0 {
+		a, stopWords := s.analyzerOf(f.Lang), s.stopWords
+
+		v.marked = func(word string) bool {
+			if len(stopWords) > 0 {
+				if _, ok := stopWords[strings.ToLower(word)]; ok {
+					return false
+				}
+			}
+
+			if a != nil {
+				var ok bool
+				if word, ok = a.term(word); !ok {
+					return false
+				}
+			}
+
+			_, ok := terms[word]
+			return ok
+		}
+	}
+
+	return v, s.fs, nil
+}
+
+func (v *View) Close() error {
+	return v.file.Close()
+}
+
+// Read calls fn with the consecutive pieces of the bytes [start, end) of the file and
+// their offsets, the words of the query in their own marked pieces. A word crossing a
+// bound is matched in whole when it is at most maxWordLookaround bytes past it, only
+// its part within the range is marked. It is called once per view
+func (v *View) Read(start, end int64, fn func(offset int64, piece []byte, marked bool) error) error {
+	if start < 0 || end > v.Size || start > end {
+		return fmt.Errorf("%w: [%d, %d) of %d bytes", ErrBadRange, start, end, v.Size)
+	}
+
+	from := start
+	if v.marked != nil {
+		from = max(0, start-maxWordLookaround)
+	}
+
+	if e := skip(v.file, from); e != nil {
+		return e
+	}
+
+	to := end
+	if v.marked != nil {
+		to = min(v.Size, end+maxWordLookaround)
+	}
+
+	r := io.LimitReader(v.file, to-from)
+
+	// The words of the file are the fields between the spaces, the way they are indexed
+	emitted := start
+	emit := func(upTo int64, text []byte, offset int64, marked bool) error {
+		upTo = min(upTo, end)
+		if upTo <= emitted {
+			return nil
+		}
+
+		piece := text[emitted-offset : upTo-offset]
+		at := emitted
+		emitted = upTo
+
+		return fn(at, piece, marked)
+	}
+
+	buf := make([]byte, 0, viewChunkSize)
+	offset := from
+
+	for emitted < end {
+		n, e := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+
+		eof := errors.Is(e, io.EOF)
+		if e != nil && !eof {
+			return e
+		}
+
+		// The bytes up to the last space hold complete words
+		cut := len(buf)
+		if v.marked != nil && !eof {
+			if i := lastSpace(buf); i > 0 {
+				cut = i
+			} else if len(buf) < cap(buf) {
+				continue
+			} else {
+				cut = runeBoundary(buf)
+			}
+		}
+
+		if v.marked != nil {
+			for _, m := range v.marks(buf[:cut]) {
+				if e := emit(offset+int64(m[0]), buf, offset, false); e != nil {
+					return e
+				}
+				if e := emit(offset+int64(m[1]), buf, offset, true); e != nil {
+					return e
+				}
+			}
+		}
+
+		if e := emit(offset+int64(cut), buf, offset, false); e != nil {
+			return e
+		}
+
+		if eof {
+			break
+		}
+
+		offset += int64(cut)
+		buf = buf[:copy(buf, buf[cut:])]
+	}
+
+	if emitted < end {
+		return fmt.Errorf("%w: the file ended at %d", io.ErrUnexpectedEOF, emitted)
+	}
+
+	return nil
+}
+
+// marks returns the byte ranges of the words of text to mark, from their first to
+// their last letter or digit
+func (v *View) marks(text []byte) [][2]int {
+	var res [][2]int
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		// The letters and the digits of the field, as removePunctuation keeps them
+		var word strings.Builder
+		first, last := -1, -1
+
+		for i < len(text) {
+			r, size = utf8.DecodeRune(text[i:])
+			if unicode.IsSpace(r) {
+				break
+			}
+
+			if unicode.IsLetter(r) || unicode.IsNumber(r) {
+				if first < 0 {
+					first = i
+				}
+				last = i + size
+				word.WriteRune(r)
+			}
+			i += size
+		}
+
+		if first >= 0 && v.marked(word.String()) {
+			res = append(res, [2]int{first, last})
+		}
+	}
+
+	return res
+}
+
+// skip moves the reader of the file to the offset, by seeking when it can
+func skip(f fs.File, offset int64) error {
+	if offset == 0 {
+		return nil
+	}
+
+	if sk, ok := f.(io.Seeker); ok {
+		_, e := sk.Seek(offset, io.SeekStart)
+		return e
+	}
+
+	_, e := io.CopyN(io.Discard, f, offset)
+	return e
+}
+
+// lastSpace returns the offset past the last space of text, 0 when there is none
+func lastSpace(text []byte) int {
+	for i := len(text); i > 0; {
+		r, size := utf8.DecodeLastRune(text[:i])
+		if unicode.IsSpace(r) {
+			return i
+		}
+		i -= size
+	}
+
+	return 0
+}
+
+// runeBoundary returns the offset of the incomplete rune ending text, its length when
+// there is none
+func runeBoundary(text []byte) int {
+	for i := len(text) - 1; i >= 0 && i >= len(text)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(text[i]) {
+			if !utf8.FullRune(text[i:]) {
+				return i
+			}
+			break
+		}
+	}
+
+	return len(text)
+}
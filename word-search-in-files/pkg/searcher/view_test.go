@@ -0,0 +1,106 @@
+package searcher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// render returns the text of the range with the marked pieces in brackets
+func render(v *View, start, end int64) (string, error) {
+	var sb strings.Builder
+	next := start
+
+	e := v.Read(start, end, func(offset int64, piece []byte, marked bool) error {
+		if offset != next {
+			return fmt.Errorf("piece %q at %d, want %d", piece, offset, next)
+		}
+		next += int64(len(piece))
+
+		if marked {
+			sb.WriteString("[" + string(piece) + "]")
+		} else {
+			sb.Write(piece)
+		}
+		return nil
+	})
+
+	return sb.String(), e
+}
+
+func TestSearcher_View(t *testing.T) {
+	// The word crosses the end of the first chunk
+	before := strings.Repeat(strings.Repeat("x", 99)+" ", 655) + strings.Repeat("y", 32) + " "
+	after := strings.Repeat(" "+strings.Repeat("x", 99), 100)
+	long := before + "hello" + after
+
+	fsys := fstest.MapFS{
+		"a.txt":       {Data: []byte("Hello, World! The world says hello.\nWorldwide")},
+		"runners.txt": {Data: []byte("The runners were running in the parks of the city every day")},
+		"long.txt":    {Data: []byte(long)},
+		"docs/b.txt":  {Data: []byte("World")},
+	}
+
+	s := &Searcher{fs: fsys}
+	s.Scan()
+
+	tests := []struct {
+		name       string
+		query      ViewQuery
+		start, end int64
+		want       string
+		wantErr    error
+	}{
+		{name: "Marked", query: ViewQuery{Path: "a.txt", Words: []string{"World hello"}}, end: -1, want: "Hello, [World]! The world says [hello].\nWorldwide"},
+		{name: "Pattern", query: ViewQuery{Path: "./a.txt", Words: []string{"W*"}}, end: -1, want: "Hello, [World]! The world says hello.\n[Worldwide]"},
+		{name: "No words", query: ViewQuery{Path: "a.txt"}, end: -1, want: "Hello, World! The world says hello.\nWorldwide"},
+		{name: "Range", query: ViewQuery{Path: "a.txt", Words: []string{"World"}}, start: 2, end: 10, want: "llo, [Wor]"},
+		{name: "Word cut at the start", query: ViewQuery{Path: "a.txt", Words: []string{"World"}}, start: 9, end: 14, want: "[rld]! "},
+		{name: "Chunks", query: ViewQuery{Path: "long.txt", Words: []string{"hello"}}, end: -1, want: before + "[hello]" + after},
+		{name: "E: range", query: ViewQuery{Path: "a.txt"}, start: 10, end: 1000, wantErr: ErrBadRange},
+		{name: "E: unknown", query: ViewQuery{Path: "nope.txt"}, wantErr: ErrUnknownFile},
+		{name: "E: traversal", query: ViewQuery{Path: "../a.txt"}, wantErr: ErrUnknownFile},
+		{name: "E: directory", query: ViewQuery{Path: "docs"}, wantErr: ErrUnknownFile},
+		{name: "E: out of the subtrees", query: ViewQuery{Path: "a.txt", Subtrees: []string{"docs"}}, wantErr: ErrUnknownFile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, e := s.View(tt.query)
+			if e == nil {
+				defer v.Close()
+
+				end := tt.end
+				if end < 0 {
+					end = v.Size
+				}
+
+				var got string
+				if got, e = render(v, tt.start, end); e == nil && got != tt.want {
+					t.Errorf("View() = %q, want %q", got, tt.want)
+				}
+			}
+
+			if !errors.Is(e, tt.wantErr) {
+				t.Errorf("View() error = %v, wantErr %v", e, tt.wantErr)
+			}
+		})
+	}
+
+	// The words are marked when the query matches their terms
+	s = &Searcher{fs: fsys, analyzers: true}
+	s.Scan()
+
+	v, e := s.View(ViewQuery{Path: "runners.txt", Words: []string{"run park"}})
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer v.Close()
+
+	got, e := render(v, 0, v.Size)
+	if want := "The runners were [running] in the [parks] of the city every day"; e != nil || got != want {
+		t.Errorf("View() = %q, %v, want %q", got, e, want)
+	}
+}
@@ -0,0 +1,237 @@
+package searcher
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+)
+
+// SymlinkPolicy tells the walker what to do with symbolic links
+type SymlinkPolicy int
+
+const (
+	// Symbolic links are left out of the index and reported as skipped
+	SymlinksSkip SymlinkPolicy = iota
+	// Links to files and directories are indexed as if they were the targets, a
+	// directory or a file reached a second time is skipped
+	SymlinksFollow
+)
+
+// ParseSymlinkPolicy parses "skip" or "follow"
+func ParseSymlinkPolicy(v string) (SymlinkPolicy, error) {
+	switch v {
+	case "", "skip":
+		return SymlinksSkip, nil
+	case "follow":
+		return SymlinksFollow, nil
+	}
+
+	return SymlinksSkip, fmt.Errorf("unknown symlink policy %q, expected skip or follow", v)
+}
+
+func (p SymlinkPolicy) String() string {
+	if p == SymlinksFollow {
+		return "follow"
+	}
+
+	return "skip"
+}
+
+// WithSymlinks sets the policy of the walker for symbolic links, SymlinksSkip by default
+func WithSymlinks(p SymlinkPolicy) Option {
+	return func(s *Searcher) {
+		s.symlinks = p
+	}
+}
+
+// SkipReason tells why the walker left an entry out of the index
+type SkipReason string
+
+const (
+	// A symbolic link with the SymlinksSkip policy
+	SkipSymlink SkipReason = "symlink"
+	// A symbolic link to nothing
+	SkipBrokenSymlink SkipReason = "broken_symlink"
+	// A link to a directory containing it
+	SkipCycle SkipReason = "cycle"
+	// A link to a directory already walked
+	SkipDuplicateDir SkipReason = "duplicate_dir"
+	// A hard link, or a symbolic link, to a file already indexed
+	SkipDuplicateFile SkipReason = "duplicate_file"
+	// Not a regular file: a named pipe, a socket or a device
+	SkipSpecial SkipReason = "special"
+)
+
+// SkippedEntry is an entry of the directory left out of the index
+type SkippedEntry struct {
+	Path   string
+	Reason SkipReason
+	// The path the entry duplicates, for the cycles and the duplicates
+	Of string
+}
+
+// Skipped returns the entries the last scan left out of the index
+func (s *Searcher) Skipped() []SkippedEntry {
+	s.muGlobal.RLock()
+	defer s.muGlobal.RUnlock()
+
+	return s.skipped
+}
+
+// fileID identifies a file across the links to it
+type fileID struct {
+	dev, ino uint64
+}
+
+// walker visits the entries of the directory in lexical order, the way fs.WalkDir
+// does, applying the symlink policy. The files and the directories are recognized by
+// device and inode where the file system tells them, see fileIDOf
+type walker struct {
+	s   *Searcher
+	snc *SearcherSync
+	// The generation to take the unchanged files from, nil reads all the files
+	prev *previousIndex
+
+	// Directory -> path it was walked as
+	dirs map[fileID]string
+	// Regular file -> path it was indexed as
+	files map[fileID]string
+}
+
+func (s *Searcher) predictWalkDir(snc *SearcherSync, prev *previousIndex) {
+	defer snc.wg.Done()
+
+	w := &walker{
+		s:     s,
+		snc:   snc,
+		prev:  prev,
+		dirs:  make(map[fileID]string),
+		files: make(map[fileID]string),
+	}
+
+	if e := w.walkRoot(); e != nil {
+		snc.errCh <- e
+	}
+}
+
+func (w *walker) walkRoot() error {
+	info, e := fs.Stat(w.s.fs, ".")
+	if e != nil {
+		return e
+	}
+
+	var ancestors []fileID
+	if id, ok := fileIDOf(info); ok {
+		w.dirs[id] = "."
+		ancestors = append(ancestors, id)
+	}
+
+	return w.walk(".", ancestors)
+}
+
+// walk visits the directory, ancestors are the ids of the directories containing it
+// and of the directory itself
+func (w *walker) walk(dir string, ancestors []fileID) error {
+	entries, e := fs.ReadDir(w.s.fs, dir)
+	if e != nil {
+		return e
+	}
+
+	for _, di := range entries {
+		p := path.Join(dir, di.Name())
+		mode := di.Type()
+
+		var info fs.FileInfo
+
+		if mode&fs.ModeSymlink != 0 {
+			if w.s.symlinks == SymlinksSkip {
+				w.skip(p, SkipSymlink, "")
+				continue
+			}
+
+			// Stat follows the link
+			if info, e = fs.Stat(w.s.fs, p); e != nil {
+				w.skip(p, SkipBrokenSymlink, "")
+				continue
+			}
+
+			mode = info.Mode().Type()
+		}
+
+		if !mode.IsDir() && !mode.IsRegular() {
+			w.skip(p, SkipSpecial, "")
+			continue
+		}
+
+		if info == nil {
+			if info, e = di.Info(); e != nil {
+				return e
+			}
+		}
+
+		id, ok := fileIDOf(info)
+
+		if mode.IsDir() {
+			if ok {
+				if of, seen := w.dirs[id]; seen {
+					if slices.Contains(ancestors, id) {
+						w.skip(p, SkipCycle, of)
+					} else {
+						w.skip(p, SkipDuplicateDir, of)
+					}
+					continue
+				}
+
+				w.dirs[id] = p
+			}
+
+			// The slice of the ancestors is only read by the callee, the next
+			// sibling may reuse the element
+			sub := ancestors
+			if ok {
+				sub = append(sub, id)
+			}
+
+			if e := w.walk(p, sub); e != nil {
+				return e
+			}
+
+			continue
+		}
+
+		if ok {
+			if of, seen := w.files[id]; seen {
+				w.skip(p, SkipDuplicateFile, of)
+				continue
+			}
+
+			w.files[id] = p
+		}
+
+		version := fileVersion(info)
+		if w.reuse(p, version) {
+			continue
+		}
+
+		if e := w.s.addFile(p, info, version, w.snc); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func (w *walker) skip(path string, reason SkipReason, of string) {
+	w.s.skipped = append(w.s.skipped, SkippedEntry{Path: path, Reason: reason, Of: of})
+}
+
+// addFile appends the file to the index and queues its content for the workers
+func (s *Searcher) addFile(path string, info fs.FileInfo, version string, snc *SearcherSync) error {
+	s.Files = append(s.Files, FileInfo{Path: path, Modified: info.ModTime(), Size: info.Size(), Version: version})
+
+	// The index of the added file will be used later to identify the words in the map
+	index := len(s.Files) - 1
+
+	return s.readByLineSimple(path, snc, index)
+}
@@ -0,0 +1,104 @@
+//go:build unix
+
+package searcher
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestSearcher_ScanLinks(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, data string) {
+		if e := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); e != nil {
+			t.Fatal(e)
+		}
+		if e := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	write("a.txt", "apple")
+	write("sub/b.txt", "pear")
+
+	for _, e := range []error{
+		os.Link(filepath.Join(dir, "a.txt"), filepath.Join(dir, "hard.txt")),
+		os.Symlink("a.txt", filepath.Join(dir, "link.txt")),
+		os.Symlink("sub", filepath.Join(dir, "sublink")),
+		os.Symlink("..", filepath.Join(dir, "sub", "loop")),
+		os.Symlink("nowhere", filepath.Join(dir, "broken")),
+		syscall.Mkfifo(filepath.Join(dir, "fifo"), 0o644),
+	} {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		policy      SymlinkPolicy
+		wantFiles   []string
+		wantSkipped []SkippedEntry
+	}{
+		{
+			name:      "Skip",
+			policy:    SymlinksSkip,
+			wantFiles: []string{"a.txt", "sub/b.txt"},
+			wantSkipped: []SkippedEntry{
+				{Path: "broken", Reason: SkipSymlink},
+				{Path: "fifo", Reason: SkipSpecial},
+				{Path: "hard.txt", Reason: SkipDuplicateFile, Of: "a.txt"},
+				{Path: "link.txt", Reason: SkipSymlink},
+				{Path: "sub/loop", Reason: SkipSymlink},
+				{Path: "sublink", Reason: SkipSymlink},
+			},
+		},
+		{
+			name:      "Follow",
+			policy:    SymlinksFollow,
+			wantFiles: []string{"a.txt", "sub/b.txt"},
+			wantSkipped: []SkippedEntry{
+				{Path: "broken", Reason: SkipBrokenSymlink},
+				{Path: "fifo", Reason: SkipSpecial},
+				{Path: "hard.txt", Reason: SkipDuplicateFile, Of: "a.txt"},
+				{Path: "link.txt", Reason: SkipDuplicateFile, Of: "a.txt"},
+				{Path: "sub/loop", Reason: SkipCycle, Of: "."},
+				{Path: "sublink", Reason: SkipDuplicateDir, Of: "sub"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := NewSearcher(dir, WithSymlinks(tt.policy))
+			if e := s.Scan(); e != nil {
+				t.Fatal(e)
+			}
+
+			var files []string
+			for _, f := range s.Files {
+				files = append(files, f.Path)
+			}
+
+			if !reflect.DeepEqual(files, tt.wantFiles) {
+				t.Errorf("Files = %v, want %v", files, tt.wantFiles)
+			}
+
+			if got := s.Skipped(); !reflect.DeepEqual(got, tt.wantSkipped) {
+				t.Errorf("Skipped() = %+v, want %+v", got, tt.wantSkipped)
+			}
+
+			if n := s.LastScan().Skipped[SkipDuplicateFile]; n == 0 {
+				t.Errorf("LastScan().Skipped = %v", s.LastScan().Skipped)
+			}
+
+			if len(s.Errors) > 0 {
+				t.Errorf("Errors = %v", s.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package searcher
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Changes kept per saved query for the clients catching up with Changes
+	watchHistory = 64
+	// Changes buffered per subscription, the ones that do not fit are dropped
+	subscriptionBuffer = 16
+)
+
+var ErrUnknownQuery = errors.New("no such saved query")
+
+// SavedQuery is a query re-evaluated after every scan
+type SavedQuery struct {
+	ID string
+	// The caller who saved the query, the only one who sees it. Empty on the servers
+	// without authentication
+	Owner   string
+	Words   []string
+	Filter  Filter
+	Created time.Time
+	// Number of matching files in the current generation
+	Matches int
+}
+
+// Change lists the files that started or stopped matching a saved query with the
+// scan that published the generation
+type Change struct {
+	QueryID    string
+	Generation uint64
+	Time       time.Time
+	Added      []string
+	Removed    []string
+}
+
+// Subscription delivers the changes of a saved query. C is closed when the query is
+// deleted or the subscription is closed. A slow reader misses the changes that do not
+// fit in the buffer, they can still be fetched with Searcher.Changes
+type Subscription struct {
+	C <-chan Change
+
+	ch      chan Change
+	queryID string
+	w       *watcher
+	once    sync.Once
+}
+
+func (sub *Subscription) Close() {
+	sub.w.unsubscribe(sub)
+}
+
+type watcher struct {
+	mu      sync.Mutex
+	queries map[string]*watchedQuery
+}
+
+type watchedQuery struct {
+	saved   SavedQuery
+	accept  func(FileInfo) bool
+	matched map[string]struct{}
+	history []Change
+	subs    map[*Subscription]struct{}
+}
+
+// Watch saves the query of the owner, from now on every scan computes the files that
+// started or stopped matching it. The files matching at the moment are the baseline,
+// they are not reported as added
+func (s *Searcher) Watch(q Query, owner string) (SavedQuery, error) {
+	words, _ := s.normalizeWords(q.Words, q.Filter.Lang)
+	if len(words) == 0 {
+		return SavedQuery{}, ErrEmptyQuery
+	}
+
+	accept, e := q.Filter.compile()
+	if e != nil {
+		return SavedQuery{}, e
+	}
+
+	id, e := newQueryID()
+	if e != nil {
+		return SavedQuery{}, e
+	}
+
+	s.muGlobal.RLock()
+	defer s.muGlobal.RUnlock()
+
+	if s.maxQueryCost > 0 {
+		if cost := s.estimate(words, q.Filter.Lang); cost > s.maxQueryCost {
+			return SavedQuery{}, fmt.Errorf("%w: it walks %d terms and postings, the limit is %d", ErrQueryTooBroad, cost, s.maxQueryCost)
+		}
+	}
+
+	wq := &watchedQuery{
+		saved: SavedQuery{
+			ID:      id,
+			Owner:   owner,
+			Words:   words,
+			Filter:  q.Filter,
+			Created: time.Now(),
+		},
+		accept: accept,
+		subs:   make(map[*Subscription]struct{}),
+	}
+	wq.matched = s.matchedPaths(wq)
+	wq.saved.Matches = len(wq.matched)
+
+	s.watch.mu.Lock()
+	defer s.watch.mu.Unlock()
+
+	if s.watch.queries == nil {
+		s.watch.queries = make(map[string]*watchedQuery)
+	}
+	s.watch.queries[id] = wq
+
+	return wq.saved, nil
+}
+
+// Unwatch deletes the saved query of the owner and closes its subscriptions
+func (s *Searcher) Unwatch(id, owner string) error {
+	s.watch.mu.Lock()
+	defer s.watch.mu.Unlock()
+
+	wq, ok := s.watch.lookup(id, owner)
+	if !ok {
+		return ErrUnknownQuery
+	}
+
+	delete(s.watch.queries, id)
+
+	for sub := range wq.subs {
+		sub.once.Do(func() { close(sub.ch) })
+	}
+
+	return nil
+}
+
+// Watched returns the saved queries of the owner, the oldest first
+func (s *Searcher) Watched(owner string) []SavedQuery {
+	s.watch.mu.Lock()
+	defer s.watch.mu.Unlock()
+
+	res := make([]SavedQuery, 0, len(s.watch.queries))
+	for _, wq := range s.watch.queries {
+		if wq.saved.Owner == owner {
+			res = append(res, wq.saved)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].Created.Equal(res[j].Created) {
+			return res[i].Created.Before(res[j].Created)
+		}
+		return res[i].ID < res[j].ID
+	})
+
+	return res
+}
+
+// Changes returns the kept changes of the saved query of the owner published after
+// the generation
+func (s *Searcher) Changes(id, owner string, since uint64) ([]Change, error) {
+	s.watch.mu.Lock()
+	defer s.watch.mu.Unlock()
+
+	wq, ok := s.watch.lookup(id, owner)
+	if !ok {
+		return nil, ErrUnknownQuery
+	}
+
+	var res []Change
+	for _, c := range wq.history {
+		if c.Generation > since {
+			res = append(res, c)
+		}
+	}
+
+	return res, nil
+}
+
+// Subscribe returns a subscription to the changes of the saved query of the owner
+func (s *Searcher) Subscribe(id, owner string) (*Subscription, error) {
+	s.watch.mu.Lock()
+	defer s.watch.mu.Unlock()
+
+	wq, ok := s.watch.lookup(id, owner)
+	if !ok {
+		return nil, ErrUnknownQuery
+	}
+
+	ch := make(chan Change, subscriptionBuffer)
+	sub := &Subscription{C: ch, ch: ch, queryID: id, w: &s.watch}
+	wq.subs[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// lookup returns the saved query of the owner, the ones of the others do not exist for
+// it. The caller must hold mu
+func (w *watcher) lookup(id, owner string) (*watchedQuery, bool) {
+	wq, ok := w.queries[id]
+	if !ok || wq.saved.Owner != owner {
+		return nil, false
+	}
+
+	return wq, true
+}
+
+func (w *watcher) unsubscribe(sub *Subscription) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if wq, ok := w.queries[sub.queryID]; ok {
+		delete(wq.subs, sub)
+	}
+
+	sub.once.Do(func() { close(sub.ch) })
+}
+
+// notifyWatchers computes the changes of every saved query against the generation
+// just published and delivers them, the caller must hold muGlobal
+func (s *Searcher) notifyWatchers() {
+	s.watch.mu.Lock()
+	defer s.watch.mu.Unlock()
+
+	now := time.Now()
+
+	for _, wq := range s.watch.queries {
+		matched := s.matchedPaths(wq)
+
+		c := Change{QueryID: wq.saved.ID, Generation: s.generation, Time: now}
+
+		for path := range matched {
+			if _, ok := wq.matched[path]; !ok {
+				c.Added = append(c.Added, path)
+			}
+		}
+
+		for path := range wq.matched {
+			if _, ok := matched[path]; !ok {
+				c.Removed = append(c.Removed, path)
+			}
+		}
+
+		wq.matched = matched
+		wq.saved.Matches = len(matched)
+
+		if len(c.Added) == 0 && len(c.Removed) == 0 {
+			continue
+		}
+
+		sort.Strings(c.Added)
+		sort.Strings(c.Removed)
+
+		wq.history = append(wq.history, c)
+		if len(wq.history) > watchHistory {
+			wq.history = wq.history[len(wq.history)-watchHistory:]
+		}
+
+		for sub := range wq.subs {
+			select {
+			case sub.ch <- c:
+			default:
+			}
+		}
+	}
+}
+
+// matchedPaths returns the paths of the files matching the saved query, the caller
+// must hold muGlobal
+func (s *Searcher) matchedPaths(wq *watchedQuery) map[string]struct{} {
+	// The cost was accepted when the query was saved
+	terms, _ := s.resolve(wq.saved.Words, wq.saved.Filter.Lang, 0)
+	// The scores do not matter
+	hits := s.match(terms, wq.accept, 0)
+
+	res := make(map[string]struct{}, len(hits))
+	for _, h := range hits {
+		res[s.Files[h.index].Path] = struct{}{}
+	}
+
+	return res
+}
+
+func newQueryID() (string, error) {
+	b := make([]byte, 8)
+	if _, e := rand.Read(b); e != nil {
+		return "", e
+	}
+
+	return hex.EncodeToString(b), nil
+}
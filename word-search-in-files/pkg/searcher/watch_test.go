@@ -0,0 +1,86 @@
+package searcher
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSearcher_Watch(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("top secret")},
+		"b.txt": {Data: []byte("nothing here")},
+	}
+
+	s := &Searcher{fs: fsys}
+	s.Scan()
+
+	saved, e := s.Watch(Query{Words: []string{"secret"}}, "alice")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if saved.Matches != 1 {
+		t.Errorf("Watch() matches = %d, want 1", saved.Matches)
+	}
+
+	sub, e := s.Subscribe(saved.ID, "alice")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Nothing changed
+	s.Scan()
+
+	fsys["c.txt"] = &fstest.MapFile{Data: []byte("another secret")}
+	delete(fsys, "a.txt")
+	s.Scan()
+
+	want := Change{QueryID: saved.ID, Generation: 3, Added: []string{"c.txt"}, Removed: []string{"a.txt"}}
+
+	select {
+	case got := <-sub.C:
+		got.Time = want.Time
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Subscription got %+v, want %+v", got, want)
+		}
+	default:
+		t.Fatalf("Subscription got nothing")
+	}
+
+	changes, e := s.Changes(saved.ID, "alice", 2)
+	if e != nil || len(changes) != 1 || changes[0].Generation != 3 {
+		t.Errorf("Changes() = %+v, %v", changes, e)
+	}
+
+	// The saved queries of the others do not exist
+	if got := s.Watched("bob"); len(got) != 0 {
+		t.Errorf("Watched() of another owner = %+v", got)
+	}
+	if _, e := s.Changes(saved.ID, "bob", 0); e != ErrUnknownQuery {
+		t.Errorf("Changes() of another owner error = %v, want %v", e, ErrUnknownQuery)
+	}
+	if _, e := s.Subscribe(saved.ID, "bob"); e != ErrUnknownQuery {
+		t.Errorf("Subscribe() of another owner error = %v, want %v", e, ErrUnknownQuery)
+	}
+	if e := s.Unwatch(saved.ID, "bob"); e != ErrUnknownQuery {
+		t.Errorf("Unwatch() of another owner error = %v, want %v", e, ErrUnknownQuery)
+	}
+	if got := s.Watched("alice"); len(got) != 1 || got[0].Owner != "alice" {
+		t.Errorf("Watched() = %+v", got)
+	}
+
+	if e := s.Unwatch(saved.ID, "alice"); e != nil {
+		t.Fatal(e)
+	}
+
+	if _, ok := <-sub.C; ok {
+		t.Errorf("Subscription is not closed by Unwatch()")
+	}
+
+	sub.Close()
+
+	if _, e := s.Changes(saved.ID, "alice", 0); e != ErrUnknownQuery {
+		t.Errorf("Changes() error = %v, want %v", e, ErrUnknownQuery)
+	}
+}
@@ -0,0 +1,179 @@
+package searcher
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// DefaultMaxQueryCost is the query cost limit of NewSearcher
+const DefaultMaxQueryCost = 1_000_000
+
+var ErrQueryTooBroad = errors.New("query is too broad")
+
+// WithMaxQueryCost rejects the queries walking more than the number of dictionary
+// terms and postings with ErrQueryTooBroad, 0 does not limit
+func WithMaxQueryCost(n int) Option {
+	return func(s *Searcher) {
+		s.maxQueryCost = n
+	}
+}
+
+// isWildcard reports whether the query word is a pattern: `*` matches any letters
+// or digits and `?` a single one
+func isWildcard(word string) bool {
+	return strings.ContainsAny(word, "*?")
+}
+
+// normalizeWildcard normalizes the literal parts of the pattern the same way as the
+// words, consecutive stars are merged
+func normalizeWildcard(word string) string {
+	var b strings.Builder
+
+	start := 0
+	for i, r := range word {
+		if r != '*' && r != '?' {
+			continue
+		}
+
+		b.WriteString(removePunctuation(word[start:i]))
+		start = i + 1
+
+		if r == '*' && strings.HasSuffix(b.String(), "*") {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString(removePunctuation(word[start:]))
+
+	return b.String()
+}
+
+// queryTerm is a word of a query with what it stands for in the index: the word and
+// its synonyms or their terms of the analyzers, or the terms matching the pattern
+type queryTerm struct {
+	word  string
+	terms []string
+	// File -> occurrences of the terms
+	postings map[int]int
+	// The field of a word such as `name:report`, empty for the content
+	field string
+	// File -> occurrences of a word of the content in the name, to rank the files
+	// named after it higher
+	names map[int]int
+}
+
+// termsOf returns the terms of the dictionary the query word stands for: the ones
+// matching the pattern, lower case with the analyzers as their terms are, or the terms
+// of the analyzers of the languages of the word and its synonyms. The caller must hold
+// muGlobal
+func (s *Searcher) termsOf(word string, langs []string) (terms []string, scanned int) {
+	if !isWildcard(word) {
+		for _, w := range append([]string{word}, s.synonyms.Expand(word, s.analyzers)...) {
+			for _, term := range s.variants(w, langs) {
+				if _, ok := s.Words[term]; ok && !slices.Contains(terms, term) {
+					terms = append(terms, term)
+				}
+			}
+		}
+
+		return terms, 0
+	}
+
+	// Only the letters change, the metacharacters have no case
+	if s.analyzers {
+		word = strings.ToLower(word)
+	}
+
+	candidates := s.candidates(word)
+	for _, term := range candidates {
+		if ok, _ := path.Match(word, term); ok {
+			terms = append(terms, term)
+		}
+	}
+
+	return terms, len(candidates)
+}
+
+// estimate returns the number of the dictionary terms and postings the words walk,
+// the caller must hold muGlobal
+func (s *Searcher) estimate(words []string, langs []string) int {
+	cost := 0
+
+	for _, word := range words {
+		if field, value, ok := fieldOf(word); ok {
+			postings, scanned := s.fieldPostings(field, value)
+			cost += scanned + len(postings)
+			continue
+		}
+
+		terms, scanned := s.termsOf(word, langs)
+		cost += scanned
+
+		for _, term := range terms {
+			cost += len(s.Words[term])
+		}
+	}
+
+	return cost
+}
+
+// resolve looks the words up in the index, rejecting the queries above the cost
+// limit. The caller must hold muGlobal
+func (s *Searcher) resolve(words []string, langs []string, maxCost int) ([]queryTerm, error) {
+	if maxCost > 0 {
+		if cost := s.estimate(words, langs); cost > maxCost {
+			return nil, fmt.Errorf("%w: it walks %d terms and postings, the limit is %d", ErrQueryTooBroad, cost, maxCost)
+		}
+	}
+
+	res := make([]queryTerm, len(words))
+
+	for i, word := range words {
+		res[i].word = word
+
+		if field, value, ok := fieldOf(word); ok {
+			res[i].field = field
+			res[i].postings, _ = s.fieldPostings(field, value)
+			continue
+		}
+
+		if !isWildcard(word) {
+			res[i].names = s.names[strings.ToLower(word)]
+		}
+
+		res[i].terms, _ = s.termsOf(word, langs)
+
+		switch len(res[i].terms) {
+		case 0:
+			continue
+		case 1:
+			res[i].postings = s.Words[res[i].terms[0]]
+			continue
+		}
+
+		union := make(map[int]int)
+		for _, term := range res[i].terms {
+			for index, tf := range s.Words[term] {
+				union[index] += tf
+			}
+		}
+		res[i].postings = union
+	}
+
+	return res, nil
+}
+
+// candidates returns the terms of the dictionary that may match the pattern: the
+// ones starting with its literal prefix, all of them for a leading wildcard. The
+// caller must hold muGlobal
+func (s *Searcher) candidates(pattern string) []string {
+	prefix := pattern[:strings.IndexAny(pattern, "*?")]
+	if prefix == "" {
+		return s.dict
+	}
+
+	return s.withPrefix(prefix)
+}
@@ -0,0 +1,79 @@
+package searcher
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSearcher_FindWildcard(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("apple pie")},
+		"b.txt": {Data: []byte("applesauce and pear")},
+		"c.txt": {Data: []byte("maple bear\nbanana split")},
+	}
+
+	tests := []struct {
+		name      string
+		words     []string
+		maxCost   int
+		analyzers bool
+		want      []string
+		wantErr   error
+	}{
+		{name: "Prefix", words: []string{"app*"}, want: []string{"a.txt", "b.txt"}},
+		{name: "Suffix", words: []string{"*ple"}, want: []string{"a.txt", "c.txt"}},
+		{name: "Single letter", words: []string{"?ear"}, want: []string{"b.txt", "c.txt"}},
+		{name: "With a word", words: []string{"*ple", "bear"}, want: []string{"c.txt"}},
+		{name: "Punctuation", words: []string{"ap-p**"}, want: []string{"a.txt", "b.txt"}},
+		{name: "No term", words: []string{"zz*"}, want: nil},
+		{name: "Case", words: []string{"APP*"}, want: nil},
+		{name: "Case with analyzers", words: []string{"APP*"}, analyzers: true, want: []string{"a.txt", "b.txt"}},
+		{name: "Mixed case with analyzers", words: []string{"?EaR"}, analyzers: true, want: []string{"b.txt", "c.txt"}},
+		{name: "Under the cost", words: []string{"app*"}, maxCost: 20, want: []string{"a.txt", "b.txt"}},
+		{name: "E: too broad", words: []string{"*"}, maxCost: 5, wantErr: ErrQueryTooBroad},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Searcher{fs: fsys, maxQueryCost: tt.maxCost, analyzers: tt.analyzers}
+			s.Scan()
+
+			res, e := s.Find(Query{Words: tt.words})
+			if !errors.Is(e, tt.wantErr) {
+				t.Fatalf("Find() error = %v, wantErr %v", e, tt.wantErr)
+			}
+			if e != nil {
+				return
+			}
+
+			var got []string
+			for _, hit := range res.Hits {
+				got = append(got, hit.Path)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearcher_FindWildcardSegments(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.txt": {Data: []byte("apple pie\napricot jam\nbanana split")},
+	}
+
+	s := &Searcher{fs: fsys, granularity: GranularityLine}
+	s.Scan()
+
+	res, e := s.Find(Query{Words: []string{"ap*"}})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if len(res.Hits) != 1 || len(res.Hits[0].Segments) != 2 {
+		t.Fatalf("Find() = %+v, want the first two lines", res.Hits)
+	}
+}